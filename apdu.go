@@ -23,10 +23,15 @@ const (
 	HighLevelSecurity               = 2
 )
 
+// Client max receive pdu size proposed in AARQ.
+const aarqMaxReceivePduSize = uint16(0x04B0)
+
 type AARQ struct {
-	appCtxt   appContext
-	authMech  authMechanism
-	authValue string
+	appCtxt     appContext
+	authMech    authMechanism
+	authValue   string
+	conformance []byte // proposed conformance block, LN services if nil
+	gbt         bool   // propose general block transfer in addition to 'conformance'
 }
 
 func (aarq *AARQ) encode() ([]byte, error) {
@@ -43,10 +48,17 @@ func (aarq *AARQ) encode() ([]byte, error) {
 	callingAuth := append([]byte{0xAC, vlen + 2, 0x80, vlen},
 		[]byte(aarq.authValue)..., // calling auth value
 	)
+	conformance := aarq.conformance
+	if nil == conformance {
+		conformance = []byte{0x00, 0x7E, 0x1F}
+	}
+	if aarq.gbt {
+		conformance = []byte{conformance[0] | 0x20, conformance[1], conformance[2]}
+	}
 	userInfo := []byte{0xBE, 0x10, 0x04, 0x0E,
 		0x01, 0x00, 0x00, 0x00, 0x06, 0x5F, 0x1F, 0x04, 0x00, // initiate request
-		0x00, 0x7E, 0x1F, // conformance block
-		0x04, 0xB0, // max receive pdu length
+		conformance[0], conformance[1], conformance[2], // conformance block
+		byte(aarqMaxReceivePduSize >> 8), byte(aarqMaxReceivePduSize & 0xFF), // max receive pdu length
 	}
 
 	var buf bytes.Buffer
@@ -81,9 +93,11 @@ const (
 )
 
 type AARE struct {
-	appCtxt    appContext
-	result     assocResult
-	diagnostic assocDiagnostic
+	appCtxt     appContext
+	result      assocResult
+	diagnostic  assocDiagnostic
+	conformance []byte // negotiated conformance block
+	maxPduSize  uint16 // server max receive pdu size
 }
 
 func (aare *AARE) decode(b []byte) (err error) {
@@ -122,6 +136,8 @@ func (aare *AARE) decode(b []byte) (err error) {
 	read(&confBlock)
 	read(&maxPduSize)
 	read(&vaaName)
+	aare.conformance = confBlock
+	aare.maxPduSize = maxPduSize
 
 	if tag != 0x61 {
 		return fmt.Errorf("invalid AARE")
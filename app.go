@@ -2,15 +2,24 @@ package gocosem
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 )
 
 var ErrorRequestTimeout = errors.New("request timeout")
 var ErrorBlockTimeout = errors.New("block receive timeout")
+var ErrorReleaseTimeout = errors.New("release timeout")
+
+// How long Close() waits for RLRE before tearing down transport.
+const appReleaseTimeout = time.Second * 5
+
+// Default of AppConn.GbtBlockTimeout.
+const appGbtBlockTimeout = time.Second * 5
 
 type DlmsRequest struct {
 	ClassId          DlmsClassId
@@ -21,10 +30,11 @@ type DlmsRequest struct {
 	AccessParameter  *DlmsData
 	Data             *DlmsData // Data to be sent with SetRequest. If non-nil and 'AttributeId' > 0 then SetRequest is sent.
 	MethodParameters *DlmsData // Optional method invokation parameters used with ActionRequest.
-	BlockSize        int       // If > 0 then data sent with SetReuqest are sent in bolocks.
+	BlockSize        int       // If > 0 then data sent with SetReuqest or method parameters sent with ActionRequest are sent in bolocks. If 0 then they are sent in blocks only if request exceeds server max receive pdu size.
 
 	rawData     []byte // Remaining data to be sent using block transfer.
 	blockNumber uint32 // Number of last block sent.
+	blockSize   int    // 'BlockSize' or block size derived from server max receive pdu size.
 }
 
 type DlmsResponse struct {
@@ -58,6 +68,41 @@ type AppConn struct {
 	applicationClient uint16
 	logicalDevice     uint16
 	invokeId          uint8
+	released          bool // guarded by mtx
+
+	GbtWindowSize uint8 // Receive window size advertised to server if general block transfer is used (1 if 0, at most 63).
+	GbtBlockSize  int   // If > 0 and general block transfer was negotiated then requests longer than 'GbtBlockSize' are sent using general block transfer.
+	// If > 0 then blocks of general block transfer reply missing for 'GbtBlockTimeout' are requested again, after few unsuccessful
+	// attempts ErrorBlockTimeout is returned and transport is aborted.
+	GbtBlockTimeout time.Duration
+	gbt             bool // general block transfer negotiated
+	gbtEndpoint     *tGbtEndpoint
+
+	serverMaxReceivePduSize uint16 // negotiated in association, longer SET and ACTION requests are sent in blocks (no limit if 0)
+
+	// Maximum number of requests sent before their replies are received (1 if 0, at most 16). AppConn is safe for concurrent use, if
+	// 'MaxOutstanding' is 1 requests from multiple goroutines are serialized. Set it to more than 1 only if server supports multiple
+	// outstanding services, requests are then pipelined and replies matched to requests by invoke id. Requests are always serialized
+	// if general block transfer was negotiated or transport is UDP (only last request is retransmitted).
+	MaxOutstanding int
+
+	mtx          sync.Mutex
+	cond         *sync.Cond
+	pending      map[uint8]*tPendingRequest // outstanding requests by invoke id
+	abandoned    map[uint8]bool             // invoke ids of cancelled requests whose replies may still arrive, they are reused only if no other id is free
+	exclusive    bool                       // no new requests may be sent, association is being released
+	sendMtx      sync.Mutex
+	receiveToken chan bool        // held by goroutine reading replies from transport
+	linkReset    error            // hdlc link was reset, association is lost and requests fail with this error
+	asyncQueue   []*tAsyncRequest // async requests waiting for free invoke id
+	asyncReady   chan uint8       // invoke ids of async requests whose replies were handed over by another reader
+	asyncReading bool             // goroutine reading replies to async requests is running
+}
+
+type tPendingRequest struct {
+	reply chan []byte     // reply handed over by goroutine reading transport
+	ctx   context.Context // request is cancelled when it is done
+	async *tAsyncRequest  // if not nil, request is completed by callback instead of by waiting caller
 }
 
 type DlmsResultResponse []*DlmsRequestResponse
@@ -88,13 +133,375 @@ func NewAppConn(dconn *DlmsConn, applicationClient uint16, logicalDevice uint16,
 	aconn.applicationClient = applicationClient
 	aconn.logicalDevice = logicalDevice
 	aconn.invokeId = invokeId
+	aconn.cond = sync.NewCond(&aconn.mtx)
+	aconn.pending = make(map[uint8]*tPendingRequest)
+	aconn.abandoned = make(map[uint8]bool)
+	aconn.receiveToken = make(chan bool, 1)
+	aconn.receiveToken <- true
+	aconn.asyncReady = make(chan uint8, 0x10)
+	aconn.GbtBlockTimeout = appGbtBlockTimeout
 
 	return aconn
 }
 
+func (aconn *AppConn) maxOutstanding() int {
+	if aconn.gbt || (aconn.MaxOutstanding < 1) || (Transport_UDP == aconn.dconn.transportType) {
+		return 1
+	}
+	if aconn.MaxOutstanding > 0x10 {
+		return 0x10
+	}
+	return aconn.MaxOutstanding
+}
+
+/*
+Waits until request may be sent and allocates invoke id for it, request is
+cancelled when 'ctx' is done. Ids are allocated starting with invoke id the
+connection was created with, serialized requests therefore always use the
+same id unless previous request was cancelled.
+*/
+func (aconn *AppConn) allocateInvokeId(ctx context.Context) (err error, invokeId uint8) {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+
+	if nil != ctx.Done() {
+		// wake up waiting loop below when ctx is done
+		stop := make(chan bool)
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				aconn.mtx.Lock()
+				aconn.cond.Broadcast()
+				aconn.mtx.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+
+	for {
+		err = ctx.Err()
+		if nil != err {
+			return err, 0
+		}
+		if !aconn.exclusive && (len(aconn.pending) < aconn.maxOutstanding()) {
+			break
+		}
+		aconn.cond.Wait()
+	}
+	return nil, aconn.takeInvokeId(ctx)
+}
+
+// Allocates free invoke id, must be called with 'mtx' held and less than maxOutstanding() requests pending.
+func (aconn *AppConn) takeInvokeId(ctx context.Context) (invokeId uint8) {
+	invokeId = 0x10
+	for i := uint8(0); i <= 0x0F; i++ {
+		id := (aconn.invokeId + i) & 0x0F
+		if _, ok := aconn.pending[id]; ok {
+			continue
+		}
+		if !aconn.abandoned[id] {
+			invokeId = id
+			break
+		}
+		if 0x10 == invokeId {
+			invokeId = id
+		}
+	}
+	delete(aconn.abandoned, invokeId)
+	aconn.pending[invokeId] = &tPendingRequest{reply: make(chan []byte, 1), ctx: ctx}
+	return invokeId
+}
+
+// Frees invoke id of finished request, async requests waiting for it are sent.
+func (aconn *AppConn) freeInvokeId(invokeId uint8) {
+	aconn.mtx.Lock()
+	delete(aconn.pending, invokeId)
+	aconn.cond.Broadcast()
+	aconn.mtx.Unlock()
+
+	aconn.startAsync()
+}
+
+// Waits until all outstanding requests are finished and blocks sending of new ones until unlockExclusive() is called.
+func (aconn *AppConn) lockExclusive() {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+
+	for aconn.exclusive || (len(aconn.pending) > 0) {
+		aconn.cond.Wait()
+	}
+	aconn.exclusive = true
+}
+
+func (aconn *AppConn) unlockExclusive() {
+	aconn.mtx.Lock()
+	aconn.exclusive = false
+	aconn.cond.Broadcast()
+	aconn.mtx.Unlock()
+
+	aconn.startAsync()
+}
+
+/*
+Receives reply to request 'invokeId'. Only one goroutine at a time reads
+transport, replies it receives to other outstanding requests are handed over
+to them.
+
+If request context is done before reply is received, request is abandoned and
+ctx.Err() is returned. Transport read in progress is finished in background,
+reply is then handed over to request it belongs to or discarded if it belongs
+to cancelled request.
+*/
+func (aconn *AppConn) receiveReply(invokeId uint8) (pdu []byte, err error) {
+	aconn.mtx.Lock()
+	req := aconn.pending[invokeId]
+	aconn.mtx.Unlock()
+	done := req.ctx.Done()
+
+	for {
+		select {
+		case pdu = <-req.reply:
+			return pdu, nil
+		case <-aconn.receiveToken:
+		case <-done:
+			return nil, aconn.abandonRequest(invokeId, req)
+		}
+		// reply may have been handed over by previous reader
+		select {
+		case pdu = <-req.reply:
+			aconn.receiveToken <- true
+			return pdu, nil
+		default:
+		}
+
+		err = aconn.linkResetError()
+		if nil != err {
+			aconn.receiveToken <- true
+			return nil, err
+		}
+		if nil == done {
+			pdu, err = aconn.receivePdu()
+		} else {
+			var rpdu []byte
+			var rerr error
+			read := make(chan bool, 1)
+			go func() {
+				rpdu, rerr = aconn.receivePdu()
+				read <- true
+			}()
+			select {
+			case <-read:
+				pdu, err = rpdu, rerr
+			case <-done:
+				err = aconn.abandonRequest(invokeId, req)
+				go func() {
+					<-read
+					if (nil == rerr) && aconn.dispatchReply(invokeId, rpdu) {
+						warnLog("discarding reply received after request was cancelled")
+					}
+					aconn.receiveToken <- true
+				}()
+				return nil, err
+			}
+		}
+		if nil != err {
+			aconn.receiveToken <- true
+			return nil, err
+		}
+		mine := aconn.dispatchReply(invokeId, pdu)
+		aconn.receiveToken <- true
+		if mine {
+			return pdu, nil
+		}
+	}
+}
+
+// Marks cancelled request 'invokeId' abandoned so that its late reply is discarded. Returns error request was cancelled with.
+func (aconn *AppConn) abandonRequest(invokeId uint8, req *tPendingRequest) error {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+
+	select {
+	case <-req.reply:
+		// reply was received meanwhile, no other one will arrive
+	default:
+		aconn.abandoned[invokeId] = true
+	}
+	debugLog("request cancelled, invokeId: %d", invokeId)
+	return req.ctx.Err()
+}
+
+// Hands reply over to outstanding request it belongs to. Returns true if reply is to be processed by request 'invokeId'.
+func (aconn *AppConn) dispatchReply(invokeId uint8, pdu []byte) bool {
+	if len(pdu) < 3 {
+		return true
+	}
+	switch pdu[0] {
+	case 0xC4, 0xC5, 0xC7:
+	default:
+		return true
+	}
+	invokeIdRcv := uint8((pdu[2] & 0xF0) >> 4)
+
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+
+	if aconn.abandoned[invokeIdRcv] {
+		warnLog("discarding reply to cancelled request, invokeId: %d", invokeIdRcv)
+		delete(aconn.abandoned, invokeIdRcv)
+		return false
+	}
+	if invokeIdRcv == invokeId {
+		return true
+	}
+	req, ok := aconn.pending[invokeIdRcv]
+	if !ok {
+		// not outstanding, caller reports invoke id mismatch
+		return true
+	}
+	aconn.handOver(invokeIdRcv, req, pdu)
+	return false
+}
+
+// Hands reply over to pending request 'invokeId', must be called with 'mtx' held.
+func (aconn *AppConn) handOver(invokeId uint8, req *tPendingRequest, pdu []byte) {
+	select {
+	case req.reply <- pdu:
+		if nil != req.async {
+			// wake up goroutine completing async requests
+			select {
+			case aconn.asyncReady <- invokeId:
+			default:
+			}
+		}
+	default:
+		warnLog("discarding duplicate reply, invokeId: %d", invokeId)
+	}
+}
+
+/*
+Releases association by sending RLRQ and waiting for RLRE. Transport stays
+open and may be used for another association.
+
+Optional 'initiateRequest' is sent in RLRQ user-information, it is ciphered
+if association was established using high level security mechanism (GMAC).
+InitiateResponse is returned if meter sent it in RLRE user-information.
+*/
+func (aconn *AppConn) Release(initiateRequest *DlmsInitiateRequest) (initiateResponse *DlmsInitiateResponse, err error) {
+	dconn := aconn.dconn
+
+	aconn.lockExclusive()
+	defer aconn.unlockExclusive()
+	// reply to cancelled request may still be read in background
+	<-aconn.receiveToken
+	defer func() { aconn.receiveToken <- true }()
+
+	if aconn.isReleased() {
+		return nil, nil
+	}
+	err = aconn.linkResetError()
+	if nil != err {
+		// association was lost with the link, there is nothing to release
+		return nil, err
+	}
+
+	rlrq := new(RLRQapdu)
+	reason := RLRQ_REASON_NORMAL
+	rlrq.reason = &reason
+
+	if nil != initiateRequest {
+		var buf bytes.Buffer
+		err = initiateRequest.encode(&buf)
+		if nil != err {
+			return nil, err
+		}
+		userInformation := buf.Bytes()
+		if dconn.authenticationMechanismId == high_level_security_mechanism_using_GMAC {
+			err, userInformation = dconn.encryptPduGSM(userInformation)
+			if nil != err {
+				return nil, err
+			}
+		}
+		_userInformation := tAsn1OctetString(userInformation)
+		rlrq.userInformation = &_userInformation
+	}
+
+	var buf bytes.Buffer
+	err = encode_RLRQapdu(&buf, rlrq)
+	if nil != err {
+		return nil, err
+	}
+
+	err = dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, buf.Bytes())
+	if nil != err {
+		return nil, err
+	}
+	pdu, err := dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+	if nil != err {
+		return nil, err
+	}
+	err = decodeErrorPdu(pdu)
+	if nil != err {
+		return nil, err
+	}
+
+	err, rlre := decode_RLREapdu(bytes.NewReader(pdu))
+	if nil != err {
+		return nil, err
+	}
+	aconn.mtx.Lock()
+	aconn.released = true
+	aconn.mtx.Unlock()
+
+	if (nil != rlre.reason) && (RLRE_REASON_NORMAL != *rlre.reason) {
+		err = fmt.Errorf("release failed, RLRE.reason: %d", *rlre.reason)
+		errorLog("%s", err)
+		return nil, err
+	}
+
+	if nil != rlre.userInformation {
+		userInformation := ([]byte)(*rlre.userInformation)
+		if dconn.authenticationMechanismId == high_level_security_mechanism_using_GMAC {
+			err, userInformation = dconn.decryptPduGSM(userInformation)
+			if nil != err {
+				return nil, err
+			}
+		}
+		initiateResponse = new(DlmsInitiateResponse)
+		err = initiateResponse.decode(bytes.NewReader(userInformation))
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	debugLog("application connection released")
+	return initiateResponse, nil
+}
+
+/*
+Releases association and closes transport connection. Association is not
+released if any request is outstanding (Release() would wait for it to
+finish), transport is then closed right away and outstanding requests fail.
+*/
 func (aconn *AppConn) Close() {
 	debugLog("closing application connection ...")
-	if !aconn.dconn.closed {
+	if !aconn.dconn.isClosed() {
+		if !aconn.isReleased() && aconn.idle() {
+			ch := make(chan error, 1)
+			go func() {
+				_, err := aconn.Release(nil)
+				ch <- err
+			}()
+			select {
+			case err := <-ch:
+				if nil != err {
+					warnLog("release failed: %v", err)
+				}
+			case <-time.After(appReleaseTimeout):
+				warnLog("release failed: %v", ErrorReleaseTimeout)
+			}
+		}
 		aconn.dconn.Close()
 	}
 	debugLog("application connection closed")
@@ -255,6 +662,210 @@ func (aconn *AppConn) processActionResponseNormal(rips []*DlmsRequestResponse, r
 	}
 }
 
+func (aconn *AppConn) processActionResponseWithList(rips []*DlmsRequestResponse, r io.Reader, errr error) error {
+
+	err, actionResults, dataAccessResults, datas := decode_ActionResponseWithList(r)
+
+	if len(actionResults) != len(rips) {
+		err = fmt.Errorf("unexpected count of received list entries")
+		errorLog("%s", err)
+
+		if len(actionResults) > len(rips) {
+			actionResults = actionResults[0:len(rips)]
+		}
+	}
+
+	for i := 0; i < len(actionResults); i += 1 {
+		rip := rips[i]
+		rip.Rep = new(DlmsResponse)
+		rip.Rep.ActionResult = actionResults[i]
+		if nil != dataAccessResults[i] {
+			rip.Rep.DataAccessResult = *dataAccessResults[i]
+			rip.Rep.Data = datas[i]
+		} else {
+			rip.Rep.DataAccessResult = dataAccessResult_success
+			rip.Rep.Data = nil
+		}
+	}
+
+	if nil == err {
+		return nil
+	} else {
+		if nil != errr {
+			return errr
+		} else {
+			return err
+		}
+	}
+}
+
+func (aconn *AppConn) processActionBlockResponse(rips []*DlmsRequestResponse, r io.Reader, err error) error {
+	if 1 == len(rips) {
+		debugLog("blocks received, processing ActionResponseNormal")
+		return aconn.processActionResponseNormal(rips, r, err)
+	} else {
+		debugLog("blocks received, processing ActionResponseWithList")
+		return aconn.processActionResponseWithList(rips, r, err)
+	}
+}
+
+// Sends next request of block transfer and processes reply.
+func (aconn *AppConn) sendBlockRequest(rips []*DlmsRequestResponse, invokeId uint8, pdu []byte) (err error) {
+	err = aconn.sendPdu(pdu)
+	if nil != err {
+		return err
+	}
+
+	pdu, err = aconn.receiveReply(invokeId)
+	if nil != err {
+		return err
+	}
+
+	err, p, buf := aconn.decodeReplyHeader(invokeId, pdu)
+	if nil != err {
+		return err
+	}
+
+	return aconn.processReply(rips, p, buf)
+}
+
+// Reads reply tag, reply type and invoke id of reply to request 'invokeId'. Transport is aborted if reply is malformed.
+func (aconn *AppConn) decodeReplyHeader(invokeId uint8, pdu []byte) (err error, p []byte, buf *bytes.Buffer) {
+	buf = bytes.NewBuffer(pdu)
+
+	p = make([]byte, 3)
+	err = binary.Read(buf, binary.BigEndian, p)
+	if nil != err {
+		errorLog("io.Read() failed: %v", err)
+		// association state is unknown, RLRQ cannot be sent while this request holds its invoke id
+		aconn.dconn.abort()
+		return err, nil, nil
+	}
+	invokeIdRcv := uint8((p[2] & 0xF0) >> 4)
+	if invokeIdRcv != invokeId {
+		err = fmt.Errorf("invoke ids differs: invokeId sent: %v, invokeId received: %v", invokeId, invokeIdRcv)
+		errorLog("%s", err)
+		aconn.dconn.abort()
+		return err, nil, nil
+	}
+	return nil, p, buf
+}
+
+// Returns next block of request data to be sent using block transfer.
+func (req *DlmsRequest) nextBlock() (lastBlock bool, blockNumber uint32, rawData []byte) {
+	n := req.blockSize
+	if n > len(req.rawData) {
+		n = len(req.rawData)
+	}
+
+	rawData = req.rawData[0:n]
+	req.rawData = req.rawData[n:]
+	req.blockNumber += 1
+
+	return 0 == len(req.rawData), req.blockNumber, rawData
+}
+
+// Sets whether general block transfer may be used for requests, normally derived from negotiated conformance block.
+func (aconn *AppConn) setNegotiatedConformance(conformance []byte) {
+	aconn.gbt = conformanceBit(conformance, CONFORMANCE_GENERAL_BLOCK_TRANSFER)
+}
+
+func (aconn *AppConn) getGbtEndpoint() *tGbtEndpoint {
+	if nil == aconn.gbtEndpoint {
+		aconn.gbtEndpoint = newGbtEndpoint(
+			func(pdu []byte) error {
+				return aconn.dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, pdu)
+			},
+			func() ([]byte, error) {
+				return aconn.dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+			},
+			aconn.GbtWindowSize)
+	}
+	aconn.gbtEndpoint.setWindowSize(aconn.GbtWindowSize)
+	aconn.gbtEndpoint.blockTimeout = aconn.GbtBlockTimeout
+	return aconn.gbtEndpoint
+}
+
+// Returns error the hdlc link was reset with, association does not exist anymore and must be established again.
+func (aconn *AppConn) linkResetError() error {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+	return aconn.linkReset
+}
+
+// Remembers 'err' if it means that hdlc link was reset.
+func (aconn *AppConn) checkLinkReset(err error) error {
+	if e, ok := err.(*HdlcLinkResetError); ok {
+		aconn.mtx.Lock()
+		aconn.linkReset = e
+		aconn.mtx.Unlock()
+	}
+	return err
+}
+
+// Sends request pdu, using general block transfer if it was negotiated and pdu exceeds 'GbtBlockSize'.
+func (aconn *AppConn) sendPdu(pdu []byte) (err error) {
+	aconn.sendMtx.Lock()
+	defer aconn.sendMtx.Unlock()
+
+	err = aconn.linkResetError()
+	if nil != err {
+		return err
+	}
+	if aconn.gbt && (aconn.GbtBlockSize > 0) && (len(pdu) > aconn.GbtBlockSize) {
+		return aconn.checkLinkReset(aconn.getGbtEndpoint().sendApdu(pdu, aconn.GbtBlockSize))
+	}
+	return aconn.checkLinkReset(aconn.dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, pdu))
+}
+
+// Receives reply pdu, reassembling it if it is sent using general block transfer.
+func (aconn *AppConn) receivePdu() (pdu []byte, err error) {
+	pdu, err = aconn.dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+	if nil != err {
+		return nil, aconn.checkLinkReset(err)
+	}
+	if (len(pdu) > 0) && (0xE0 == pdu[0]) {
+		pdu, err = aconn.getGbtEndpoint().receiveApdu(pdu)
+		if ErrorBlockTimeout == err {
+			// block may still arrive and be mistaken for reply to next request
+			aconn.dconn.abort()
+			return nil, err
+		}
+		if nil != err {
+			return nil, aconn.checkLinkReset(err)
+		}
+	}
+	err = decodeErrorPdu(pdu)
+	if nil != err {
+		return nil, err
+	}
+	return pdu, nil
+}
+
+// Returns typed error if pdu is ExceptionResponse or ConfirmedServiceError, these carry no invoke id.
+func decodeErrorPdu(pdu []byte) (err error) {
+	if 0 == len(pdu) {
+		return nil
+	}
+	switch pdu[0] {
+	case 0xD8:
+		err, e := decode_ExceptionResponse(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return err
+		}
+		errorLog("%s", e)
+		return e
+	case 0x0E:
+		err, e := decode_ConfirmedServiceError(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return err
+		}
+		errorLog("%s", e)
+		return e
+	}
+	return nil
+}
+
 func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.Reader) error {
 
 	invokeId := uint8((p[2] & 0xF0) >> 4)
@@ -310,28 +921,18 @@ func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.R
 				return err
 			}
 
-			err = aconn.dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, buf.Bytes())
+			err = aconn.sendPdu(buf.Bytes())
 			if nil != err {
 				return err
 			}
 
-			pdu, err := aconn.dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+			pdu, err := aconn.receiveReply(invokeId)
 			if nil != err {
 				return err
 			}
 
-			buf = bytes.NewBuffer(pdu)
-
-			p = make([]byte, 3)
-			err = binary.Read(buf, binary.BigEndian, p)
+			err, p, buf = aconn.decodeReplyHeader(invokeId, pdu)
 			if nil != err {
-				errorLog("io.Read() failed: %v", err)
-				return err
-			}
-			invokeIdRcv := uint8((p[2] & 0xF0) >> 4)
-			if invokeIdRcv != invokeId {
-				err := fmt.Errorf("invoke ids differs: invokeId sent: %v, invokeId received: %v", invokeId, invokeIdRcv)
-				errorLog("%s", err)
 				return err
 			}
 
@@ -365,7 +966,7 @@ func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.R
 
 		// set next block
 
-		n := req.BlockSize
+		n := req.blockSize
 		if n > len(req.rawData) {
 			n = len(req.rawData)
 		}
@@ -393,29 +994,18 @@ func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.R
 		}
 		req.blockNumber += 1
 
-		err = aconn.dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, buf.Bytes())
+		err = aconn.sendPdu(buf.Bytes())
 		if nil != err {
 			return err
 		}
 
-		pdu, err := aconn.dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+		pdu, err := aconn.receiveReply(invokeId)
 		if nil != err {
 			return err
 		}
 
-		buf = bytes.NewBuffer(pdu)
-
-		p = make([]byte, 3)
-		err = binary.Read(buf, binary.BigEndian, p)
+		err, p, buf = aconn.decodeReplyHeader(invokeId, pdu)
 		if nil != err {
-			errorLog("io.Read() failed: %v", err)
-			aconn.Close()
-			return err
-		}
-		invokeIdRcv := uint8((p[2] & 0xF0) >> 4)
-		if invokeIdRcv != invokeId {
-			err := fmt.Errorf("invoke ids differs: invokeId sent: %v, invokeId received: %v", invokeId, invokeIdRcv)
-			errorLog("%s", err)
 			return err
 		}
 
@@ -473,6 +1063,76 @@ func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.R
 
 		return aconn.processActionResponseNormal(rips, r, nil)
 
+	} else if (0xC7 == p[0]) && (0x03 == p[1]) {
+		debugLog("processing ActionResponseWithList")
+
+		return aconn.processActionResponseWithList(rips, r, nil)
+
+	} else if (0xC7 == p[0]) && (0x02 == p[1]) {
+		debugLog("processing ActionResponseWithPblock")
+
+		err, lastBlock, blockNumber, rawData := decode_ActionResponseWithPblock(r)
+		if nil != err {
+			return err
+		}
+
+		if nil == rips[0].rawData {
+			rips[0].rawData = rawData
+		} else {
+			rips[0].rawData = append(rips[0].rawData, rawData...)
+		}
+
+		if lastBlock {
+			return aconn.processActionBlockResponse(rips, bytes.NewBuffer(rips[0].rawData), nil)
+		}
+
+		// request next response block
+
+		debugLog("requesting next response block after block %d", blockNumber)
+
+		buf := new(bytes.Buffer)
+		_, err = buf.Write([]byte{0xC3, 0x02, p[2]})
+		if nil != err {
+			return err
+		}
+		err = encode_ActionRequestNextPblock(buf, blockNumber)
+		if nil != err {
+			return err
+		}
+		return aconn.sendBlockRequest(rips, invokeId, buf.Bytes())
+
+	} else if (0xC7 == p[0]) && (0x04 == p[1]) {
+		debugLog("processing ActionResponseNextPblock")
+
+		req := rips[0].Req
+
+		err, blockNumber := decode_ActionResponseNextPblock(r)
+		if nil != err {
+			return err
+		}
+		if req.blockNumber != blockNumber {
+			err = fmt.Errorf("error occured receiving response block: received unexpected blockNumber: %d, invokeId: %d ", blockNumber, invokeId)
+			errorLog("%s", err)
+			return err
+		}
+
+		// send next parameters block
+
+		lastBlock, blockNumber, rawData := req.nextBlock()
+
+		debugLog("sending parameters block %d", blockNumber)
+
+		buf := new(bytes.Buffer)
+		_, err = buf.Write([]byte{0xC3, 0x06, p[2]})
+		if nil != err {
+			return err
+		}
+		err = encode_ActionRequestWithPblock(buf, lastBlock, blockNumber, rawData)
+		if nil != err {
+			return err
+		}
+		return aconn.sendBlockRequest(rips, invokeId, buf.Bytes())
+
 	} else {
 		err := fmt.Errorf("received pdu discarded due to unknown tag: % 02X % 02X", p[0], p[1])
 		errorLog("%s", err)
@@ -480,41 +1140,132 @@ func (aconn *AppConn) processReply(rips []*DlmsRequestResponse, p []byte, r io.R
 	}
 }
 
+/*
+Encodes method parameters sent using parameter block transfer. Single method
+parameters are encoded as data, parameters of list of methods are preceded by
+count of methods. Missing parameters are encoded as null-data.
+*/
+func encodeMethodParameters(vals []*DlmsRequest) (err error, rawData []byte) {
+	var buf bytes.Buffer
+	if len(vals) > 1 {
+		err = binary.Write(&buf, binary.BigEndian, uint8(len(vals)))
+		if nil != err {
+			errorLog("binary.Write() failed: %v", err)
+			return err, nil
+		}
+	}
+	for _, val := range vals {
+		methodParameters := val.MethodParameters
+		if nil == methodParameters {
+			methodParameters = new(DlmsData)
+		}
+		err = methodParameters.Encode(&buf)
+		if nil != err {
+			return err, nil
+		}
+	}
+	return nil, buf.Bytes()
+}
+
 func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultResponse, err error) {
 	debugLog("enter")
-	highPriority := true
-	invokeId := aconn.invokeId
 
 	if 0 == len(vals) {
 		return nil, nil
 	}
 
-	debugLog("invokeId %d\n", invokeId)
-	if invokeId > 0x0F {
+	if aconn.invokeId > 0x0F {
 		err := fmt.Errorf("invokeId exceeds limit")
 		errorLog("%s", err)
 		return nil, err
 	}
 
-	rips := make([]*DlmsRequestResponse, len(vals))
-	for i := 0; i < len(vals); i += 1 {
-		rip := new(DlmsRequestResponse)
-		rip.Req = vals[i]
+	_, invokeId := aconn.allocateInvokeId(context.Background())
+	defer aconn.freeInvokeId(invokeId)
 
-		rip.RequestSubmittedAt = time.Now()
-		rip.highPriority = highPriority
-		rips[i] = rip
+	return aconn.sendRequest(vals, invokeId)
+}
+
+/*
+Same as SendRequest() but request is cancelled when 'ctx' is done or, if
+'timeout' is > 0, when it does not complete in 'timeout' after it got its
+invoke id (waiting for other outstanding requests is not included). Cancelled
+request is abandoned, transport stays open and late reply is discarded.
+*/
+func (aconn *AppConn) sendRequestContext(ctx context.Context, vals []*DlmsRequest, timeout time.Duration) (err error, response DlmsResultResponse) {
+	if 0 == len(vals) {
+		return nil, nil
 	}
 
-	// build and forward pdu to transport
+	if aconn.invokeId > 0x0F {
+		err := fmt.Errorf("invokeId exceeds limit")
+		errorLog("%s", err)
+		return err, nil
+	}
 
-	var invokeIdAndPriority tDlmsInvokeIdAndPriority
-	if highPriority {
-		invokeIdAndPriority = tDlmsInvokeIdAndPriority((invokeId << 4) | 0x01)
-	} else {
-		invokeIdAndPriority = tDlmsInvokeIdAndPriority(invokeId << 4)
+	err, invokeId := aconn.allocateInvokeId(ctx)
+	if nil != err {
+		return err, nil
+	}
+	defer aconn.freeInvokeId(invokeId)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		aconn.mtx.Lock()
+		aconn.pending[invokeId].ctx = ctx
+		aconn.mtx.Unlock()
+	}
+
+	response, err = aconn.sendRequest(vals, invokeId)
+	return err, response
+}
+
+func (aconn *AppConn) isReleased() bool {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+	return aconn.released
+}
+
+// Returns true if no request is outstanding.
+func (aconn *AppConn) idle() bool {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+	return 0 == len(aconn.pending)
+}
+
+/*
+Sends SET request without response to all meters on HDLC line (e.g. time
+synchronization of meters on RS-485 bus). Request is sent in UI frame
+addressed to all stations, meters send no reply so success of request is not
+known. Request is never sent in blocks.
+*/
+func (aconn *AppConn) BroadcastSetRequest(vals []*DlmsRequest) (err error) {
+	if 0 == len(vals) {
+		return nil
+	}
+	for _, val := range vals {
+		if nil == val.Data {
+			err = fmt.Errorf("missing data in broadcast set request")
+			errorLog("%s", err)
+			return err
+		}
+	}
+
+	vals[0].blockSize = 0
+	pdu, err := aconn.encodeRequest(vals, tDlmsInvokeIdAndPriority((aconn.invokeId<<4)|0x01))
+	if nil != err {
+		return err
 	}
 
+	aconn.sendMtx.Lock()
+	defer aconn.sendMtx.Unlock()
+	return aconn.dconn.transportBroadcast(pdu)
+}
+
+// Encodes request pdu, SET and ACTION requests are encoded as first block of block transfer if block size is set.
+func (aconn *AppConn) encodeRequest(vals []*DlmsRequest, invokeIdAndPriority tDlmsInvokeIdAndPriority) (pdu []byte, err error) {
 	buf := new(bytes.Buffer) // buffer for encoded application layer data pdu
 
 	// encode application layer data pdu
@@ -534,7 +1285,7 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 				if nil != err {
 					return nil, err
 				}
-			} else if vals[0].MethodId > 0 {
+			} else if (vals[0].MethodId > 0) && (0 == vals[0].blockSize) {
 
 				// action request normal
 				_, err = buf.Write([]byte{0xC3, 0x01, byte(invokeIdAndPriority)})
@@ -546,12 +1297,32 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 				if nil != err {
 					return nil, err
 				}
+			} else if vals[0].MethodId > 0 {
+
+				// action request with first pblock
+				_, err = buf.Write([]byte{0xC3, 0x04, byte(invokeIdAndPriority)})
+				if nil != err {
+					errorLog("buf.Write() failed: %v\n", err)
+					return nil, err
+				}
+
+				err, vals[0].rawData = encodeMethodParameters(vals)
+				if nil != err {
+					return nil, err
+				}
+				vals[0].blockNumber = 0
+				lastBlock, blockNumber, rawData := vals[0].nextBlock()
+
+				err = encode_ActionRequestWithFirstPblock(buf, vals[0].ClassId, vals[0].InstanceId, vals[0].MethodId, lastBlock, blockNumber, rawData)
+				if nil != err {
+					return nil, err
+				}
 			} else {
 				panic("assertion failed")
 			}
 
 		} else {
-			if 0 == vals[0].BlockSize {
+			if 0 == vals[0].blockSize {
 				_, err = buf.Write([]byte{0xC1, 0x01, byte(invokeIdAndPriority)})
 				if nil != err {
 					errorLog("buf.Write() failed: %v\n", err)
@@ -575,8 +1346,9 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 					return nil, err
 				}
 				vals[0].rawData = _buf.Bytes()
+				vals[0].blockNumber = 0
 
-				n := vals[0].BlockSize
+				n := vals[0].blockSize
 				if n > len(vals[0].rawData) {
 					n = len(vals[0].rawData)
 				}
@@ -601,7 +1373,50 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 
 	} else if len(vals) > 1 { // request with list
 
-		if nil == vals[0].Data {
+		if (0 == vals[0].AttributeId) && (vals[0].MethodId > 0) {
+			var (
+				classIds    []DlmsClassId  = make([]DlmsClassId, len(vals))
+				instanceIds []*DlmsOid     = make([]*DlmsOid, len(vals))
+				methodIds   []DlmsMethodId = make([]DlmsMethodId, len(vals))
+				parameters  []*DlmsData    = make([]*DlmsData, len(vals))
+			)
+			for i := 0; i < len(vals); i += 1 {
+				classIds[i] = vals[i].ClassId
+				instanceIds[i] = vals[i].InstanceId
+				methodIds[i] = vals[i].MethodId
+				parameters[i] = vals[i].MethodParameters
+			}
+			if 0 == vals[0].blockSize {
+				_, err = buf.Write([]byte{0xC3, 0x03, byte(invokeIdAndPriority)})
+				if nil != err {
+					errorLog("buf.Write() failed: %v\n", err)
+					return nil, err
+				}
+
+				err = encode_ActionRequestWithList(buf, classIds, instanceIds, methodIds, parameters)
+				if nil != err {
+					return nil, err
+				}
+			} else {
+				_, err = buf.Write([]byte{0xC3, 0x05, byte(invokeIdAndPriority)})
+				if nil != err {
+					errorLog("buf.Write() failed: %v\n", err)
+					return nil, err
+				}
+
+				err, vals[0].rawData = encodeMethodParameters(vals)
+				if nil != err {
+					return nil, err
+				}
+				vals[0].blockNumber = 0
+				lastBlock, blockNumber, rawData := vals[0].nextBlock()
+
+				err = encode_ActionRequestWithListAndFirstPblock(buf, classIds, instanceIds, methodIds, lastBlock, blockNumber, rawData)
+				if nil != err {
+					return nil, err
+				}
+			}
+		} else if nil == vals[0].Data {
 			_, err = buf.Write([]byte{0xC0, 0x03, byte(invokeIdAndPriority)})
 			if nil != err {
 				errorLog("buf.Write() failed: %v\n", err)
@@ -642,7 +1457,7 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 				accessParameters[i] = vals[i].AccessParameter
 				datas[i] = vals[i].Data
 			}
-			if 0 == vals[0].BlockSize {
+			if 0 == vals[0].blockSize {
 				_, err = buf.Write([]byte{0xC1, 0x04, byte(invokeIdAndPriority)})
 				if nil != err {
 					errorLog("buf.Write() failed: %v\n", err)
@@ -674,8 +1489,9 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 					}
 				}
 				vals[0].rawData = _buf.Bytes()
+				vals[0].blockNumber = 0
 
-				n := vals[0].BlockSize
+				n := vals[0].blockSize
 				if n > len(vals[0].rawData) {
 					n = len(vals[0].rawData)
 				}
@@ -701,11 +1517,45 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 		panic("assertion failed")
 	}
 
-	// send request
+	return buf.Bytes(), nil
+}
 
-	debugLog("send request")
+/*
+Returns block size for SET or ACTION request 'vals' if its encoded 'pdu' exceeds
+server max receive pdu size, 0 if request is sent without block transfer.
+*/
+func (aconn *AppConn) autoBlockSize(vals []*DlmsRequest, invokeIdAndPriority tDlmsInvokeIdAndPriority, pdu []byte) (blockSize int, err error) {
+	maxPduSize := int(aconn.serverMaxReceivePduSize) - aconn.dconn.cipherOverhead()
+	if (0 == aconn.serverMaxReceivePduSize) || (len(pdu) <= maxPduSize) {
+		return 0, nil
+	}
+	if (nil == vals[0].Data) && (vals[0].AttributeId > 0) {
+		// GetRequest cannot be sent in blocks
+		return 0, nil
+	}
+	if aconn.gbt && (aconn.GbtBlockSize > 0) && (len(pdu) > aconn.GbtBlockSize) {
+		// sent using general block transfer
+		return 0, nil
+	}
 
-	err = aconn.dconn.transportSend(aconn.applicationClient, aconn.logicalDevice, buf.Bytes())
+	// Find out length of block header by encoding block of length 1, length of raw data takes up to 2 more bytes in longer block.
+	vals[0].blockSize = 1
+	pdu, err = aconn.encodeRequest(vals, invokeIdAndPriority)
+	vals[0].blockSize = 0
+	if nil != err {
+		return 0, err
+	}
+	blockSize = maxPduSize - (len(pdu) - 1) - 2
+	if blockSize < 1 {
+		err = fmt.Errorf("server max receive pdu size too small: %d", aconn.serverMaxReceivePduSize)
+		errorLog("%s", err)
+		return 0, err
+	}
+	return blockSize, nil
+}
+
+func (aconn *AppConn) sendRequest(vals []*DlmsRequest, invokeId uint8) (response DlmsResultResponse, err error) {
+	err, rips := aconn.startRequest(vals, invokeId)
 	if nil != err {
 		return nil, err
 	}
@@ -714,23 +1564,75 @@ func (aconn *AppConn) SendRequest(vals []*DlmsRequest) (response DlmsResultRespo
 
 	debugLog("receive request")
 
-	pdu, err := aconn.dconn.transportReceive(aconn.logicalDevice, aconn.applicationClient)
+	pdu, err := aconn.receiveReply(invokeId)
 	if nil != err {
 		return nil, err
 	}
 
-	buf = bytes.NewBuffer(pdu)
+	return aconn.finishRequest(rips, invokeId, pdu)
+}
 
-	p := make([]byte, 3)
-	err = binary.Read(buf, binary.BigEndian, p)
+// Encodes and sends request 'invokeId'.
+func (aconn *AppConn) startRequest(vals []*DlmsRequest, invokeId uint8) (err error, rips []*DlmsRequestResponse) {
+	highPriority := true
+
+	debugLog("invokeId %d\n", invokeId)
+
+	rips = make([]*DlmsRequestResponse, len(vals))
+	for i := 0; i < len(vals); i += 1 {
+		rip := new(DlmsRequestResponse)
+		rip.Req = vals[i]
+
+		rip.RequestSubmittedAt = time.Now()
+		rip.highPriority = highPriority
+		rips[i] = rip
+	}
+
+	// build and forward pdu to transport
+
+	var invokeIdAndPriority tDlmsInvokeIdAndPriority
+	if highPriority {
+		invokeIdAndPriority = tDlmsInvokeIdAndPriority((invokeId << 4) | 0x01)
+	} else {
+		invokeIdAndPriority = tDlmsInvokeIdAndPriority(invokeId << 4)
+	}
+
+	// encode application layer data pdu
+
+	vals[0].blockSize = vals[0].BlockSize
+	pdu, err := aconn.encodeRequest(vals, invokeIdAndPriority)
 	if nil != err {
-		errorLog("io.Read() failed: %v", err)
-		return nil, err
+		return err, nil
 	}
-	invokeIdRcv := uint8((p[2] & 0xF0) >> 4)
-	if invokeIdRcv != invokeId {
-		err := fmt.Errorf("invoke ids differs: invokeId sent: %v, invokeId received: %v", invokeId, invokeIdRcv)
-		errorLog("%s", err)
+	if 0 == vals[0].blockSize {
+		vals[0].blockSize, err = aconn.autoBlockSize(vals, invokeIdAndPriority, pdu)
+		if nil != err {
+			return err, nil
+		}
+		if vals[0].blockSize > 0 {
+			debugLog("request exceeds server max receive pdu size, sending it in blocks of %d bytes", vals[0].blockSize)
+			pdu, err = aconn.encodeRequest(vals, invokeIdAndPriority)
+			if nil != err {
+				return err, nil
+			}
+		}
+	}
+
+	// send request
+
+	debugLog("send request")
+
+	err = aconn.sendPdu(pdu)
+	if nil != err {
+		return err, nil
+	}
+	return nil, rips
+}
+
+// Processes reply 'pdu' to request 'invokeId', remaining blocks of reply are requested and received.
+func (aconn *AppConn) finishRequest(rips []*DlmsRequestResponse, invokeId uint8, pdu []byte) (response DlmsResultResponse, err error) {
+	err, p, buf := aconn.decodeReplyHeader(invokeId, pdu)
+	if nil != err {
 		return nil, err
 	}
 
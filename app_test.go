@@ -2,7 +2,12 @@ package gocosem
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestApp_TcpConnect(t *testing.T) {
@@ -975,3 +980,675 @@ func TestApp_ActionRequestNormalWithReturnedValue(t *testing.T) {
 	}
 
 }
+
+func TestApp_ExceptionResponse(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+
+	mockCosemServer.setExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED, EXCEPTION_SERVICE_ERROR_OPERATION_NOT_POSSIBLE)
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	_, err = aconn.SendRequest([]*DlmsRequest{val})
+	var e *ExceptionResponseError
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED != e.StateError) || (EXCEPTION_SERVICE_ERROR_OPERATION_NOT_POSSIBLE != e.ServiceError) {
+		t.Fatalf("unexpected exception response: %s", e)
+	}
+}
+
+func TestApp_ConfirmedServiceError(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+
+	mockCosemServer.setConfirmedServiceError(CONFIRMED_SERVICE_READ, SERVICE_ERROR_ACCESS, 2)
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	_, err = aconn.SendRequest([]*DlmsRequest{val})
+	var e *ConfirmedServiceError
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (CONFIRMED_SERVICE_READ != e.Service) || (SERVICE_ERROR_ACCESS != e.Class) || (2 != e.Value) {
+		t.Fatalf("unexpected confirmed service error: %s", e)
+	}
+}
+
+func TestApp_ConfirmedServiceError_ciphered(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+
+	// cipher requests as if association was established using high level security, server replies unciphered
+	dconn.authenticationMechanismId = high_level_security_mechanism_using_GMAC
+	dconn.EK = testPushGlobalKey
+	dconn.AK = testPushAuthenticationKey
+	dconn.clientSystemTitle = []byte{0x4D, 0x4D, 0x4D, 0x00, 0x00, 0x00, 0x00, 0x01}
+	dconn.serverSystemTitle = testPushSystemTitle
+
+	mockCosemServer.setConfirmedServiceError(CONFIRMED_SERVICE_READ, SERVICE_ERROR_ACCESS, 2)
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	_, err = aconn.SendRequest([]*DlmsRequest{val})
+	var e *ConfirmedServiceError
+	if !errors.As(err, &e) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (CONFIRMED_SERVICE_READ != e.Service) || (SERVICE_ERROR_ACCESS != e.Class) || (2 != e.Value) {
+		t.Fatalf("unexpected confirmed service error: %s", e)
+	}
+}
+
+func TestApp_GeneralBlockTransfer_get(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+	mockCosemServer.gbtBlockLength = 10
+
+	data := (new(DlmsData))
+	data.SetOctetString(generateBytes(100))
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+	aconn.GbtWindowSize = 3
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	rep, err := aconn.SendRequest([]*DlmsRequest{val})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0 != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestApp_GeneralBlockTransfer_lostBlock(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+	mockCosemServer.gbtBlockLength = 10
+	mockCosemServer.gbtLostBlock = 3
+
+	data := (new(DlmsData))
+	data.SetOctetString(generateBytes(100))
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+	aconn.GbtWindowSize = 4
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	rep, err := aconn.SendRequest([]*DlmsRequest{val})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestApp_GeneralBlockTransfer_lostLastBlockOfWindow(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+	mockCosemServer.gbtBlockLength = 10
+	mockCosemServer.gbtLostBlock = 5 // first window is single block as server learns our window size from acknowledgement, block 5 ends second window
+
+	data := (new(DlmsData))
+	data.SetOctetString(generateBytes(100))
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+	aconn.GbtWindowSize = 4
+	aconn.GbtBlockTimeout = time.Millisecond * 100
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	rep, err := aconn.SendRequestContext(ctx, []*DlmsRequest{val})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestApp_GeneralBlockTransfer_set(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+	mockCosemServer.gbtWindowSize = 2
+
+	data := (new(DlmsData))
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+
+	// mock server AARE does not negotiate general block transfer
+	aconn.setNegotiatedConformance([]byte{0x20, 0x18, 0x1F})
+	aconn.GbtBlockSize = 8
+
+	data = (new(DlmsData))
+	data.SetOctetString(generateBytes(50))
+
+	val := new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	val.Data = data
+	rep, err := aconn.SendRequest([]*DlmsRequest{val})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0 != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+
+	val = new(DlmsRequest)
+	val.ClassId = 1
+	val.InstanceId = &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	val.AttributeId = 0x02
+	rep, err = aconn.SendRequest([]*DlmsRequest{val})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+// Server acknowledging first block of SetRequest and replying to second block with truncated SetResponseDataBlock.
+func serveTruncatedBlockReply(t *testing.T, ln net.Listener) {
+	conn, err := ln.Accept()
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+
+	_, src, dst, err := ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, c_TEST_AARE)
+	if nil != err {
+		return
+	}
+
+	pdu, _, _, err := ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	if (len(pdu) < 3) || (0xC1 != pdu[0]) || (0x02 != pdu[1]) {
+		t.Errorf("unexpected request: % 02X", pdu)
+		return
+	}
+	err = ipTransportSend(conn, dst, src, []byte{0xC5, 0x02, pdu[2], 0x00, 0x00, 0x00, 0x01})
+	if nil != err {
+		return
+	}
+	_, _, _, err = ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, []byte{0xC5, 0x02})
+	if nil != err {
+		return
+	}
+	// nothing more is expected, connection is aborted without RLRQ
+	pdu, _, _, err = ipTransportReceive(conn, nil, nil)
+	if nil == err {
+		t.Errorf("unexpected request: % 02X", pdu)
+	}
+}
+
+func TestApp_SetRequest_truncatedBlockReply(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go serveTruncatedBlockReply(t, ln)
+
+	dconn, err := TcpConnect("localhost", ln.Addr().(*net.TCPAddr).Port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(100))
+	start := time.Now()
+	_, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02, Data: data, BlockSize: 10},
+	})
+	if nil == err {
+		t.Fatalf("truncated reply accepted")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("request failure stalled: %v", time.Since(start))
+	}
+	if !dconn.isClosed() {
+		t.Fatalf("transport not aborted")
+	}
+}
+
+func serveTruncatedReply(t *testing.T, ln net.Listener) {
+	conn, err := ln.Accept()
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+
+	_, src, dst, err := ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, c_TEST_AARE)
+	if nil != err {
+		return
+	}
+
+	_, _, _, err = ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, []byte{0xC4, 0x01})
+	if nil != err {
+		return
+	}
+	// nothing more is expected, connection is aborted without RLRQ
+	pdu, _, _, err := ipTransportReceive(conn, nil, nil)
+	if nil == err {
+		t.Errorf("unexpected request: % 02X", pdu)
+	}
+}
+
+func TestApp_GetRequest_truncatedReply(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go serveTruncatedReply(t, ln)
+
+	dconn, err := TcpConnect("localhost", ln.Addr().(*net.TCPAddr).Port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	_, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+	})
+	if nil == err {
+		t.Fatalf("truncated reply accepted")
+	}
+	if !dconn.isClosed() {
+		t.Fatalf("transport not aborted")
+	}
+	aconn.Close()
+}
+
+func TestApp_Udp_GetRequestNormal(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+	mockCosemServer.blockLength = 100
+
+	data := (new(DlmsData))
+	data.SetOctetString(generateBytes(1000))
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := UdpConnect("localhost", 4059, time.Duration(1)*time.Second, 3)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("transport connected")
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("application connected")
+	defer aconn.Close()
+
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0 != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+// Udp server answering AARQ and GetRequests after 'delay', reply data is third byte of requested instance id.
+func serveUdpReplies(t *testing.T, conn *net.UDPConn, delay time.Duration) {
+	p := make([]byte, udpMaxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(p)
+		if nil != err {
+			return
+		}
+		pdu, src, dst, err := ipTransportReceive(bytes.NewReader(p[0:n]), nil, nil)
+		if nil != err {
+			t.Errorf("%s\n", err)
+			return
+		}
+		var reply []byte
+		if 0x60 == pdu[0] {
+			reply = c_TEST_AARE
+		} else if (len(pdu) >= 13) && (0xC0 == pdu[0]) && (0x01 == pdu[1]) {
+			reply = []byte{0xC4, 0x01, pdu[2], 0x00, 0x11, pdu[7]}
+		} else {
+			t.Errorf("unexpected request: % 02X", pdu)
+			return
+		}
+		time.Sleep(delay)
+		err, wpdu := makeWpdu(dst, src, reply)
+		if nil != err {
+			return
+		}
+		_, err = conn.WriteToUDP(wpdu, addr)
+		if nil != err {
+			return
+		}
+	}
+}
+
+func TestApp_Udp_concurrent(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer conn.Close()
+	go serveUdpReplies(t, conn, time.Duration(10)*time.Millisecond)
+
+	dconn, err := UdpConnect("localhost", conn.LocalAddr().(*net.UDPAddr).Port, time.Duration(1)*time.Second, 2)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	// requests are serialized over udp regardless of MaxOutstanding, sending one must not discard reply to another
+	aconn.MaxOutstanding = 4
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(c byte) {
+			defer wg.Done()
+			rep, err := aconn.SendRequest([]*DlmsRequest{
+				&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, c, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+			})
+			if nil != err {
+				t.Errorf("%s\n", err)
+				return
+			}
+			if c != rep.DataAt(0).GetUnsigned() {
+				t.Errorf("value differs: %d, expected: %d", rep.DataAt(0).GetUnsigned(), c)
+			}
+		}(byte(0x2A + i))
+	}
+	wg.Wait()
+}
+
+func TestApp_Udp_retransmission(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	data := (new(DlmsData))
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	dconn, err := UdpConnect("localhost", 4059, time.Duration(100)*time.Millisecond, 2)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	// lost AARQ is sent again
+	mockCosemServer.connections_mtx.Lock()
+	mockCosemServer.udpDropDatagrams = 1
+	mockCosemServer.connections_mtx.Unlock()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	vals := []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+	}
+
+	mockCosemServer.connections_mtx.Lock()
+	mockCosemServer.udpDropDatagrams = 2
+	mockCosemServer.connections_mtx.Unlock()
+
+	rep, err := aconn.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	mockCosemServer.connections_mtx.Lock()
+	mockCosemServer.udpDropDatagrams = 3
+	mockCosemServer.connections_mtx.Unlock()
+
+	_, err = aconn.SendRequest(vals)
+	if ErrDlmsTimeout != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Server receiving two GetRequests before replying to them in reverse order, reply data is third byte of requested instance id.
+func serveReversedReplies(t *testing.T, ln net.Listener) {
+	conn, err := ln.Accept()
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+
+	_, src, dst, err := ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, c_TEST_AARE)
+	if nil != err {
+		return
+	}
+
+	var pdus [][]byte
+	for i := 0; i < 2; i++ {
+		pdu, _, _, err := ipTransportReceive(conn, nil, nil)
+		if nil != err {
+			return
+		}
+		if (len(pdu) < 13) || (0xC0 != pdu[0]) || (0x01 != pdu[1]) {
+			t.Errorf("unexpected request: % 02X", pdu)
+			return
+		}
+		pdus = append(pdus, pdu)
+	}
+	for i := len(pdus) - 1; i >= 0; i-- {
+		reply := []byte{0xC4, 0x01, pdus[i][2], 0x00, 0x11, pdus[i][7]}
+		err = ipTransportSend(conn, dst, src, reply)
+		if nil != err {
+			return
+		}
+	}
+}
+
+func TestApp_SendRequest_pipelined(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go serveReversedReplies(t, ln)
+
+	dconn, err := TcpConnect("localhost", ln.Addr().(*net.TCPAddr).Port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn.MaxOutstanding = 2
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(c byte) {
+			defer wg.Done()
+			rep, err := aconn.SendRequest([]*DlmsRequest{
+				&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, c, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+			})
+			if nil != err {
+				t.Errorf("%s\n", err)
+				return
+			}
+			if c != rep.DataAt(0).GetUnsigned() {
+				t.Errorf("reply matched to wrong request: %d, expected: %d", rep.DataAt(0).GetUnsigned(), c)
+			}
+		}(byte(0x2A + i))
+	}
+	wg.Wait()
+}
@@ -800,7 +800,7 @@ func der_decode_chunk(r io.Reader) (err error, _ch *t_der_chunk) {
 		return err, nil
 	}
 	ch.content = make([]byte, length)
-	_, err = r.Read(ch.content)
+	_, err = io.ReadFull(r, ch.content)
 	if nil != err {
 		errorLog("io.Read(): %v", err)
 		return err, nil
@@ -3258,3 +3258,180 @@ func decode_AAREapdu(r io.Reader) (err error, aare *AAREapdu) {
 
 	return err, aare
 }
+
+//RLRQ-apdu ::= [APPLICATION 2] IMPLICIT SEQUENCE
+type RLRQapdu struct {
+	//reason [0] IMPLICIT Release-request-reason OPTIONAL,
+	reason *tAsn1Integer
+
+	//user-information [30] EXPLICIT Association-information OPTIONAL
+	userInformation *tAsn1OctetString
+}
+
+//RLRE-apdu ::= [APPLICATION 3] IMPLICIT SEQUENCE
+type RLREapdu struct {
+	//reason [0] IMPLICIT Release-response-reason OPTIONAL,
+	reason *tAsn1Integer
+
+	//user-information [30] EXPLICIT Association-information OPTIONAL
+	userInformation *tAsn1OctetString
+}
+
+// Release-request-reason
+const (
+	RLRQ_REASON_NORMAL       = tAsn1Integer(0)
+	RLRQ_REASON_URGENT       = tAsn1Integer(1)
+	RLRQ_REASON_USER_DEFINED = tAsn1Integer(30)
+)
+
+// Release-response-reason
+const (
+	RLRE_REASON_NORMAL       = tAsn1Integer(0)
+	RLRE_REASON_NOT_FINISHED = tAsn1Integer(1)
+	RLRE_REASON_USER_DEFINED = tAsn1Integer(30)
+)
+
+// RLRQ and RLRE differ only in application tag.
+func encode_releaseApdu(w io.Writer, tag uint32, reason *tAsn1Integer, userInformation *tAsn1OctetString) (err error) {
+	var ch, ch1, chApdu *t_der_chunk
+	var buf, bufApdu *bytes.Buffer
+
+	chApdu = new(t_der_chunk)
+	chApdu.asn1_class = ASN1_CLASS_APPLICATION
+	chApdu.encoding = BER_ENCODING_CONSTRUCTED
+	chApdu.asn1_tag = tag
+
+	bufApdu = new(bytes.Buffer)
+
+	// reason [0] IMPLICIT Release-request-reason OPTIONAL,
+
+	if nil != reason {
+		ch = new(t_der_chunk)
+		ch.asn1_class = ASN1_CLASS_CONTEXT_SPECIFIC
+		ch.encoding = BER_ENCODING_PRIMITIVE
+		ch.asn1_tag = 0
+		err, ch.content = der_encode_Integer(*reason)
+		if nil != err {
+			return err
+		}
+
+		err = der_encode_chunk(bufApdu, ch)
+		if nil != err {
+			return err
+		}
+	}
+
+	// user-information [30] EXPLICIT Association-information OPTIONAL
+
+	if nil != userInformation {
+		ch = new(t_der_chunk)
+		ch.asn1_class = ASN1_CLASS_CONTEXT_SPECIFIC
+		ch.encoding = BER_ENCODING_CONSTRUCTED
+		ch.asn1_tag = 30
+
+		ch1 = new(t_der_chunk)
+		ch1.asn1_class = ASN1_CLASS_UNIVERSAL
+		ch1.encoding = BER_ENCODING_PRIMITIVE
+		ch1.asn1_tag = 4
+		octetString := ([]uint8)(*userInformation)
+		ch1.content = make([]uint8, len(octetString))
+		copy(ch1.content, octetString)
+
+		buf = new(bytes.Buffer)
+		err = der_encode_chunk(buf, ch1)
+		if nil != err {
+			return err
+		}
+
+		ch.content = buf.Bytes()
+
+		err = der_encode_chunk(bufApdu, ch)
+		if nil != err {
+			return err
+		}
+	}
+
+	chApdu.content = bufApdu.Bytes()
+
+	err = der_encode_chunk(w, chApdu)
+	if nil != err {
+		return err
+	}
+
+	return nil
+}
+
+func decode_releaseApdu(r io.Reader, tag uint32) (err error, reason *tAsn1Integer, userInformation *tAsn1OctetString) {
+	err, ch := der_decode_chunk(r)
+	if nil != err {
+		return err, nil, nil
+	}
+	if (ASN1_CLASS_APPLICATION != ch.asn1_class) || (tag != ch.asn1_tag) {
+		err = fmt.Errorf("decoding error")
+		errorLog("%v", err)
+		return err, nil, nil
+	}
+
+	content := bytes.NewReader(ch.content)
+	for content.Len() > 0 {
+		err, ch = der_decode_chunk(content)
+		if nil != err {
+			return err, nil, nil
+		}
+
+		switch ch.asn1_tag {
+		case 0:
+			// reason [0] IMPLICIT Release-request-reason OPTIONAL,
+			err, _reason := der_decode_Integer(ch.content)
+			if nil != err {
+				return err, nil, nil
+			}
+			reason = &_reason
+		case 30:
+			// user-information [30] EXPLICIT Association-information OPTIONAL
+			err, ch = der_decode_chunk(bytes.NewReader(ch.content))
+			if nil != err {
+				return err, nil, nil
+			}
+			if 4 != ch.asn1_tag {
+				err = fmt.Errorf("decoding error")
+				errorLog("%v", err)
+				return err, nil, nil
+			}
+			octetString := make([]uint8, len(ch.content))
+			copy(octetString, ch.content)
+			userInformation = (*tAsn1OctetString)(&octetString)
+		default:
+			err = fmt.Errorf("decoding error")
+			errorLog("%v", err)
+			return err, nil, nil
+		}
+	}
+	return nil, reason, userInformation
+}
+
+func encode_RLRQapdu(w io.Writer, rlrq *RLRQapdu) (err error) {
+	if nil == rlrq {
+		return nil
+	}
+	return encode_releaseApdu(w, 2, rlrq.reason, rlrq.userInformation)
+}
+
+func decode_RLRQapdu(r io.Reader) (err error, rlrq *RLRQapdu) {
+	rlrq = new(RLRQapdu)
+	err, rlrq.reason, rlrq.userInformation = decode_releaseApdu(r, 2)
+	return err, rlrq
+}
+
+func encode_RLREapdu(w io.Writer, rlre *RLREapdu) (err error) {
+	if nil == rlre {
+		return nil
+	}
+	return encode_releaseApdu(w, 3, rlre.reason, rlre.userInformation)
+}
+
+func decode_RLREapdu(r io.Reader) (err error, rlre *RLREapdu) {
+	rlre = new(RLREapdu)
+	err, rlre.reason, rlre.userInformation = decode_releaseApdu(r, 3)
+	return err, rlre
+}
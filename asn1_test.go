@@ -784,3 +784,55 @@ func TestAsn1_decode_AAREapdu_for_hdlc_meter_with_sec_5(t *testing.T) {
 	}
 
 }
+
+func TestAsn1_encode_RLRQapdu(t *testing.T) {
+	var buf bytes.Buffer
+
+	rlrq := new(RLRQapdu)
+	reason := RLRQ_REASON_NORMAL
+	rlrq.reason = &reason
+	err := encode_RLRQapdu(&buf, rlrq)
+	if nil != err {
+		t.Fatalf("encode_RLRQapdu() failed")
+	}
+	if !byteEquals(t, buf.Bytes(), []byte{0x62, 0x03, 0x80, 0x01, 0x00}, true) {
+		t.Fatalf("bytes don't match")
+	}
+
+	buf.Reset()
+	userInformation := tAsn1OctetString([]byte{0x01, 0x00, 0x00, 0x00, 0x06, 0x5F, 0x1F, 0x04, 0x00, 0x00, 0x1E, 0x1D, 0xFF, 0xFF})
+	rlrq.userInformation = &userInformation
+	err = encode_RLRQapdu(&buf, rlrq)
+	if nil != err {
+		t.Fatalf("encode_RLRQapdu() failed")
+	}
+	if !byteEquals(t, buf.Bytes(), []byte{0x62, 0x15, 0x80, 0x01, 0x00, 0xBE, 0x10, 0x04, 0x0E, 0x01, 0x00, 0x00, 0x00, 0x06, 0x5F, 0x1F, 0x04, 0x00, 0x00, 0x1E, 0x1D, 0xFF, 0xFF}, true) {
+		t.Fatalf("bytes don't match")
+	}
+}
+
+func TestAsn1_decode_RLREapdu(t *testing.T) {
+	err, rlre := decode_RLREapdu(bytes.NewReader([]byte{0x63, 0x03, 0x80, 0x01, 0x00}))
+	if nil != err {
+		t.Fatalf("decode_RLREapdu() failed")
+	}
+	if (nil == rlre.reason) || (RLRE_REASON_NORMAL != *rlre.reason) {
+		t.Fatalf("rlre.reason don't match")
+	}
+	if nil != rlre.userInformation {
+		t.Fatalf("rlre.userInformation don't match")
+	}
+
+	err, rlre = decode_RLREapdu(bytes.NewReader([]byte{0x63, 0x00}))
+	if nil != err {
+		t.Fatalf("decode_RLREapdu() failed")
+	}
+	if nil != rlre.reason {
+		t.Fatalf("rlre.reason don't match")
+	}
+
+	err, rlre = decode_RLREapdu(bytes.NewReader([]byte{0x62, 0x03, 0x80, 0x01, 0x00}))
+	if nil == err {
+		t.Fatalf("RLRQ decoded as RLRE")
+	}
+}
@@ -0,0 +1,283 @@
+package gocosem
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type tAsyncRequest struct {
+	vals       []*DlmsRequest
+	timeout    time.Duration
+	callback   func(msg *DlmsMessage)
+	rips       []*DlmsRequestResponse // guarded by mtx
+	cancel     context.CancelFunc
+	timer      *time.Timer
+	processing bool // guarded by mtx, reply is being processed
+	finished   bool // guarded by mtx, request was removed from pending requests and its callback called
+}
+
+/*
+Sends request without blocking the caller. Exactly one message is delivered to
+returned channel when request completes: message 'Data' holds
+DlmsResultResponse (its DeliveredIn() tells request round trip time), 'Err' is
+set if request failed.
+
+If 'timeout' is > 0 and request does not complete in time, message with
+ErrorRequestTimeout is delivered. Timeout starts when request gets its invoke
+id, time request waits for other outstanding requests is not included. Only
+timed out request is abandoned, transport connection stays open and late reply
+is discarded.
+
+No goroutine waits for reply of particular request: request is registered
+under its invoke id and completed when its reply is dispatched. Requests
+waiting for free invoke id are queued and sent when outstanding requests
+complete.
+*/
+func (aconn *AppConn) SendRequestAsync(vals []*DlmsRequest, timeout time.Duration) DlmsReplyChannel {
+	ch := make(chan *DlmsMessage, 1)
+	aconn.SendRequestCallback(vals, timeout, func(msg *DlmsMessage) {
+		ch <- msg
+	})
+	return ch
+}
+
+/*
+Same as SendRequestAsync() but 'callback' is called with result message.
+Callback must not block, it is called from goroutine that completed the
+request: goroutine reading replies, request timer or, if request failed before
+it was sent, goroutine sending it (possibly the caller before
+SendRequestCallback() returns).
+*/
+func (aconn *AppConn) SendRequestCallback(vals []*DlmsRequest, timeout time.Duration, callback func(msg *DlmsMessage)) {
+	var rep DlmsResultResponse
+
+	if 0 == len(vals) {
+		callback(&DlmsMessage{Err: nil, Data: rep})
+		return
+	}
+
+	if aconn.invokeId > 0x0F {
+		err := fmt.Errorf("invokeId exceeds limit")
+		errorLog("%s", err)
+		callback(&DlmsMessage{Err: err, Data: rep})
+		return
+	}
+
+	aconn.mtx.Lock()
+	aconn.asyncQueue = append(aconn.asyncQueue, &tAsyncRequest{vals: vals, timeout: timeout, callback: callback})
+	aconn.mtx.Unlock()
+
+	aconn.startAsync()
+}
+
+// Sends queued async requests while invoke ids are free.
+func (aconn *AppConn) startAsync() {
+	for {
+		aconn.mtx.Lock()
+		if (0 == len(aconn.asyncQueue)) || aconn.exclusive || (len(aconn.pending) >= aconn.maxOutstanding()) {
+			aconn.mtx.Unlock()
+			return
+		}
+		areq := aconn.asyncQueue[0]
+		aconn.asyncQueue[0] = nil
+		aconn.asyncQueue = aconn.asyncQueue[1:]
+		var ctx context.Context
+		ctx, areq.cancel = context.WithCancel(context.Background())
+		invokeId := aconn.takeInvokeId(ctx)
+		aconn.pending[invokeId].async = areq
+		if areq.timeout > 0 {
+			areq.timer = time.AfterFunc(areq.timeout, func() {
+				aconn.timeoutAsync(invokeId, areq)
+			})
+		}
+		aconn.mtx.Unlock()
+
+		err, rips := aconn.startRequest(areq.vals, invokeId)
+		if nil != err {
+			aconn.finishAsync(invokeId, areq, err, nil)
+			continue
+		}
+		aconn.mtx.Lock()
+		areq.rips = rips
+		if !aconn.asyncReading {
+			aconn.asyncReading = true
+			go aconn.receiveAsync()
+		}
+		aconn.mtx.Unlock()
+	}
+}
+
+// Returns true if some async request waits for its reply, must be called with 'mtx' held.
+func (aconn *AppConn) awaitingAsync() bool {
+	for _, req := range aconn.pending {
+		if (nil != req.async) && !req.async.processing {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Reads transport while async requests wait for their replies. Replies are
+dispatched the same way receiveReply() dispatches them, async requests are
+completed here once their replies are received by this goroutine or handed
+over by another reader. Only one such goroutine runs per connection.
+*/
+func (aconn *AppConn) receiveAsync() {
+	for {
+		aconn.mtx.Lock()
+		if !aconn.awaitingAsync() {
+			aconn.asyncReading = false
+			aconn.mtx.Unlock()
+			return
+		}
+		aconn.mtx.Unlock()
+
+		select {
+		case invokeId := <-aconn.asyncReady:
+			aconn.completeAsync(invokeId)
+			continue
+		case <-aconn.receiveToken:
+		}
+		// reply may have been handed over by previous reader
+		select {
+		case invokeId := <-aconn.asyncReady:
+			aconn.receiveToken <- true
+			aconn.completeAsync(invokeId)
+			continue
+		default:
+		}
+		aconn.mtx.Lock()
+		awaiting := aconn.awaitingAsync()
+		aconn.mtx.Unlock()
+		if !awaiting {
+			aconn.receiveToken <- true
+			continue
+		}
+
+		var pdu []byte
+		err := aconn.linkResetError()
+		if nil == err {
+			pdu, err = aconn.receivePdu()
+		}
+		if nil != err {
+			aconn.receiveToken <- true
+			aconn.failAsync(err)
+			continue
+		}
+		if aconn.dispatchReply(0x10, pdu) {
+			aconn.handOverUnmatched(pdu)
+		}
+		aconn.receiveToken <- true
+	}
+}
+
+/*
+Hands reply not matching any outstanding request to the only pending request
+so that it reports the mismatch the same way it does when it reads the reply
+itself. Reply is discarded if more requests are pending.
+*/
+func (aconn *AppConn) handOverUnmatched(pdu []byte) {
+	aconn.mtx.Lock()
+	defer aconn.mtx.Unlock()
+
+	if 1 != len(aconn.pending) {
+		warnLog("discarding reply not matching any outstanding request")
+		return
+	}
+	for invokeId, req := range aconn.pending {
+		aconn.handOver(invokeId, req, pdu)
+	}
+}
+
+// Processes reply handed over to async request 'invokeId' and calls its callback.
+func (aconn *AppConn) completeAsync(invokeId uint8) {
+	aconn.mtx.Lock()
+	req := aconn.pending[invokeId]
+	if (nil == req) || (nil == req.async) || req.async.processing {
+		aconn.mtx.Unlock()
+		return
+	}
+	var pdu []byte
+	select {
+	case pdu = <-req.reply:
+	default:
+		aconn.mtx.Unlock()
+		return
+	}
+	areq := req.async
+	areq.processing = true
+	rips := areq.rips
+	aconn.mtx.Unlock()
+
+	// remaining blocks of reply are received synchronously, timeout cancels request context
+	rep, err := aconn.finishRequest(rips, invokeId, pdu)
+	if context.Canceled == err {
+		err = ErrorRequestTimeout
+	}
+	aconn.finishAsync(invokeId, areq, err, rep)
+}
+
+// Abandons async request 'invokeId' which did not complete in time.
+func (aconn *AppConn) timeoutAsync(invokeId uint8, areq *tAsyncRequest) {
+	aconn.mtx.Lock()
+	if areq.finished {
+		aconn.mtx.Unlock()
+		return
+	}
+	if areq.processing {
+		// completeAsync() waits for next block of reply, it fails with context.Canceled
+		aconn.mtx.Unlock()
+		areq.cancel()
+		return
+	}
+	select {
+	case <-aconn.pending[invokeId].reply:
+		// reply was received meanwhile, no other one will arrive
+	default:
+		aconn.abandoned[invokeId] = true
+	}
+	aconn.mtx.Unlock()
+
+	debugLog("request timed out, invokeId: %d", invokeId)
+	aconn.finishAsync(invokeId, areq, ErrorRequestTimeout, nil)
+}
+
+// Fails async requests waiting for reply if transport read failed.
+func (aconn *AppConn) failAsync(err error) {
+	aconn.mtx.Lock()
+	invokeIds := make([]uint8, 0, len(aconn.pending))
+	areqs := make([]*tAsyncRequest, 0, len(aconn.pending))
+	for invokeId, req := range aconn.pending {
+		if (nil != req.async) && !req.async.processing && (0 == len(req.reply)) {
+			invokeIds = append(invokeIds, invokeId)
+			areqs = append(areqs, req.async)
+		}
+	}
+	aconn.mtx.Unlock()
+
+	for i, areq := range areqs {
+		aconn.finishAsync(invokeIds[i], areq, err, nil)
+	}
+}
+
+// Frees invoke id of async request and calls its callback unless it was already called.
+func (aconn *AppConn) finishAsync(invokeId uint8, areq *tAsyncRequest, err error, rep DlmsResultResponse) {
+	aconn.mtx.Lock()
+	if areq.finished {
+		aconn.mtx.Unlock()
+		return
+	}
+	areq.finished = true
+	delete(aconn.pending, invokeId)
+	aconn.cond.Broadcast()
+	aconn.mtx.Unlock()
+
+	if nil != areq.timer {
+		areq.timer.Stop()
+	}
+	areq.cancel()
+	areq.callback(&DlmsMessage{Err: err, Data: rep})
+	aconn.startAsync()
+}
@@ -0,0 +1,150 @@
+package gocosem
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAsync_SendRequestAsync(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	for i := 0; i < 4; i++ {
+		data := new(DlmsData)
+		data.SetUnsigned(uint8(i))
+		srv.AddObject(1, &DlmsOid{0x00, 0x00, 0x2A, byte(i), 0x00, 0xFF}).SetAttribute(2, data)
+	}
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	chs := make([]DlmsReplyChannel, 4)
+	for i := 0; i < 4; i++ {
+		chs[i] = aconn.SendRequestAsync([]*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, byte(i), 0x00, 0xFF}, AttributeId: 2},
+		}, time.Duration(5)*time.Second)
+	}
+	for i, ch := range chs {
+		msg := <-ch
+		if nil != msg.Err {
+			t.Fatalf("%s\n", msg.Err)
+		}
+		rep := msg.Data.(DlmsResultResponse)
+		if uint8(i) != rep.DataAt(0).GetUnsigned() {
+			t.Fatalf("value differs: %d, expected: %d", rep.DataAt(0).GetUnsigned(), i)
+		}
+		if rep.DeliveredIn() <= 0 {
+			t.Fatalf("delivery time not set")
+		}
+	}
+}
+
+func TestAsync_SendRequestAsync_noGoroutinePerRequest(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(20)*time.Millisecond)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	n := runtime.NumGoroutine()
+	chs := make([]DlmsReplyChannel, 16)
+	for i := 0; i < len(chs); i++ {
+		chs[i] = aconn.SendRequestAsync([]*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+		}, time.Duration(5)*time.Second)
+	}
+	if runtime.NumGoroutine()-n >= len(chs)/2 {
+		t.Fatalf("goroutines started for %d outstanding requests: %d", len(chs), runtime.NumGoroutine()-n)
+	}
+	for _, ch := range chs {
+		msg := <-ch
+		if nil != msg.Err {
+			t.Fatalf("%s\n", msg.Err)
+		}
+		if 1 != msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned() {
+			t.Fatalf("value differs: %d, expected: 1", msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned())
+		}
+	}
+}
+
+func TestAsync_SendRequestCallback_timeout(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(2)*time.Second)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	ch := make(chan *DlmsMessage, 1)
+	start := time.Now()
+	aconn.SendRequestCallback([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	}, time.Duration(100)*time.Millisecond, func(msg *DlmsMessage) {
+		ch <- msg
+	})
+	msg := <-ch
+	if ErrorRequestTimeout != msg.Err {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("request not timed out in time: %v", time.Since(start))
+	}
+}
+
+func TestAsync_SendRequestAsync_timeoutOutstanding(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go serveLateReply(t, ln, time.Duration(200)*time.Millisecond)
+
+	dconn, err := TcpConnect("localhost", ln.Addr().(*net.TCPAddr).Port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn.MaxOutstanding = 2
+
+	slow := aconn.SendRequestAsync([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 2},
+	}, time.Duration(100)*time.Millisecond)
+	fast := aconn.SendRequestAsync([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2B, 0x00, 0x00, 0xFF}, AttributeId: 2},
+	}, time.Duration(5)*time.Second)
+
+	msg := <-slow
+	if ErrorRequestTimeout != msg.Err {
+		t.Fatalf("unexpected error: %v", msg.Err)
+	}
+	msg = <-fast
+	if nil != msg.Err {
+		t.Fatalf("%s\n", msg.Err)
+	}
+	if 0x2B != msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned() {
+		t.Fatalf("reply matched to wrong request: %d", msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned())
+	}
+
+	// late reply to timed out request is discarded, connection stays usable
+	time.Sleep(time.Duration(300) * time.Millisecond)
+	msg = <-aconn.SendRequestAsync([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}, AttributeId: 2},
+	}, time.Duration(5)*time.Second)
+	if nil != msg.Err {
+		t.Fatalf("%s\n", msg.Err)
+	}
+	if 0x2C != msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned() {
+		t.Fatalf("reply matched to wrong request: %d", msg.Data.(DlmsResultResponse).DataAt(0).GetUnsigned())
+	}
+}
@@ -0,0 +1,176 @@
+package gocosem
+
+import (
+	"context"
+)
+
+/*
+Context aware variants of connect, associate and request functions.
+
+If context is done while connecting or associating, transport connection is
+aborted (closed without sending DISC or RLRQ) as it is unknown how far the
+exchange got, blocked socket or HDLC reads return and function returns
+ctx.Err(). Connection must be established again.
+
+AppConn.SendRequestContext() cancels only its own request: the request is
+abandoned, its invoke id is not reused while other ids are free and its late
+reply is discarded. Transport connection is aborted only if no other request
+is outstanding. Any block transfer of cancelled request is abandoned.
+*/
+
+// Closes transport stream without disconnecting, makes blocked reads and writes return.
+func (dconn *DlmsConn) abort() {
+	dconn.closedMutex.Lock()
+	defer dconn.closedMutex.Unlock()
+	if dconn.closed {
+		return
+	}
+	debugLog("aborting transport connection")
+	switch dconn.transportType {
+	case Transport_TCP, Transport_UDP:
+		dconn.rwc.Close()
+	case Transport_HDLC:
+		dconn.hdlcRwc.Close()
+		if nil != dconn.HdlcClient {
+			dconn.HdlcClient.Close()
+		}
+	}
+	dconn.closed = true
+}
+
+// Runs 'f' doing transport i/o, transport is aborted if 'ctx' is done before 'f' returns.
+func (dconn *DlmsConn) doContext(ctx context.Context, f func() error) (err error) {
+	if nil == ctx.Done() {
+		return f()
+	}
+	err = ctx.Err()
+	if nil != err {
+		return err
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- f()
+	}()
+	select {
+	case err = <-ch:
+		return err
+	case <-ctx.Done():
+		warnLog("%v: aborting transport connection", ctx.Err())
+		dconn.abort()
+		<-ch
+		return ctx.Err()
+	}
+}
+
+func (dconn *DlmsConn) AppConnectWithPasswordContext(ctx context.Context, applicationClient uint16, logicalDevice uint16, invokeId uint8, password string) (aconn *AppConn, err error) {
+	err = dconn.doContext(ctx, func() (err error) {
+		aconn, err = dconn.AppConnectWithPassword(applicationClient, logicalDevice, invokeId, password)
+		return err
+	})
+	return aconn, err
+}
+
+func (dconn *DlmsConn) SNConnectWithPasswordContext(ctx context.Context, applicationClient uint16, logicalDevice uint16, password string) (snconn *SNConn, err error) {
+	err = dconn.doContext(ctx, func() (err error) {
+		snconn, err = dconn.SNConnectWithPassword(applicationClient, logicalDevice, password)
+		return err
+	})
+	return snconn, err
+}
+
+func (dconn *DlmsConn) AppConnectWithSecurity5Context(ctx context.Context, applicationClient uint16, logicalDevice uint16, invokeId uint8, authenticationKey []byte, encryptionKey []byte, applicationContextName []uint32, callingAPtitle []byte, clientToServerChallenge string, initiateRequest *DlmsInitiateRequest, sendFrameCounter uint32) (aconn *AppConn, initiateResponse *DlmsInitiateResponse, err error) {
+	err = dconn.doContext(ctx, func() (err error) {
+		aconn, initiateResponse, err = dconn.AppConnectWithSecurity5(applicationClient, logicalDevice, invokeId, authenticationKey, encryptionKey, applicationContextName, callingAPtitle, clientToServerChallenge, initiateRequest, sendFrameCounter)
+		return err
+	})
+	return aconn, initiateResponse, err
+}
+
+func (dconn *DlmsConn) AppConnectRawContext(ctx context.Context, applicationClient uint16, logicalDevice uint16, invokeId uint8, aarq []byte, aare []byte) (aconn *AppConn, err error) {
+	err = dconn.doContext(ctx, func() (err error) {
+		aconn, err = dconn.AppConnectRaw(applicationClient, logicalDevice, invokeId, aarq, aare)
+		return err
+	})
+	return aconn, err
+}
+
+func (dconn *DlmsConn) AppConnectContext(ctx context.Context, applicationClient uint16, logicalDevice uint16, invokeId uint8, aarq *AARQapdu) (aconn *AppConn, aare *AAREapdu, err error) {
+	err = dconn.doContext(ctx, func() (err error) {
+		aconn, aare, err = dconn.AppConnect(applicationClient, logicalDevice, invokeId, aarq)
+		return err
+	})
+	return aconn, aare, err
+}
+
+func (aconn *AppConn) SendRequestContext(ctx context.Context, vals []*DlmsRequest) (response DlmsResultResponse, err error) {
+	err = ctx.Err()
+	if nil != err {
+		return nil, err
+	}
+	err, response = aconn.sendRequestContext(ctx, vals, 0)
+	if nil != err {
+		if (err == ctx.Err()) && aconn.idle() {
+			warnLog("%v: no other request outstanding, aborting transport connection", err)
+			aconn.dconn.abort()
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+func (aconn *AppConn) ReleaseContext(ctx context.Context, initiateRequest *DlmsInitiateRequest) (initiateResponse *DlmsInitiateResponse, err error) {
+	err = aconn.dconn.doContext(ctx, func() (err error) {
+		initiateResponse, err = aconn.Release(initiateRequest)
+		return err
+	})
+	return initiateResponse, err
+}
+
+/*
+Same as SNConn.SendRequest() but returns ctx.Err() when 'ctx' is done. SN
+requests are serialized, if other requests are outstanding the cancelled one
+is finished in background and its reply discarded, transport is aborted only
+if no other request is outstanding.
+*/
+func (snconn *SNConn) SendRequestContext(ctx context.Context, vals []*DlmsSNRequest) (response DlmsSNResultResponse, err error) {
+	err = ctx.Err()
+	if nil != err {
+		return nil, err
+	}
+	if nil == ctx.Done() {
+		return snconn.SendRequest(vals)
+	}
+
+	var rep DlmsSNResultResponse
+	snconn.beginRequest()
+	ch := make(chan error, 1)
+	go func() {
+		defer snconn.endRequest()
+		var err error
+		rep, err = snconn.sendRequest(vals)
+		ch <- err
+	}()
+	select {
+	case err = <-ch:
+		if nil != err {
+			return nil, err
+		}
+		return rep, nil
+	case <-ctx.Done():
+		if snconn.othersOutstanding() {
+			warnLog("%v: other requests outstanding, request finishes in background", ctx.Err())
+			return nil, ctx.Err()
+		}
+		warnLog("%v: no other request outstanding, aborting transport connection", ctx.Err())
+		snconn.dconn.abort()
+		<-ch
+		return nil, ctx.Err()
+	}
+}
+
+func (snconn *SNConn) SendUnconfirmedWriteContext(ctx context.Context, vals []*DlmsSNRequest) (err error) {
+	return snconn.dconn.doContext(ctx, func() (err error) {
+		return snconn.SendUnconfirmedWrite(vals)
+	})
+}
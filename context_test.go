@@ -0,0 +1,362 @@
+package gocosem
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Stream delaying each read, makes block transfers slow.
+type tSlowConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (conn *tSlowConn) Read(p []byte) (n int, err error) {
+	time.Sleep(conn.delay)
+	return conn.Conn.Read(p)
+}
+
+func addSlowObject(srv *CosemServer, instanceId *DlmsOid, delay time.Duration) {
+	srv.AddObject(1, instanceId).SetAttributeGetter(2, func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (DlmsDataAccessResult, *DlmsData) {
+		time.Sleep(delay)
+		data := new(DlmsData)
+		data.SetUnsigned(1)
+		return dataAccessResult_success, data
+	})
+}
+
+func TestContext_SendRequestContext(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(2)*time.Second)
+	fastInstanceId := &DlmsOid{0x00, 0x00, 0x2B, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03})
+	srv.AddObject(1, fastInstanceId).SetAttribute(2, data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(5)*time.Second)
+	defer cancel()
+
+	dconn, err := TcpConnectContext(ctx, "localhost", port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPasswordContext(ctx, 01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	rep, err := aconn.SendRequestContext(ctx, []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: fastInstanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, time.Duration(100)*time.Millisecond)
+	defer timeoutCancel()
+	start := time.Now()
+	_, err = aconn.SendRequestContext(timeoutCtx, []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if context.DeadlineExceeded != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("request not aborted in time: %v", time.Since(start))
+	}
+
+	// transport was aborted
+	_, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: fastInstanceId, AttributeId: 2},
+	})
+	if nil == err {
+		t.Fatalf("request on aborted connection succeeded")
+	}
+}
+
+func TestContext_SendRequestContext_blockTransfer(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+	srv.BlockLength = 10
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(1000))
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	conn, err := net.Dial("tcp", (&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}).String())
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	dconn := new(DlmsConn)
+	dconn.transportType = Transport_TCP
+	dconn.rwc = &tSlowConn{conn, time.Duration(5) * time.Millisecond}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Duration(100) * time.Millisecond)
+		cancel()
+	}()
+	_, err = aconn.SendRequestContext(ctx, []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if context.Canceled != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = aconn.SendRequestContext(ctx, []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if context.Canceled != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestContext_HdlcConnectContext(t *testing.T) {
+	// peer accepting connection but never answering SNRM
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if nil == err {
+			defer conn.Close()
+			time.Sleep(time.Duration(2) * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(100)*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = HdlcConnectContext(ctx, "localhost", ln.Addr().(*net.TCPAddr).Port, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if context.DeadlineExceeded != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("connect not aborted in time: %v", time.Since(start))
+	}
+}
+
+func TestContext_Hdlc_SendRequestContext(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+	ln, err := srv.ListenHdlc("localhost:0", 1, 1, nil, nil)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(2)*time.Second)
+
+	dconn, err := HdlcConnect("localhost", ln.Addr().(*net.TCPAddr).Port, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(100)*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = aconn.SendRequestContext(ctx, []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if context.DeadlineExceeded != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("request not aborted in time: %v", time.Since(start))
+	}
+}
+
+// Replies to first two requests after 'delay', reply to request of object with C byte 0x2A is sent after another 'delay'. Following requests are replied immediately.
+func serveLateReply(t *testing.T, ln net.Listener, delay time.Duration) {
+	conn, err := ln.Accept()
+	if nil != err {
+		return
+	}
+	defer conn.Close()
+
+	_, src, dst, err := ipTransportReceive(conn, nil, nil)
+	if nil != err {
+		return
+	}
+	err = ipTransportSend(conn, dst, src, c_TEST_AARE)
+	if nil != err {
+		return
+	}
+
+	var replies [][]byte
+	for i := 0; ; i++ {
+		pdu, _, _, err := ipTransportReceive(conn, nil, nil)
+		if nil != err {
+			return
+		}
+		if (len(pdu) < 13) || (0xC0 != pdu[0]) || (0x01 != pdu[1]) {
+			t.Errorf("unexpected request: % 02X", pdu)
+			return
+		}
+		reply := []byte{0xC4, 0x01, pdu[2], 0x00, 0x11, pdu[7]}
+		if i >= 2 {
+			err = ipTransportSend(conn, dst, src, reply)
+			if nil != err {
+				return
+			}
+			continue
+		}
+		if 0x2A == pdu[7] {
+			replies = append(replies, reply)
+		} else {
+			replies = append([][]byte{reply}, replies...)
+		}
+		if 1 == i {
+			for _, reply := range replies {
+				time.Sleep(delay)
+				err = ipTransportSend(conn, dst, src, reply)
+				if nil != err {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestContext_SendRequestContext_outstanding(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer ln.Close()
+	go serveLateReply(t, ln, time.Duration(200)*time.Millisecond)
+
+	dconn, err := TcpConnect("localhost", ln.Addr().(*net.TCPAddr).Port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn.MaxOutstanding = 2
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(100)*time.Millisecond)
+		defer cancel()
+		_, err := aconn.SendRequestContext(ctx, []*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+		})
+		if context.DeadlineExceeded != err {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		rep, err := aconn.SendRequestContext(context.Background(), []*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2B, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+		})
+		if nil != err {
+			t.Errorf("%s\n", err)
+			return
+		}
+		if 0x2B != rep.DataAt(0).GetUnsigned() {
+			t.Errorf("reply matched to wrong request: %d", rep.DataAt(0).GetUnsigned())
+		}
+	}()
+	wg.Wait()
+
+	// late reply to cancelled request is discarded, connection stays usable
+	time.Sleep(time.Duration(300) * time.Millisecond)
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0x2C != rep.DataAt(0).GetUnsigned() {
+		t.Fatalf("reply matched to wrong request: %d", rep.DataAt(0).GetUnsigned())
+	}
+}
+
+func TestContext_SNConn_SendRequestContext_outstanding(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(300)*time.Millisecond)
+	srv.SetBaseName(instanceId, 0xFD00)
+	fastInstanceId := &DlmsOid{0x00, 0x00, 0x2B, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetUnsigned(0x2B)
+	srv.AddObject(1, fastInstanceId).SetAttribute(2, data)
+	srv.SetBaseName(fastInstanceId, 0xFE00)
+
+	dconn, snconn := connectCosemServerSN(t, port)
+	defer dconn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(100)*time.Millisecond)
+		defer cancel()
+		_, err := snconn.SendRequestContext(ctx, []*DlmsSNRequest{
+			&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2)},
+		})
+		if context.DeadlineExceeded != err {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(time.Duration(20) * time.Millisecond)
+		rep, err := snconn.SendRequestContext(context.Background(), []*DlmsSNRequest{
+			&DlmsSNRequest{VariableName: SNAttribute(0xFE00, 2)},
+		})
+		if nil != err {
+			t.Errorf("%s\n", err)
+			return
+		}
+		if 0x2B != rep.DataAt(0).GetUnsigned() {
+			t.Errorf("reply matched to wrong request: %d", rep.DataAt(0).GetUnsigned())
+		}
+	}()
+	wg.Wait()
+
+	// cancelled request did not abort transport
+	rep, err := snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFE00, 2)},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0x2B != rep.DataAt(0).GetUnsigned() {
+		t.Fatalf("value differs: %d", rep.DataAt(0).GetUnsigned())
+	}
+}
@@ -964,7 +964,16 @@ func (data *DlmsData) GetOctetString() []byte {
 }
 
 func (data *DlmsData) PrintOctetString() string {
-	return fmt.Sprintf("% 02X (OctetString)", data.GetOctetString())
+	b := data.GetOctetString()
+	if 6 == len(b) {
+		// logical name of known object
+		var oid DlmsOid
+		copy(oid[:], b)
+		if info := LookupOBIS(&oid); nil != info {
+			return fmt.Sprintf("% 02X (OctetString, %s %s)", b, oid.String(), info.Name)
+		}
+	}
+	return fmt.Sprintf("% 02X (OctetString)", b)
 }
 
 func (data *DlmsData) encodeOctetString(w io.Writer) (err error) {
@@ -2083,7 +2092,7 @@ func decode_getRequest(r io.Reader) (err error, classId DlmsClassId, instanceId
 	var _accessSelector DlmsAccessSelector = 0
 	var _accessParameters *DlmsData = nil
 
-	if 0 > accessSelection {
+	if 0 < accessSelection {
 		// access selection is true
 
 		err = binary.Read(r, binary.BigEndian, &_accessSelector)
@@ -2634,7 +2643,7 @@ func decode_setRequest(r io.Reader) (err error, classId DlmsClassId, instanceId
 	var _accessSelector DlmsAccessSelector = 0
 	var _accessParameters *DlmsData = nil
 
-	if 0 > accessSelection {
+	if 0 < accessSelection {
 		// access selection is true
 
 		err = binary.Read(r, binary.BigEndian, &_accessSelector)
@@ -3813,3 +3822,249 @@ func decode_ActionResponseWithList(r io.Reader) (err error, actionResults []Dlms
 	return err, actionResults, dataAccessResults, data
 
 }
+
+// ExceptionResponse state-error
+const (
+	EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED uint8 = 1
+	EXCEPTION_STATE_ERROR_SERVICE_UNKNOWN     uint8 = 2
+)
+
+// ExceptionResponse service-error
+const (
+	EXCEPTION_SERVICE_ERROR_OPERATION_NOT_POSSIBLE   uint8 = 1
+	EXCEPTION_SERVICE_ERROR_SERVICE_NOT_SUPPORTED    uint8 = 2
+	EXCEPTION_SERVICE_ERROR_OTHER_REASON             uint8 = 3
+	EXCEPTION_SERVICE_ERROR_PDU_TOO_LONG             uint8 = 4
+	EXCEPTION_SERVICE_ERROR_DECIPHERING_ERROR        uint8 = 5
+	EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR uint8 = 6
+)
+
+// ConfirmedServiceError service
+const (
+	CONFIRMED_SERVICE_INITIATE_ERROR         uint8 = 1
+	CONFIRMED_SERVICE_GET_STATUS             uint8 = 2
+	CONFIRMED_SERVICE_GET_NAME_LIST          uint8 = 3
+	CONFIRMED_SERVICE_GET_VARIABLE_ATTRIBUTE uint8 = 4
+	CONFIRMED_SERVICE_READ                   uint8 = 5
+	CONFIRMED_SERVICE_WRITE                  uint8 = 6
+)
+
+// ServiceError class
+const (
+	SERVICE_ERROR_APPLICATION_REFERENCE uint8 = 0
+	SERVICE_ERROR_HARDWARE_RESOURCE     uint8 = 1
+	SERVICE_ERROR_VDE_STATE_ERROR       uint8 = 2
+	SERVICE_ERROR_SERVICE               uint8 = 3
+	SERVICE_ERROR_DEFINITION            uint8 = 4
+	SERVICE_ERROR_ACCESS                uint8 = 5
+	SERVICE_ERROR_INITIATE              uint8 = 6
+	SERVICE_ERROR_LOAD_DATA_SET         uint8 = 7
+	SERVICE_ERROR_CHANGE_SCOPE          uint8 = 8
+	SERVICE_ERROR_TASK                  uint8 = 9
+	SERVICE_ERROR_OTHER                 uint8 = 10
+)
+
+type ExceptionResponseError struct {
+	StateError        uint8
+	ServiceError      uint8
+	InvocationCounter uint32 // valid only if 'ServiceError' is invocation-counter-error
+}
+
+func (e *ExceptionResponseError) Error() string {
+	if EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR == e.ServiceError {
+		return fmt.Sprintf("exception response: state error: %d, service error: %d, invocation counter: %d", e.StateError, e.ServiceError, e.InvocationCounter)
+	}
+	return fmt.Sprintf("exception response: state error: %d, service error: %d", e.StateError, e.ServiceError)
+}
+
+type ConfirmedServiceError struct {
+	Service uint8
+	Class   uint8
+	Value   uint8
+}
+
+func (e *ConfirmedServiceError) Error() string {
+	return fmt.Sprintf("confirmed service error: service: %d, class: %d, value: %d", e.Service, e.Class, e.Value)
+}
+
+func encode_ExceptionResponse(w io.Writer, e *ExceptionResponseError) (err error) {
+	err = binary.Write(w, binary.BigEndian, []byte{e.StateError, e.ServiceError})
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	if EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR == e.ServiceError {
+		err = binary.Write(w, binary.BigEndian, e.InvocationCounter)
+		if nil != err {
+			errorLog("binary.Write() failed, err: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func decode_ExceptionResponse(r io.Reader) (err error, e *ExceptionResponseError) {
+	p := make([]byte, 2)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, nil
+	}
+	e = new(ExceptionResponseError)
+	e.StateError = p[0]
+	e.ServiceError = p[1]
+	if EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR == e.ServiceError {
+		err = binary.Read(r, binary.BigEndian, &e.InvocationCounter)
+		if nil != err {
+			errorLog("binary.Read() failed, err: %v", err)
+			return err, nil
+		}
+	}
+	return nil, e
+}
+
+func encode_ConfirmedServiceError(w io.Writer, e *ConfirmedServiceError) (err error) {
+	err = binary.Write(w, binary.BigEndian, []byte{e.Service, e.Class, e.Value})
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func decode_ConfirmedServiceError(r io.Reader) (err error, e *ConfirmedServiceError) {
+	p := make([]byte, 3)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, nil
+	}
+	return nil, &ConfirmedServiceError{Service: p[0], Class: p[1], Value: p[2]}
+}
+
+// Conformance block bits (bit 0 is most significant bit of first byte).
+const (
+	CONFORMANCE_GENERAL_PROTECTION               = 1
+	CONFORMANCE_GENERAL_BLOCK_TRANSFER           = 2
+	CONFORMANCE_READ                             = 3
+	CONFORMANCE_WRITE                            = 4
+	CONFORMANCE_UNCONFIRMED_WRITE                = 5
+	CONFORMANCE_ATTRIBUTE0_SUPPORTED_WITH_SET    = 8
+	CONFORMANCE_PRIORITY_MGMT_SUPPORTED          = 9
+	CONFORMANCE_ATTRIBUTE0_SUPPORTED_WITH_GET    = 10
+	CONFORMANCE_BLOCK_TRANSFER_WITH_GET_OR_READ  = 11
+	CONFORMANCE_BLOCK_TRANSFER_WITH_SET_OR_WRITE = 12
+	CONFORMANCE_BLOCK_TRANSFER_WITH_ACTION       = 13
+	CONFORMANCE_MULTIPLE_REFERENCES              = 14
+	CONFORMANCE_INFORMATION_REPORT               = 15
+	CONFORMANCE_DATA_NOTIFICATION                = 16
+	CONFORMANCE_ACCESS                           = 17
+	CONFORMANCE_PARAMETERIZED_ACCESS             = 18
+	CONFORMANCE_GET                              = 19
+	CONFORMANCE_SET                              = 20
+	CONFORMANCE_SELECTIVE_ACCESS                 = 21
+	CONFORMANCE_EVENT_NOTIFICATION               = 22
+	CONFORMANCE_ACTION                           = 23
+)
+
+func conformanceBit(buf []byte, bit int) bool {
+	if (bit < 0) || (bit/8 >= len(buf)) {
+		return false
+	}
+	return 0 != buf[bit/8]&(0x80>>uint(bit%8))
+}
+
+// Sets or clears bit of proposed conformance block.
+func (req *DlmsInitiateRequest) ProposeConformance(bit int, on bool) {
+	for len(req.proposedConformance.buf) < 3 {
+		req.proposedConformance.buf = append(req.proposedConformance.buf, 0)
+	}
+	if on {
+		req.proposedConformance.buf[bit/8] |= 0x80 >> uint(bit%8)
+	} else {
+		req.proposedConformance.buf[bit/8] &^= 0x80 >> uint(bit%8)
+	}
+}
+
+func (rep *DlmsInitiateResponse) HasConformance(bit int) bool {
+	return conformanceBit(rep.negotiatedConformance.buf, bit)
+}
+
+/*
+General-Block-Transfer ::= SEQUENCE
+{
+	block-control Unsigned8, -- bit 7 last-block, bit 6 streaming, bits 0-5 window
+	block-number Unsigned16,
+	block-number-ack Unsigned16,
+	block-data OCTET STRING
+}
+*/
+
+func encode_GeneralBlockTransfer(w io.Writer, lastBlock bool, streaming bool, window uint8, blockNumber uint16, blockNumberAck uint16, blockData []byte) (err error) {
+	blockControl := window & 0x3F
+	if lastBlock {
+		blockControl |= 0x80
+	}
+	if streaming {
+		blockControl |= 0x40
+	}
+	err = binary.Write(w, binary.BigEndian, blockControl)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumber)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumberAck)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = encodeAxdrLength(w, uint16(len(blockData)))
+	if nil != err {
+		return err
+	}
+	_, err = w.Write(blockData)
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func decode_GeneralBlockTransfer(r io.Reader) (err error, lastBlock bool, streaming bool, window uint8, blockNumber uint16, blockNumberAck uint16, blockData []byte) {
+	var blockControl uint8
+	err = binary.Read(r, binary.BigEndian, &blockControl)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, false, false, 0, 0, 0, nil
+	}
+	lastBlock = 0 != blockControl&0x80
+	streaming = 0 != blockControl&0x40
+	window = blockControl & 0x3F
+
+	err = binary.Read(r, binary.BigEndian, &blockNumber)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, streaming, window, 0, 0, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &blockNumberAck)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, streaming, window, blockNumber, 0, nil
+	}
+	err, length := decodeAxdrLength(r)
+	if nil != err {
+		return err, lastBlock, streaming, window, blockNumber, blockNumberAck, nil
+	}
+	blockData = make([]byte, length)
+	err = binary.Read(r, binary.BigEndian, blockData)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, streaming, window, blockNumber, blockNumberAck, nil
+	}
+	return nil, lastBlock, streaming, window, blockNumber, blockNumberAck, blockData
+}
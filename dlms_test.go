@@ -1861,3 +1861,54 @@ func TestDlms_decode_ActionResponseWithList(t *testing.T) {
 		t.Fatalf("data does not match")
 	}
 }
+
+func TestDlms_encode_GeneralBlockTransfer(t *testing.T) {
+	b := []byte{0xC3, 0x00, 0x05, 0x00, 0x02, 0x03, 0x01, 0x02, 0x03}
+
+	var buf bytes.Buffer
+	err := encode_GeneralBlockTransfer(&buf, true, true, 3, 5, 2, []byte{0x01, 0x02, 0x03})
+	if nil != err {
+		t.Fatalf("encode_GeneralBlockTransfer() failed, err: %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("bytes don't match")
+	}
+}
+
+func TestDlms_decode_GeneralBlockTransfer(t *testing.T) {
+	pdu := []byte{0x41, 0x00, 0x05, 0x00, 0x02, 0x03, 0x01, 0x02, 0x03}
+	buf := bytes.NewBuffer(pdu)
+
+	err, lastBlock, streaming, window, blockNumber, blockNumberAck, blockData := decode_GeneralBlockTransfer(buf)
+	if nil != err {
+		t.Fatalf("decode_GeneralBlockTransfer() failed, err %v", err)
+	}
+
+	if lastBlock || !streaming || (1 != window) || (5 != blockNumber) || (2 != blockNumberAck) {
+		t.Fatalf("block control or numbers wrong")
+	}
+	if !bytes.Equal(blockData, []byte{0x01, 0x02, 0x03}) {
+		t.Fatalf("blockData value wrong: % 02X", blockData)
+	}
+}
+
+func TestDlms_InitiateRequest_ProposeConformance(t *testing.T) {
+	req := new(DlmsInitiateRequest)
+	req.ProposeConformance(CONFORMANCE_GENERAL_BLOCK_TRANSFER, true)
+	req.ProposeConformance(CONFORMANCE_GET, true)
+	req.ProposeConformance(CONFORMANCE_ACTION, true)
+	if !bytes.Equal(req.proposedConformance.buf, []byte{0x20, 0x00, 0x11}) {
+		t.Fatalf("conformance block wrong: % 02X", req.proposedConformance.buf)
+	}
+	req.ProposeConformance(CONFORMANCE_GET, false)
+	if !bytes.Equal(req.proposedConformance.buf, []byte{0x20, 0x00, 0x01}) {
+		t.Fatalf("conformance block wrong: % 02X", req.proposedConformance.buf)
+	}
+
+	rep := new(DlmsInitiateResponse)
+	rep.negotiatedConformance.buf = []byte{0x20, 0x00, 0x01}
+	if !rep.HasConformance(CONFORMANCE_GENERAL_BLOCK_TRANSFER) || rep.HasConformance(CONFORMANCE_GET) {
+		t.Fatalf("conformance bits wrong")
+	}
+}
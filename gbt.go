@@ -0,0 +1,247 @@
+package gocosem
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Largest window size encodable in block-control.
+const gbtMaxWindowSize = 0x3F
+
+// How many times missing blocks are requested again before receiving is given up.
+const gbtBlockRetries = 3
+
+/*
+One side of general block transfer. Complete APDU is split into blocks
+numbered from 1. Sender sends up to peer's window of blocks with streaming
+flag set on all but last block of window and waits for acknowledgement of
+blocks received in sequence. Receiver acknowledges at the end of each window
+with number of last block received in sequence, so lost blocks are sent
+again. Blocks carrying no data are acknowledgements, their block number is
+number of last block sent by acknowledging side.
+
+Lost block at the end of window (or lost last block) leaves receiver waiting,
+so if no block arrives within 'blockTimeout' receiver acknowledges last block
+received in sequence again, requesting the rest of the window to be resent.
+First block of APDU is not requested again, request timeout applies to it.
+*/
+type tGbtEndpoint struct {
+	send    func(pdu []byte) error
+	receive func() (pdu []byte, err error)
+
+	windowSize   uint8             // own receive window advertised to peer
+	peerWindow   uint8             // receive window of peer
+	blockNumber  uint16            // number of last block sent
+	sent         [][]byte          // blocks of APDU being sent, kept for retransmission
+	blockTimeout time.Duration     // missing blocks are requested again after this time (never if 0)
+	received     chan tGbtReceived // result of receive still in progress when block timeout expired
+}
+
+type tGbtReceived struct {
+	pdu []byte
+	err error
+}
+
+func newGbtEndpoint(send func(pdu []byte) error, receive func() (pdu []byte, err error), windowSize uint8) (gbt *tGbtEndpoint) {
+	gbt = new(tGbtEndpoint)
+	gbt.send = send
+	gbt.receive = receive
+	gbt.setWindowSize(windowSize)
+	gbt.peerWindow = 1
+	return gbt
+}
+
+func (gbt *tGbtEndpoint) setWindowSize(windowSize uint8) {
+	if 0 == windowSize {
+		windowSize = 1
+	}
+	if windowSize > gbtMaxWindowSize {
+		windowSize = gbtMaxWindowSize
+	}
+	gbt.windowSize = windowSize
+}
+
+func (gbt *tGbtEndpoint) sendBlock(lastBlock bool, streaming bool, blockNumber uint16, blockNumberAck uint16, blockData []byte) (err error) {
+	var buf bytes.Buffer
+	buf.WriteByte(0xE0)
+	err = encode_GeneralBlockTransfer(&buf, lastBlock, streaming, gbt.windowSize, blockNumber, blockNumberAck, blockData)
+	if nil != err {
+		return err
+	}
+	return gbt.send(buf.Bytes())
+}
+
+// Sends one window of blocks starting at block 'first'. Returns true if last block was sent.
+func (gbt *tGbtEndpoint) sendWindow(first uint16) (lastBlockSent bool, err error) {
+	if (first < 1) || (int(first) > len(gbt.sent)) {
+		err = fmt.Errorf("general block transfer: invalid block number requested: %d", first)
+		errorLog("%s", err)
+		return false, err
+	}
+	window := gbt.peerWindow
+	if 0 == window {
+		window = 1
+	}
+	for i := 0; i < int(window); i++ {
+		blockNumber := first + uint16(i)
+		lastBlock := int(blockNumber) == len(gbt.sent)
+		streaming := !lastBlock && (i < int(window)-1)
+		err = gbt.sendBlock(lastBlock, streaming, blockNumber, 0, gbt.sent[blockNumber-1])
+		if nil != err {
+			return false, err
+		}
+		gbt.blockNumber = blockNumber
+		if lastBlock {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Sends APDU split into blocks of 'blockSize' bytes. Returns after last block was sent, retransmission requested afterwards is handled by receiveApdu().
+func (gbt *tGbtEndpoint) sendApdu(apdu []byte, blockSize int) (err error) {
+	gbt.sent = nil
+	for len(apdu) > blockSize {
+		gbt.sent = append(gbt.sent, apdu[0:blockSize])
+		apdu = apdu[blockSize:]
+	}
+	gbt.sent = append(gbt.sent, apdu)
+	debugLog("general block transfer: sending %d blocks", len(gbt.sent))
+
+	next := uint16(1)
+	for {
+		lastBlockSent, err := gbt.sendWindow(next)
+		if nil != err {
+			return err
+		}
+		if lastBlockSent {
+			return nil
+		}
+
+		pdu, err := gbt.receive()
+		if nil != err {
+			return err
+		}
+		err = decodeErrorPdu(pdu)
+		if nil != err {
+			return err
+		}
+		if (0 == len(pdu)) || (0xE0 != pdu[0]) {
+			err = fmt.Errorf("general block transfer: received unexpected pdu while sending blocks")
+			errorLog("%s", err)
+			return err
+		}
+		err, _, _, window, _, blockNumberAck, _ := decode_GeneralBlockTransfer(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return err
+		}
+		gbt.peerWindow = window
+		if blockNumberAck < next-1 {
+			err = fmt.Errorf("general block transfer: acknowledged block %d precedes already acknowledged block %d", blockNumberAck, next-1)
+			errorLog("%s", err)
+			return err
+		}
+		if blockNumberAck != gbt.blockNumber {
+			debugLog("general block transfer: peer acknowledged block %d, resending from block %d", blockNumberAck, blockNumberAck+1)
+		}
+		next = blockNumberAck + 1
+	}
+}
+
+// Receives APDU sent in blocks, 'pdu' is first received block.
+func (gbt *tGbtEndpoint) receiveApdu(pdu []byte) (apdu []byte, err error) {
+	var data bytes.Buffer
+	expected := uint16(1)
+
+	for {
+		if (0 == len(pdu)) || (0xE0 != pdu[0]) {
+			if 0 == data.Len() {
+				// peer replied with ordinary pdu after acknowledging all our blocks
+				gbt.sent = nil
+				return pdu, nil
+			}
+			err = fmt.Errorf("general block transfer: received unexpected pdu while receiving blocks")
+			errorLog("%s", err)
+			return nil, err
+		}
+		err, lastBlock, streaming, window, blockNumber, blockNumberAck, blockData := decode_GeneralBlockTransfer(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return nil, err
+		}
+		gbt.peerWindow = window
+
+		if (0 == len(blockData)) && !lastBlock {
+			// acknowledgement, peer requests blocks following 'blockNumberAck'
+			if (nil == gbt.sent) || (int(blockNumberAck) >= len(gbt.sent)) {
+				err = fmt.Errorf("general block transfer: unexpected acknowledgement of block %d", blockNumberAck)
+				errorLog("%s", err)
+				return nil, err
+			}
+			debugLog("general block transfer: resending from block %d", blockNumberAck+1)
+			_, err = gbt.sendWindow(blockNumberAck + 1)
+			if nil != err {
+				return nil, err
+			}
+		} else {
+			gbt.sent = nil
+			if blockNumber == expected {
+				data.Write(blockData)
+				expected += 1
+				if lastBlock {
+					debugLog("general block transfer: received %d blocks", expected-1)
+					return data.Bytes(), nil
+				}
+			} else {
+				debugLog("general block transfer: received block %d, expected block %d", blockNumber, expected)
+			}
+			if !streaming || lastBlock {
+				// end of window
+				err = gbt.sendBlock(false, false, gbt.blockNumber, expected-1, nil)
+				if nil != err {
+					return nil, err
+				}
+			}
+		}
+
+		err, pdu = gbt.receiveBlock(expected - 1)
+		if nil != err {
+			return nil, err
+		}
+	}
+}
+
+// Receives next block, if it does not arrive in time blocks following 'blockNumberAck' are requested again.
+func (gbt *tGbtEndpoint) receiveBlock(blockNumberAck uint16) (err error, pdu []byte) {
+	if 0 == gbt.blockTimeout {
+		pdu, err = gbt.receive()
+		return err, pdu
+	}
+	if nil == gbt.received {
+		received := make(chan tGbtReceived, 1)
+		gbt.received = received
+		go func() {
+			pdu, err := gbt.receive()
+			received <- tGbtReceived{pdu, err}
+		}()
+	}
+	for i := 0; ; i++ {
+		select {
+		case r := <-gbt.received:
+			gbt.received = nil
+			return r.err, r.pdu
+		case <-time.After(gbt.blockTimeout):
+		}
+		if i == gbtBlockRetries {
+			// receive still in progress is left to caller tearing down transport
+			gbt.received = nil
+			errorLog("general block transfer: no block received after block %d", blockNumberAck)
+			return ErrorBlockTimeout, nil
+		}
+		debugLog("general block transfer: no block received in time, requesting blocks following block %d again", blockNumberAck)
+		err = gbt.sendBlock(false, false, gbt.blockNumber, blockNumberAck, nil)
+		if nil != err {
+			return err, nil
+		}
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
-	"net"
 	"os"
 	"sync"
 	"time"
@@ -30,15 +29,16 @@ const (
 )
 
 const (
-	HDLC_CONTROL_I    = 1 // I frame
-	HDLC_CONTROL_RR   = 2 // response ready
-	HDLC_CONTROL_RNR  = 3 // response not ready
-	HDLC_CONTROL_SNRM = 4 // set normal response mode
-	HDLC_CONTROL_DISC = 5 // disconnect
-	HDLC_CONTROL_UA   = 6 // unnumbered acknowledgement
-	HDLC_CONTROL_DM   = 7 // disconnected mode
-	HDLC_CONTROL_FRMR = 8 // frame reject
-	HDLC_CONTROL_UI   = 9 // unnumbered information
+	HDLC_CONTROL_I    = 1  // I frame
+	HDLC_CONTROL_RR   = 2  // response ready
+	HDLC_CONTROL_RNR  = 3  // response not ready
+	HDLC_CONTROL_SNRM = 4  // set normal response mode
+	HDLC_CONTROL_DISC = 5  // disconnect
+	HDLC_CONTROL_UA   = 6  // unnumbered acknowledgement
+	HDLC_CONTROL_DM   = 7  // disconnected mode
+	HDLC_CONTROL_FRMR = 8  // frame reject
+	HDLC_CONTROL_UI   = 9  // unnumbered information
+	HDLC_CONTROL_REJ  = 10 // reject
 )
 
 type HdlcTransport struct {
@@ -47,6 +47,8 @@ type HdlcTransport struct {
 	modulus                    uint8
 	maxInfoFieldLengthTransmit uint16
 	maxInfoFieldLengthReceive  uint16
+	// Guards negotiated info field lengths and window sizes. They are written only by transport goroutine, other goroutines read them under lock.
+	linkParametersMtx *sync.Mutex
 
 	cosem         bool
 	cosemWaitTime time.Duration
@@ -73,10 +75,21 @@ type HdlcTransport struct {
 	controlQueueMtx *sync.Mutex
 	closedAck       chan map[string]interface{}
 
+	uiSendQueue       *list.List // list of *HdlcUIMessage to transmit in UI frames
+	uiSendQueueMtx    *sync.Mutex
+	uiSendAck         chan map[string]interface{}
+	uiReceiveQueue    *list.List // list of []byte received in UI frames, oldest first
+	uiReceiveQueueMtx *sync.Mutex
+	uiReceiveAck      chan map[string]interface{}
+
 	finishedCh chan bool
 
 	readFrameImpl int
 	frameNum      int
+
+	// Called by server when link is established by SNRM and after UA acknowledging DISC is sent. Both are called from transport goroutine and must not block.
+	linkConnected    func()
+	linkDisconnected func()
 }
 
 type HdlcClientConnection struct {
@@ -101,18 +114,48 @@ type HdlcFrame struct {
 	nr                    uint8 // N(R) - receive sequence number
 	ns                    uint8 // N(S) - send sequence number
 	control               int
+	controlField          byte   // control field as received, reported back in FRMR
 	fcs16                 uint16 // current fcs16 checksum
 	infoField             []byte // information
 	infoFieldFormat       uint8
 	callingPhysicalDevice bool
+	broadcast             bool // addressed to all stations
 	content               *bytes.Buffer
 }
 
+// Decoded FRMR information field.
+type HdlcFrmr struct {
+	RejectedControl byte  // control field of rejected frame
+	Vs              uint8 // V(S) of station rejecting the frame
+	Vr              uint8 // V(R) of station rejecting the frame
+	Response        bool  // rejected frame was a response
+	W               bool  // control field invalid or not implemented
+	X               bool  // information field not permitted with the frame
+	Y               bool  // information field exceeds max. info field length
+	Z               bool  // invalid N(R)
+}
+
+/*
+Returned by Read() and Write() of client HdlcTransport when link was reset
+after FRMR, unexpected DM or sequence error (and also by server's Write()
+when client reset the link). Data in transit are lost and COSEM association
+must be established again.
+*/
+type HdlcLinkResetError struct {
+	Reason string
+	Frmr   *HdlcFrmr // FRMR diagnostics if link was reset after receiving FRMR
+}
+
 type HdlcSegment struct {
 	p    []byte
 	last bool
 }
 
+type HdlcUIMessage struct {
+	p         []byte
+	broadcast bool
+}
+
 type HdlcControlCommand struct {
 	control int
 	snrm    *HdlcControlCommandSNRM
@@ -177,8 +220,14 @@ func pppfcs16(fcs16 uint16, p []byte) uint16 {
 	return fcs16
 }
 
+// Stream supporting read deadline, e.g. net.Conn or *os.File of serial port.
+type tReadDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
 func isTimeOutErr(err error) bool {
-	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+	// net.Error or *os.PathError of serial port
+	if nerr, ok := err.(interface{ Timeout() bool }); ok && nerr.Timeout() {
 		return true
 	} else {
 		return false
@@ -234,14 +283,40 @@ var HdlcErrorTransportClosed = errors.New("transport closed")
 
 var MaxInfoFieldLength = uint16(512)
 
+// Number of SNRM frames client transmits when re-establishing reset link before it gives up and stays disconnected.
+var HdlcLinkRecoveryAttempts = 10
+
+// FRMR information field diagnostic bits
+const (
+	HDLC_FRMR_W = 0x01
+	HDLC_FRMR_X = 0x02
+	HDLC_FRMR_Y = 0x04
+	HDLC_FRMR_Z = 0x08
+)
+
+// Window size proposed in SNRM and offered in UA, peer may negotiate it down, at most 7. Some meters handle windowing badly,
+// raise it only for meters known to support it.
+var HdlcWindowSize = uint32(1)
+
+// Number of received UI messages kept for ReadUI(), oldest are dropped when nobody reads them.
+const hdlcUIReceiveQueueLength = 100
+
 func NewHdlcTransport(rw io.ReadWriter, responseTimeout time.Duration, client bool, clientId uint8, logicalDeviceId uint16, physicalDeviceId *uint16, serverAddressLength *int) *HdlcTransport {
+	htran := newHdlcTransport(rw, responseTimeout, client, clientId, logicalDeviceId, physicalDeviceId, serverAddressLength)
+	go htran.handleHdlc()
+	return htran
+}
+
+// Same as NewHdlcTransport() but transport goroutine is not started so that caller may set hooks first.
+func newHdlcTransport(rw io.ReadWriter, responseTimeout time.Duration, client bool, clientId uint8, logicalDeviceId uint16, physicalDeviceId *uint16, serverAddressLength *int) *HdlcTransport {
 	htran := new(HdlcTransport)
 	htran.rw = rw
 	htran.modulus = 8
 	htran.maxInfoFieldLengthTransmit = MaxInfoFieldLength
 	htran.maxInfoFieldLengthReceive = MaxInfoFieldLength
-	htran.windowSizeTransmit = 1
-	htran.windowSizeReceive = 1
+	htran.linkParametersMtx = new(sync.Mutex)
+	htran.windowSizeTransmit = HdlcWindowSize
+	htran.windowSizeReceive = HdlcWindowSize
 
 	htran.writeQueue = list.New()
 	htran.writeQueueMtx = new(sync.Mutex)
@@ -255,6 +330,14 @@ func NewHdlcTransport(rw io.ReadWriter, responseTimeout time.Duration, client bo
 	htran.controlQueueMtx = new(sync.Mutex)
 	htran.controlAck = make(chan map[string]interface{}, 100)
 
+	htran.uiSendQueue = list.New()
+	htran.uiSendQueueMtx = new(sync.Mutex)
+	htran.uiSendAck = make(chan map[string]interface{}, 100)
+
+	htran.uiReceiveQueue = list.New()
+	htran.uiReceiveQueueMtx = new(sync.Mutex)
+	htran.uiReceiveAck = make(chan map[string]interface{}, 100)
+
 	htran.closedAck = make(chan map[string]interface{}, 100)
 	htran.finishedCh = make(chan bool)
 
@@ -283,10 +366,87 @@ func NewHdlcTransport(rw io.ReadWriter, responseTimeout time.Duration, client bo
 	}
 
 	htran.client = client
-	go htran.handleHdlc()
 	return htran
 }
 
+// Returns transport used only to read and write single frames on 'rw', no HDLC connection is maintained.
+func newHdlcFramer(rw io.ReadWriter, client bool, clientId uint8, logicalDeviceId uint16, physicalDeviceId *uint16, serverAddressLength int) *HdlcTransport {
+	htran := new(HdlcTransport)
+	htran.rw = rw
+	htran.modulus = 8
+	htran.maxInfoFieldLengthTransmit = MaxInfoFieldLength
+	htran.maxInfoFieldLengthReceive = MaxInfoFieldLength
+	htran.linkParametersMtx = new(sync.Mutex)
+	htran.client = client
+	htran.clientId = clientId
+	htran.logicalDeviceId = logicalDeviceId
+	htran.physicalDeviceId = physicalDeviceId
+	htran.serverAddrLength = serverAddressLength
+	return htran
+}
+
+/*
+Sets max. info field lengths from lengths 'maxInfoFieldLengthTransmit' and
+'maxInfoFieldLengthReceive' received from peer in SNRM or UA (nil if not
+present, 128 is assumed then). Peer's transmit length limits own receive length
+and vice versa.
+*/
+func (htran *HdlcTransport) negotiateInfoFieldLength(maxInfoFieldLengthTransmit *uint16, maxInfoFieldLengthReceive *uint16) {
+	limit := func(peerLength *uint16, length uint16) uint16 {
+		n := uint16(128)
+		if nil != peerLength {
+			n = *peerLength
+		}
+		if n > length {
+			n = length
+		}
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+	htran.linkParametersMtx.Lock()
+	htran.maxInfoFieldLengthTransmit = limit(maxInfoFieldLengthReceive, htran.maxInfoFieldLengthTransmit)
+	htran.maxInfoFieldLengthReceive = limit(maxInfoFieldLengthTransmit, htran.maxInfoFieldLengthReceive)
+	htran.linkParametersMtx.Unlock()
+}
+
+/*
+Sets window sizes from window sizes 'windowSizeTransmit' and 'windowSizeReceive'
+received from peer in SNRM or UA (nil if not present). Peer's transmit window
+limits own receive window and vice versa, window size is at least 1 and at most
+modulus-1.
+*/
+func (htran *HdlcTransport) negotiateWindowSize(windowSizeTransmit *uint32, windowSizeReceive *uint32) {
+	limit := func(peerWindowSize *uint32, windowSize uint32) uint32 {
+		n := uint32(1)
+		if nil != peerWindowSize {
+			n = *peerWindowSize
+		}
+		if n > windowSize {
+			n = windowSize
+		}
+		if n > uint32(htran.modulus-1) {
+			n = uint32(htran.modulus - 1)
+		}
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+	htran.linkParametersMtx.Lock()
+	htran.windowSizeTransmit = limit(windowSizeReceive, htran.windowSizeTransmit)
+	htran.windowSizeReceive = limit(windowSizeTransmit, htran.windowSizeReceive)
+	htran.linkParametersMtx.Unlock()
+}
+
+// Returns snapshot of currently negotiated link parameters, safe to call from any goroutine.
+func (htran *HdlcTransport) linkParameters() (maxInfoFieldLengthTransmit uint16, maxInfoFieldLengthReceive uint16, windowSizeTransmit uint32, windowSizeReceive uint32) {
+	htran.linkParametersMtx.Lock()
+	defer htran.linkParametersMtx.Unlock()
+	return htran.maxInfoFieldLengthTransmit, htran.maxInfoFieldLengthReceive, htran.windowSizeTransmit, htran.windowSizeReceive
+}
+
 func (htran *HdlcTransport) SetForCosem(cosemWaitTime time.Duration) {
 	htran.cosem = true
 	htran.cosemWaitTime = cosemWaitTime
@@ -299,21 +459,16 @@ func (htran *HdlcTransport) SendSNRM(maxInfoFieldLengthTransmit *uint16, maxInfo
 
 	command.snrm = new(HdlcControlCommandSNRM)
 
+	command.snrm.maxInfoFieldLengthTransmit, command.snrm.maxInfoFieldLengthReceive, command.snrm.windowSizeTransmit, command.snrm.windowSizeReceive = htran.linkParameters()
+
 	if nil != maxInfoFieldLengthTransmit {
 		command.snrm.maxInfoFieldLengthTransmit = *maxInfoFieldLengthTransmit
-	} else {
-		command.snrm.maxInfoFieldLengthTransmit = htran.maxInfoFieldLengthTransmit
 	}
 
 	if nil != maxInfoFieldLengthReceive {
 		command.snrm.maxInfoFieldLengthReceive = *maxInfoFieldLengthReceive
-	} else {
-		command.snrm.maxInfoFieldLengthReceive = htran.maxInfoFieldLengthReceive
 	}
 
-	command.snrm.windowSizeTransmit = 1
-	command.snrm.windowSizeReceive = 1
-
 	htran.controlQueueMtx.Lock()
 	if HdlcDebug {
 		fmt.Printf("htran.SendSNRM(): sending command: %d\n", command.control)
@@ -365,9 +520,11 @@ func (htran *HdlcTransport) SendDSNRM(maxInfoFieldLengthTransmit *uint16, maxInf
 func (htran *HdlcTransport) Write(p []byte) (n int, err error) {
 
 	var segment *HdlcSegment
-	var maxSegmentSize = htran.maxInfoFieldLengthTransmit
+	maxSegmentSize, _, _, _ := htran.linkParameters()
 
 	n = len(p)
+	// queue all segments at once so that link reset cannot drop only part of them
+	htran.readQueueMtx.Lock()
 	for len(p) > 0 {
 		segment = new(HdlcSegment)
 		if len(p) > int(maxSegmentSize) {
@@ -378,12 +535,14 @@ func (htran *HdlcTransport) Write(p []byte) (n int, err error) {
 			p = p[len(segment.p):]
 			segment.last = true
 		}
-		htran.readQueueMtx.Lock()
 		htran.readQueue.PushBack(segment)
-		htran.readQueueMtx.Unlock()
 	}
+	htran.readQueueMtx.Unlock()
 
-	msg := <-htran.readAck
+	msg, ok := <-htran.readAck
+	if !ok {
+		return 0, HdlcErrorTransportClosed
+	}
 	if nil == msg["err"] {
 		err = nil
 	} else {
@@ -413,7 +572,10 @@ func (htran *HdlcTransport) Read(p []byte) (n int, err error) {
 			}
 			htran.writeQueueMtx.Unlock()
 			if nil == segment {
-				msg := <-htran.writeAck
+				msg, ok := <-htran.writeAck
+				if !ok {
+					return n, HdlcErrorTransportClosed
+				}
 				if nil == msg["err"] {
 					err = nil
 				} else {
@@ -454,6 +616,74 @@ func (htran *HdlcTransport) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+/*
+Transmits 'p' to peer in UI frame(s). UI frames are not acknowledged and may
+be transmitted also when HDLC connection is not established. Server transmits
+them only when it is polled by client.
+*/
+func (htran *HdlcTransport) WriteUI(p []byte) (err error) {
+	return htran.writeUI(p, false)
+}
+
+// Transmits 'p' in UI frame(s) addressed to all servers on the line (e.g. RS-485 bus). Only client may broadcast.
+func (htran *HdlcTransport) BroadcastUI(p []byte) (err error) {
+	if !htran.client {
+		return HdlcErrorNotClient
+	}
+	return htran.writeUI(p, true)
+}
+
+func (htran *HdlcTransport) writeUI(p []byte, broadcast bool) (err error) {
+	if 0 == len(p) {
+		return HdlcErrorNoInfo
+	}
+
+	message := new(HdlcUIMessage)
+	message.p = p
+	message.broadcast = broadcast
+
+	htran.uiSendQueueMtx.Lock()
+	htran.uiSendQueue.PushBack(message)
+	htran.uiSendQueueMtx.Unlock()
+
+	msg, ok := <-htran.uiSendAck
+	if !ok {
+		return HdlcErrorTransportClosed
+	}
+	if nil == msg["err"] {
+		err = nil
+	} else {
+		err = msg["err"].(error)
+	}
+
+	return err
+}
+
+// Returns next message received from peer in UI frame(s), blocks until message arrives.
+func (htran *HdlcTransport) ReadUI() (p []byte, err error) {
+	for {
+		var e *list.Element
+
+		htran.uiReceiveQueueMtx.Lock()
+		e = htran.uiReceiveQueue.Front()
+		if nil != e {
+			htran.uiReceiveQueue.Remove(e)
+		}
+		htran.uiReceiveQueueMtx.Unlock()
+		if nil != e {
+			return e.Value.([]byte), nil
+		}
+
+		msg, ok := <-htran.uiReceiveAck
+		if !ok {
+			return nil, HdlcErrorTransportClosed
+		}
+		if nil != msg["err"] {
+			return nil, msg["err"].(error)
+		}
+	}
+}
+
 func (htran *HdlcTransport) Close() (err error) {
 	close(htran.finishedCh)
 	msg := <-htran.closedAck
@@ -464,6 +694,60 @@ func (htran *HdlcTransport) Close() (err error) {
 	}
 }
 
+func (e *HdlcLinkResetError) Error() string {
+	if nil != e.Frmr {
+		return fmt.Sprintf("hdlc link reset: %s: %s", e.Reason, e.Frmr)
+	}
+	return fmt.Sprintf("hdlc link reset: %s", e.Reason)
+}
+
+func (frmr *HdlcFrmr) String() string {
+	var diag string
+	if frmr.W {
+		diag += "W (invalid control field) "
+	}
+	if frmr.X {
+		diag += "X (information field not permitted) "
+	}
+	if frmr.Y {
+		diag += "Y (information field too long) "
+	}
+	if frmr.Z {
+		diag += "Z (invalid N(R)) "
+	}
+	return fmt.Sprintf("%scontrol: %02X, V(S): %d, V(R): %d, response: %t", diag, frmr.RejectedControl, frmr.Vs, frmr.Vr, frmr.Response)
+}
+
+// Decodes FRMR information field (ISO/IEC 13239 modulo 8 format).
+func decodeFrmr(infoField []byte) (err error, frmr *HdlcFrmr) {
+	if 3 != len(infoField) {
+		return HdlcErrorInfoFieldFormat, nil
+	}
+	frmr = new(HdlcFrmr)
+	frmr.RejectedControl = infoField[0]
+	frmr.Vs = (infoField[1] & 0x0E) >> 1
+	frmr.Response = infoField[1]&0x10 > 0
+	frmr.Vr = (infoField[1] & 0xE0) >> 5
+	frmr.W = infoField[2]&HDLC_FRMR_W > 0
+	frmr.X = infoField[2]&HDLC_FRMR_X > 0
+	frmr.Y = infoField[2]&HDLC_FRMR_Y > 0
+	frmr.Z = infoField[2]&HDLC_FRMR_Z > 0
+	return nil, frmr
+}
+
+// Encodes FRMR information field rejecting 'frame', 'diag' are HDLC_FRMR_* bits.
+func encodeFrmr(frame *HdlcFrame, vs uint8, vr uint8, diag byte) []byte {
+	p := make([]byte, 3)
+	p[0] = frame.controlField
+	p[1] = ((vs & 0x07) << 1) | ((vr & 0x07) << 5)
+	if HDLC_FRAME_DIRECTION_CLIENT_INBOUND == frame.direction {
+		// rejected frame is response from server
+		p[1] |= 0x10
+	}
+	p[2] = diag
+	return p
+}
+
 func (htran *HdlcTransport) decodeServerAddress(frame *HdlcFrame) (err error, n int) {
 	var r io.Reader
 	r = frame.content
@@ -614,12 +898,26 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 		panic("wrong expected server address length value")
 	}
 
+	logicalDeviceId := htran.logicalDeviceId
+	physicalDeviceId := htran.physicalDeviceId
+	if frame.broadcast {
+		// all station broadcast address
+		if HDLC_ADDRESS_LENGTH_4 == htran.serverAddrLength {
+			logicalDeviceId = 0x3FFF
+		} else {
+			logicalDeviceId = 0x7F
+		}
+		if HDLC_ADDRESS_LENGTH_1 != htran.serverAddrLength {
+			physicalDeviceId = new(uint16)
+			*physicalDeviceId = logicalDeviceId
+		}
+	}
+
 	if HDLC_ADDRESS_LENGTH_1 == htran.serverAddrLength {
 		p := make([]byte, 1)
 
 		// logicalDeviceId
 
-		logicalDeviceId := htran.logicalDeviceId
 		if logicalDeviceId > 0x7F {
 			errorLog("logicalDeviceId exceeds limit")
 			return HdlcErrorInvalidValue
@@ -637,7 +935,7 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 
 		// physicalDeviceId
 
-		if nil != htran.physicalDeviceId {
+		if nil != physicalDeviceId {
 			errorLog("physicalDeviceId specified (expected to be nil)")
 			return HdlcErrorInvalidValue
 		}
@@ -646,7 +944,6 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 
 		// logicalDeviceId
 
-		logicalDeviceId := htran.logicalDeviceId
 		if logicalDeviceId > 0x7F {
 			errorLog("logicalDeviceId exceeds limit")
 			return HdlcErrorInvalidValue
@@ -664,18 +961,17 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 
 		// physicalDeviceId
 
-		if nil == htran.physicalDeviceId {
+		if nil == physicalDeviceId {
 			errorLog("physicalDeviceId not specified")
 			return HdlcErrorInvalidValue
 		}
 
-		physicalDeviceId := *htran.physicalDeviceId
-		if physicalDeviceId > 0x007F {
+		if *physicalDeviceId > 0x007F {
 			errorLog("physicalDeviceId exceeds limit")
 			return HdlcErrorInvalidValue
 		}
 
-		v16 = physicalDeviceId
+		v16 = *physicalDeviceId
 
 		p[0] = byte(((v16 & 0x007F) << 1) | 0x0001)
 		_, err = w.Write(p)
@@ -689,7 +985,6 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 
 		// logicalDeviceId
 
-		logicalDeviceId := htran.logicalDeviceId
 		if logicalDeviceId > 0x3FFF {
 			errorLog("logicalDeviceId exceeds limit")
 			return HdlcErrorInvalidValue
@@ -715,18 +1010,17 @@ func (htran *HdlcTransport) encodeServerAddress(frame *HdlcFrame) (err error) {
 
 		// physicalDeviceId
 
-		if nil == htran.physicalDeviceId {
+		if nil == physicalDeviceId {
 			errorLog("physicalDeviceId not specified")
 			return HdlcErrorInvalidValue
 		}
 
-		physicalDeviceId := *htran.physicalDeviceId
-		if physicalDeviceId > 0x3FFF {
+		if *physicalDeviceId > 0x3FFF {
 			errorLog("physicalDeviceId exceeds limit")
 			return HdlcErrorInvalidValue
 		}
 
-		v16 = physicalDeviceId
+		v16 = *physicalDeviceId
 
 		p[0] = byte((v16 & 0x3F80) >> 6)
 		_, err = w.Write(p)
@@ -915,7 +1209,9 @@ func (htran *HdlcTransport) encodeFrameInfo(frame *HdlcFrame) (err error) {
 	if (nil != frame.infoField) && len(frame.infoField) > 0 {
 		infoFieldLength := len(frame.infoField)
 
-		if (HDLC_FRAME_DIRECTION_CLIENT_INBOUND == frame.direction) || (HDLC_FRAME_DIRECTION_SERVER_INBOUND == frame.direction) {
+		if (HDLC_CONTROL_I != frame.control) && (HDLC_CONTROL_UI != frame.control) {
+			// negotiated length limits only I and UI frames
+		} else if (HDLC_FRAME_DIRECTION_CLIENT_INBOUND == frame.direction) || (HDLC_FRAME_DIRECTION_SERVER_INBOUND == frame.direction) {
 			if infoFieldLength > int(htran.maxInfoFieldLengthReceive) {
 				errorLog("long info field")
 				return HdlcErrorMalformedSegment
@@ -1027,7 +1323,6 @@ func (htran *HdlcTransport) decodeLinkParameters(frame *HdlcFrame) (err error, m
 
 	// parameters
 
-	var buf *bytes.Buffer
 	for {
 		_, err := io.ReadFull(rr, p)
 		if nil != err {
@@ -1082,32 +1377,24 @@ func (htran *HdlcTransport) decodeLinkParameters(frame *HdlcFrame) (err error, m
 				return HdlcErrorParameterValue, nil, nil, nil, nil
 			}
 		} else if 0x07 == parameterId {
-			if 4 != length {
+			// window size is encoded on 4 bytes by us but some meters use just 1 byte
+			if (length < 1) || (length > 4) {
 				warnLog("wrong parameter value length")
 				return HdlcErrorParameterValue, nil, nil, nil, nil
 			}
 			windowSizeTransmit = new(uint32)
-			buf = bytes.NewBuffer(parameterValue)
-			err = binary.Read(buf, binary.BigEndian, windowSizeTransmit)
-			if nil != err {
-				if !isTimeOutErr(err) {
-					errorLog("binary.Read() failed: %v", err)
-				}
-				return err, nil, nil, nil, nil
+			for _, b := range parameterValue {
+				*windowSizeTransmit = *windowSizeTransmit<<8 | uint32(b)
 			}
 		} else if 0x08 == parameterId {
-			if 4 != length {
+			// window size is encoded on 4 bytes by us but some meters use just 1 byte
+			if (length < 1) || (length > 4) {
 				warnLog("wrong parameter value length")
 				return HdlcErrorParameterValue, nil, nil, nil, nil
 			}
 			windowSizeReceive = new(uint32)
-			buf = bytes.NewBuffer(parameterValue)
-			err = binary.Read(buf, binary.BigEndian, windowSizeReceive)
-			if nil != err {
-				if !isTimeOutErr(err) {
-					errorLog("binary.Read() failed: %v", err)
-				}
-				return err, nil, nil, nil, nil
+			for _, b := range parameterValue {
+				*windowSizeReceive = *windowSizeReceive<<8 | uint32(b)
 			}
 		} else {
 			// just ignore usupported parameter
@@ -1347,6 +1634,7 @@ func (htran *HdlcTransport) decodeFrameACI(frame *HdlcFrame, l int) (err error,
 	n += 1
 	frame.fcs16 = pppfcs16(frame.fcs16, p)
 	b0 = p[0]
+	frame.controlField = b0
 
 	// P/F bit
 	frame.poll = b0&0x10 > 0
@@ -1448,6 +1736,36 @@ func (htran *HdlcTransport) decodeFrameACI(frame *HdlcFrame, l int) (err error,
 		n += 1
 		frame.fcs16 = pppfcs16(frame.fcs16, p)
 
+		if PPPGOODFCS16 != frame.fcs16 {
+			warnLog("wrong FCS")
+			return HdlcErrorMalformedSegment, n
+		}
+	} else if (b0&0x08 > 0) && (b0&0x04 == 0) && (b0&0x02 == 0) && (b0&0x01 > 0) {
+		frame.control = HDLC_CONTROL_REJ
+
+		frame.nr = b0 & 0xE0 >> 5
+
+		// HCS - header control sum
+
+		_, err = io.ReadFull(r, p)
+		if nil != err {
+			if !isTimeOutErr(err) {
+				errorLog("io.ReadFull() failed: %v", err)
+			}
+			return err, n
+		}
+		n += 1
+		frame.fcs16 = pppfcs16(frame.fcs16, p)
+		_, err = io.ReadFull(r, p)
+		if nil != err {
+			if !isTimeOutErr(err) {
+				errorLog("io.ReadFull() failed: %v", err)
+			}
+			return err, n
+		}
+		n += 1
+		frame.fcs16 = pppfcs16(frame.fcs16, p)
+
 		if PPPGOODFCS16 != frame.fcs16 {
 			warnLog("wrong FCS")
 			return HdlcErrorMalformedSegment, n
@@ -1816,6 +2134,41 @@ func (htran *HdlcTransport) encodeFrameACI(frame *HdlcFrame) (err error) {
 		}
 		frame.fcs16 = pppfcs16(frame.fcs16, p)
 
+	} else if HDLC_CONTROL_REJ == frame.control {
+		b0 |= 0x01
+		b0 |= 0x08
+
+		if frame.nr > 0x07 {
+			panic("NR exceeds limit")
+		}
+		b0 |= frame.nr << 5
+
+		p[0] = b0
+		_, err = w.Write(p)
+		if nil != err {
+			errorLog("w.Write() failed: %v", err)
+			return err
+		}
+		frame.fcs16 = pppfcs16(frame.fcs16, p)
+
+		// FCS - frame control sum
+
+		fcs16 := frame.fcs16
+		p[0] = byte(^fcs16 & 0x00FF)
+		_, err = w.Write(p)
+		if nil != err {
+			errorLog("w.Write() failed: %v", err)
+			return err
+		}
+		frame.fcs16 = pppfcs16(frame.fcs16, p)
+		p[0] = byte((^fcs16 & 0xFF00) >> 8)
+		_, err = w.Write(p)
+		if nil != err {
+			errorLog("w.Write() failed: %v", err)
+			return err
+		}
+		frame.fcs16 = pppfcs16(frame.fcs16, p)
+
 	} else if HDLC_CONTROL_SNRM == frame.control {
 		b0 |= 0x01
 		b0 |= 0x02
@@ -2333,6 +2686,46 @@ func (htran *HdlcTransport) readFrameTest3(direction int) (err error, frame *Hdl
 	}
 }
 
+// corrupt N(R) of every 5th I frame
+
+func (htran *HdlcTransport) readFrameTest4(direction int) (err error, frame *HdlcFrame) {
+	err, frame = htran.readFrameNormal(direction)
+	if nil != err {
+		return err, nil
+	}
+	if HDLC_CONTROL_I == frame.control {
+		htran.frameNum += 1
+		if 0 == htran.frameNum%5 {
+			if HdlcDebug {
+				fmt.Print("corrupt N(R) ")
+				htran.printFrame(frame)
+			}
+			frame.nr = (frame.nr + htran.modulus/2) % htran.modulus
+		}
+	}
+	return nil, frame
+}
+
+// replace 5th RR frame with DM
+
+func (htran *HdlcTransport) readFrameTest5(direction int) (err error, frame *HdlcFrame) {
+	err, frame = htran.readFrameNormal(direction)
+	if nil != err {
+		return err, nil
+	}
+	if HDLC_CONTROL_RR == frame.control {
+		htran.frameNum += 1
+		if 5 == htran.frameNum {
+			if HdlcDebug {
+				fmt.Print("replace with DM ")
+				htran.printFrame(frame)
+			}
+			frame.control = HDLC_CONTROL_DM
+		}
+	}
+	return nil, frame
+}
+
 func (htran *HdlcTransport) readFrame(direction int) (err error, frame *HdlcFrame) {
 	var readFrameImpl int = htran.readFrameImpl
 	if 0 == readFrameImpl {
@@ -2343,6 +2736,10 @@ func (htran *HdlcTransport) readFrame(direction int) (err error, frame *HdlcFram
 		return htran.readFrameTest2(direction)
 	} else if 3 == readFrameImpl {
 		return htran.readFrameTest3(direction)
+	} else if 4 == readFrameImpl {
+		return htran.readFrameTest4(direction)
+	} else if 5 == readFrameImpl {
+		return htran.readFrameTest5(direction)
 	} else {
 		panic("unknow read frame implementation")
 	}
@@ -2435,6 +2832,9 @@ func (htran *HdlcTransport) printFrame(frame *HdlcFrame) {
 	case HDLC_CONTROL_RNR:
 		control = "RNR, "
 		sequence = fmt.Sprintf("nr %d, ", frame.nr)
+	case HDLC_CONTROL_REJ:
+		control = "REJ, "
+		sequence = fmt.Sprintf("nr %d, ", frame.nr)
 	case HDLC_CONTROL_SNRM:
 		control = "SNRM, "
 	case HDLC_CONTROL_DISC:
@@ -2469,6 +2869,36 @@ func (htran *HdlcTransport) printFrame(frame *HdlcFrame) {
 	fmt.Printf("%s%s%s%s%s%s\n", direction, control, poll, sequence, segment, info)
 }
 
+// Writes 'message' in UI frames, it is segmented if it is longer than max. info field length.
+func (htran *HdlcTransport) writeUIFrames(message *HdlcUIMessage) (err error) {
+	p := message.p
+	for {
+		frame := new(HdlcFrame)
+		if htran.client {
+			frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
+		} else {
+			frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
+		}
+		frame.poll = false
+		frame.control = HDLC_CONTROL_UI
+		frame.broadcast = message.broadcast
+		if len(p) > int(htran.maxInfoFieldLengthTransmit) {
+			frame.infoField = p[0:htran.maxInfoFieldLengthTransmit]
+			frame.segmentation = true
+		} else {
+			frame.infoField = p
+		}
+		p = p[len(frame.infoField):]
+		err = htran.writeFrame(frame)
+		if nil != err {
+			return err
+		}
+		if !frame.segmentation {
+			return nil
+		}
+	}
+}
+
 func (htran *HdlcTransport) handleHdlc() {
 	var frame *HdlcFrame
 	var segment *HdlcSegment
@@ -2486,16 +2916,119 @@ func (htran *HdlcTransport) handleHdlc() {
 		STATE_CONNECTED_SEGMENT_WAIT
 		STATE_DISCONNECTING
 		STATE_DISCONNECTED
+		STATE_FRAME_REJECT // server rejected frame and waits for client to reset the link
 	)
 	var state int = STATE_DISCONNECTED
 	var clientRcnt int
 	var serverRcnt int
 
-	var segmentToAck *HdlcFrame = nil
+	framesToAck := list.New()  // transmitted and not yet acknowledged I frames, oldest first
+	var window int             // number of I frames transmitted in one poll, shrinks when frames get lost
+	var sentInPoll int         // number of I frames transmitted in last poll
+	var ackedInPoll int        // number of I frames acknowledged since last poll
 	framesToSend := list.New() // frames scheduled to send in next poll
 
 	var segmentDeadline time.Time
 
+	var uiSegments bytes.Buffer // info fields of received UI frames of not yet complete message
+
+	var recovering bool      // client re-establishes reset link, nobody waits for UA
+	var recoveryAttempts int // SNRM frames transmitted while re-establishing reset link
+	var frmrFrame *HdlcFrame // FRMR transmitted by server, repeated until client resets the link
+
+	var disconnectPending bool // server notifies that link was disconnected by client once UA is sent
+
+	// Acknowledges transmitted I frames preceding 'nr'. Returns number of acknowledged frames, ok is false if 'nr' is not within transmit window.
+	acknowledge := func(nr uint8) (n int, ok bool) {
+		if nr != vs {
+			for e := framesToAck.Front(); nil != e; e = e.Next() {
+				if nr == e.Value.(*HdlcFrame).ns {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return 0, false
+			}
+		}
+		for framesToAck.Len() > 0 {
+			f := framesToAck.Front().Value.(*HdlcFrame)
+			if nr == f.ns {
+				break
+			}
+			framesToAck.Remove(framesToAck.Front())
+			n += 1
+			ackedInPoll += 1
+			if !f.segmentation {
+				// whole segment written by upper layer is acknowledged
+				htran.readAck <- map[string]interface{}{"err": nil}
+			}
+		}
+		return n, true
+	}
+
+	// Fails writes of segments not yet acknowledged by peer, these are lost when link is reset or disconnected.
+	failWrites := func(err error) {
+		for e := framesToAck.Front(); nil != e; e = e.Next() {
+			if !e.Value.(*HdlcFrame).segmentation {
+				htran.readAck <- map[string]interface{}{"err": err}
+			}
+		}
+		framesToAck = list.New()
+		htran.readQueueMtx.Lock()
+		for e := htran.readQueue.Front(); nil != e; e = e.Next() {
+			if e.Value.(*HdlcSegment).last {
+				htran.readAck <- map[string]interface{}{"err": err}
+			}
+		}
+		htran.readQueue.Init()
+		htran.readQueueMtx.Unlock()
+	}
+
+	/*
+		Client resets the link after FRMR, unexpected DM or sequence error. Data in transit are lost, pending writes
+		fail and next read returns 'e'. Link is then connected again with parameters of last SNRM.
+	*/
+	resetLink := func(e *HdlcLinkResetError) {
+		warnLog("%s", e)
+		failWrites(e)
+		htran.writeQueueMtx.Lock()
+		htran.writeQueue.Init() // drop segments of partially received message
+		htran.writeQueueMtx.Unlock()
+		select {
+		case htran.writeAck <- map[string]interface{}{"err": e}:
+		default:
+			// reader is woken up by acknowledgements already waiting in channel
+		}
+		framesToSend = list.New()
+		recovering = true
+		recoveryAttempts = 0
+		state = STATE_CONNECTING
+	}
+
+	// Server rejects received 'frame' and refuses any further I frames until client resets the link.
+	rejectFrame := func(frame *HdlcFrame, diag byte) {
+		frmrFrame = new(HdlcFrame)
+		frmrFrame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
+		frmrFrame.poll = true
+		frmrFrame.control = HDLC_CONTROL_FRMR
+		frmrFrame.infoField = encodeFrmr(frame, vs, vr, diag)
+		warnLog("rejecting frame, control: %02X, N(R): %d, V(S): %d, V(R): %d", frame.controlField, frame.nr, vs, vr)
+		failWrites(HdlcErrorFrameRejected)
+		framesToSend = list.New()
+		framesToSend.PushBack(frmrFrame)
+		state = STATE_FRAME_REJECT
+	}
+
+	// Server in disconnected mode answers commands with DM so that client learns that link is down.
+	respondDM := func() {
+		frame := new(HdlcFrame)
+		frame.poll = true
+		frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
+		frame.control = HDLC_CONTROL_DM
+		framesToSend.PushBack(frame)
+	}
+
 	if htran.client {
 		sending = true
 	} else {
@@ -2511,6 +3044,9 @@ mainLoop:
 			if framesToSend.Len() > 0 {
 				for framesToSend.Len() > 0 {
 					frame = framesToSend.Front().Value.(*HdlcFrame)
+					if HDLC_CONTROL_RR == frame.control {
+						frame.nr = vr // acknowledge also frames received after RR was scheduled
+					}
 					if frame.poll {
 						sending = false
 					}
@@ -2519,12 +3055,40 @@ mainLoop:
 						break mainLoop
 					}
 					framesToSend.Remove(framesToSend.Front())
+					if disconnectPending && (0 == framesToSend.Len()) {
+						// UA acknowledging DISC is out
+						disconnectPending = false
+						if nil != htran.linkDisconnected {
+							htran.linkDisconnected()
+						}
+					}
 					if !sending {
 						continue mainLoop
 					}
 				}
 			}
 
+			// transmit pending UI frames, these are neither sequenced nor acknowledged
+
+			for {
+				var message *HdlcUIMessage
+
+				htran.uiSendQueueMtx.Lock()
+				if htran.uiSendQueue.Len() > 0 {
+					message = htran.uiSendQueue.Front().Value.(*HdlcUIMessage)
+					htran.uiSendQueue.Remove(htran.uiSendQueue.Front())
+				}
+				htran.uiSendQueueMtx.Unlock()
+				if nil == message {
+					break
+				}
+				err = htran.writeUIFrames(message)
+				if nil != err {
+					break mainLoop
+				}
+				htran.uiSendAck <- map[string]interface{}{"err": nil}
+			}
+
 			// check for any pending priority command
 
 			htran.controlQueueMtx.Lock()
@@ -2536,63 +3100,110 @@ mainLoop:
 			}
 			htran.controlQueueMtx.Unlock()
 
-			// check for any pending segment to transmit o retransmit unacknowledged segment
+			// check for any pending segment to transmit or unacknowledged frames to retransmit
 
 			if nil == command {
 				segment = nil
-				if !timeout {
-					if nil == segmentToAck {
+				if (STATE_CONNECTED == state) || (STATE_CONNECTED_SEGMENT_WAIT == state) {
+
+					if sentInPoll > 0 {
+						if ackedInPoll < sentInPoll {
+							// Some frames transmitted in last poll were lost, on bad link transmit less frames in one poll
+							// so that fewer frames are transmitted again when next frame is lost.
+							window = window / 2
+							if window < 1 {
+								window = 1
+							}
+						} else if window < int(htran.windowSizeTransmit) {
+							window += 1
+						}
+						sentInPoll = 0
+					}
+
+					// frames to transmit in this poll, poll bit is set in the last one
+
+					frames := list.New()
+
+					// Peer did not acknowledge all frames transmitted in previous poll (or we timed out waiting for acknowledgement),
+					// transmit again frames not received by peer starting with N(R) peer sent last time. This is go-back-N, not selective
+					// retransmission: DLMS HDLC has no SREJ and receiver discards out of sequence frames, so all frames following
+					// the lost one are transmitted again. REJ is handled as RR.
+					for e := framesToAck.Front(); (nil != e) && (frames.Len() < window); e = e.Next() {
+						frames.PushBack(e.Value)
+					}
+
+					if !timeout {
+						// fill up the transmit window with new segments
 						htran.readQueueMtx.Lock()
-						if htran.readQueue.Len() > 0 {
+						for (frames.Len() < window) && (framesToAck.Len() < int(htran.windowSizeTransmit)) && (htran.readQueue.Len() > 0) {
 							segment = htran.readQueue.Front().Value.(*HdlcSegment)
 							htran.readQueue.Remove(htran.readQueue.Front())
-						} else {
-							segment = nil
+
+							frame = new(HdlcFrame)
+							if htran.client {
+								frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
+							} else {
+								frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
+							}
+							frame.segmentation = !segment.last
+							frame.control = HDLC_CONTROL_I
+							frame.ns = vs
+							frame.infoField = segment.p
+							framesToAck.PushBack(frame)
+							frames.PushBack(frame)
+
+							if vs == htran.modulus-1 {
+								vs = 0
+							} else {
+								vs += 1
+							}
 						}
 						htran.readQueueMtx.Unlock()
-					} else {
-						// transmit unacknowledged segment
-						if segmentToAck.poll {
-							sending = false
-						}
-						err = htran.writeFrame(segmentToAck)
-						if nil != err {
-							break mainLoop
-						}
-						if !sending {
-							continue mainLoop
-						}
 					}
-				} else {
-					if (STATE_CONNECTED == state) || (STATE_CONNECTED_SEGMENT_WAIT == state) {
-						if nil != segmentToAck {
-							// transmit again possibly lost transmitted I frame to server
-							if segmentToAck.poll {
-								sending = false
-							}
-							err = htran.writeFrame(segmentToAck)
-							if nil != err {
-								break mainLoop
-							}
-							if !sending {
-								continue mainLoop
-							}
-						} else {
-							// in case we lost incomming I frame from server solicit its retransmission
-							frame = new(HdlcFrame)
-							frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
-							frame.poll = true
-							frame.control = HDLC_CONTROL_RR
-							frame.ns = vs
+
+					if frames.Len() > 0 {
+						state = STATE_CONNECTED
+						for e := frames.Front(); nil != e; e = e.Next() {
+							frame = e.Value.(*HdlcFrame)
 							frame.nr = vr
-							err := htran.writeFrame(frame)
+							frame.poll = nil == e.Next()
+							frame.content = nil // encode again, N(R) and poll bit may have changed since last transmission
+							err = htran.writeFrame(frame)
 							if nil != err {
 								break mainLoop
 							}
-							sending = false
-							continue mainLoop
 						}
+						sentInPoll = frames.Len()
+						ackedInPoll = 0
+						sending = false
+						continue mainLoop
+					}
+
+					if timeout {
+						// in case we lost incomming I frame from server solicit its retransmission
+						frame = new(HdlcFrame)
+						frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
+						frame.poll = true
+						frame.control = HDLC_CONTROL_RR
+						frame.ns = vs
+						frame.nr = vr
+						err = htran.writeFrame(frame)
+						if nil != err {
+							break mainLoop
+						}
+						sending = false
+						continue mainLoop
+					}
+				} else if !timeout && !recovering {
+					// segments written while link is being re-established wait until it is connected
+					htran.readQueueMtx.Lock()
+					if htran.readQueue.Len() > 0 {
+						segment = htran.readQueue.Front().Value.(*HdlcSegment)
+						htran.readQueue.Remove(htran.readQueue.Front())
+					} else {
+						segment = nil
 					}
+					htran.readQueueMtx.Unlock()
 				}
 			}
 
@@ -2612,10 +3223,13 @@ mainLoop:
 					frame.control = HDLC_CONTROL_SNRM
 
 					snrm := command.snrm
-					err = htran.encodeLinkParameters(frame, &snrm.maxInfoFieldLengthTransmit, &snrm.maxInfoFieldLengthReceive, &snrm.windowSizeTransmit, &snrm.windowSizeTransmit)
+					err = htran.encodeLinkParameters(frame, &snrm.maxInfoFieldLengthTransmit, &snrm.maxInfoFieldLengthReceive, &snrm.windowSizeTransmit, &snrm.windowSizeReceive)
 					if nil != err {
 						break mainLoop
 					}
+					// proposed lengths may be only lowered by server in UA
+					htran.maxInfoFieldLengthTransmit = snrm.maxInfoFieldLengthTransmit
+					htran.maxInfoFieldLengthReceive = snrm.maxInfoFieldLengthReceive
 
 					// @@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
 					//frame.content = bytes.NewBuffer([]byte{0x7E, 0xA0, 0x1F, 0x02, 0xFF, 0x23, 0x93, 0x3D, 0xF9, 0x81, 0x80, 0x12, 0x05, 0x01, 0x82, 0x06, 0x01, 0x82, 0x07, 0x04, 0x00, 0x00, 0x00, 0x02, 0x08, 0x04, 0x00, 0x00, 0x00, 0x02, 0xCD, 0xBE, 0x7E})
@@ -2631,7 +3245,8 @@ mainLoop:
 				}
 			} else if (nil != command) && (HDLC_CONTROL_DISC == command.control) {
 				if htran.client { // only client may disconnect the line.
-					if (STATE_CONNECTED == state) || (STATE_CONNECTED_SEGMENT_WAIT == state) || (STATE_DISCONNECTED == state) {
+					if (STATE_CONNECTED == state) || (STATE_CONNECTED_SEGMENT_WAIT == state) || (STATE_DISCONNECTED == state) || ((STATE_CONNECTING == state) && recovering) {
+						recovering = false
 						frame = new(HdlcFrame)
 						frame.poll = true
 						frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
@@ -2640,7 +3255,7 @@ mainLoop:
 						if nil != err {
 							break mainLoop
 						}
-						segmentToAck = nil        // no retransmitting anymore, we are disconnecting
+						framesToAck = list.New()  // no retransmitting anymore, we are disconnecting
 						framesToSend = list.New() // do not transmit anything scheduled for the next poll, we are disconnecting
 						state = STATE_DISCONNECTING
 						sending = false
@@ -2651,51 +3266,31 @@ mainLoop:
 					htran.controlAck <- map[string]interface{}{"err": HdlcErrorNoAllowed}
 				}
 			} else if nil != segment {
-				if (STATE_CONNECTED == state) || (STATE_CONNECTED_SEGMENT_WAIT == state) {
-
-					if STATE_CONNECTED_SEGMENT_WAIT == state {
-						state = STATE_CONNECTED
-					}
-
-					frame = new(HdlcFrame)
-					if htran.client {
-						frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
-					} else {
-						frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
-					}
-					frame.poll = true
-					frame.segmentation = !segment.last
-					frame.control = HDLC_CONTROL_I
-					frame.ns = vs
-					frame.nr = vr
-					frame.infoField = segment.p
-
-					err = htran.writeFrame(frame)
-					if nil != err {
-						break mainLoop
-					}
-					segmentToAck = frame
-					if vs == htran.modulus-1 {
-						vs = 0
-					} else {
-						vs += 1
-					}
-					sending = false
-
-				} else {
+				if segment.last {
 					htran.readAck <- map[string]interface{}{"err": HdlcErrorNotConnected}
 				}
 			} else {
 				// nothing to transmit now, poll the peer (may be peer has someting to transmit now)
 
 				if STATE_CONNECTING == state {
+					if recovering {
+						if recoveryAttempts >= HdlcLinkRecoveryAttempts {
+							errorLog("link not re-established, giving up")
+							recovering = false
+							state = STATE_DISCONNECTED
+							failWrites(HdlcErrorDisconnected)
+							continue mainLoop
+						}
+						recoveryAttempts += 1
+					}
+
 					frame = new(HdlcFrame)
 					frame.poll = true
 					frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
 					frame.control = HDLC_CONTROL_SNRM
 
 					snrm := snrmCommand.snrm
-					err = htran.encodeLinkParameters(frame, &snrm.maxInfoFieldLengthTransmit, &snrm.maxInfoFieldLengthReceive, &snrm.windowSizeTransmit, &snrm.windowSizeTransmit)
+					err = htran.encodeLinkParameters(frame, &snrm.maxInfoFieldLengthTransmit, &snrm.maxInfoFieldLengthReceive, &snrm.windowSizeTransmit, &snrm.windowSizeReceive)
 					if nil != err {
 						break mainLoop
 					}
@@ -2718,7 +3313,7 @@ mainLoop:
 					frame.control = HDLC_CONTROL_RR
 					frame.ns = vs
 					frame.nr = vr
-					err := htran.writeFrame(frame)
+					err = htran.writeFrame(frame)
 					if nil != err {
 						break mainLoop
 					}
@@ -2756,6 +3351,13 @@ mainLoop:
 						break mainLoop
 					}
 					sending = false
+				} else if STATE_FRAME_REJECT == state {
+					// repeat FRMR until client resets the link
+					err = htran.writeFrame(frmrFrame)
+					if nil != err {
+						break mainLoop
+					}
+					sending = false
 				} else if STATE_DISCONNECTED == state {
 					if htran.client {
 						// just wait wait for upper layer to send SNRM command
@@ -2790,23 +3392,26 @@ mainLoop:
 
 			timeout = false
 			ch := make(chan bool)
+			var rerr error // reader may finish after transport was closed, do not let it touch 'err' and 'frame' then
+			var rframe *HdlcFrame
 			go func(ch chan bool) {
 				if htran.client {
-					// we need upcast to net.Conn so that we cat set read dealine (this should be only palce in entire code needing such upcasting)
-					conn, ok := htran.rw.(net.Conn)
+					// we need upcast so that we cat set read dealine (this should be only palce in entire code needing such upcasting)
+					conn, ok := htran.rw.(tReadDeadliner)
 					if !ok {
-						panic("io.ReadWriter passed to hdlc transport constructor must be instance of net.Conn")
+						panic("io.ReadWriter passed to hdlc transport constructor must support read deadline (net.Conn or *os.File)")
 					}
 					conn.SetReadDeadline(time.Now().Add(htran.responseTimeout))
-					err, frame = htran.readFrame(HDLC_FRAME_DIRECTION_CLIENT_INBOUND)
+					rerr, rframe = htran.readFrame(HDLC_FRAME_DIRECTION_CLIENT_INBOUND)
 				} else {
-					err, frame = htran.readFrame(HDLC_FRAME_DIRECTION_SERVER_INBOUND)
+					rerr, rframe = htran.readFrame(HDLC_FRAME_DIRECTION_SERVER_INBOUND)
 				}
 				//rfCh <- true
 				close(ch)
 			}(ch)
 			select {
 			case <-ch:
+				err, frame = rerr, rframe
 			case <-htran.finishedCh:
 				err = HdlcErrorTransportClosed
 				break mainLoop
@@ -2814,17 +3419,9 @@ mainLoop:
 
 			if HdlcDebug {
 				if htran.client {
-					if nil != segmentToAck {
-						fmt.Printf("client: vs %d, vr %d, akcWait[vs %d, vr %d]\n", vs, vr, segmentToAck.ns, segmentToAck.nr)
-					} else {
-						fmt.Printf("client: vs %d, vr %d\n", vs, vr)
-					}
+					fmt.Printf("client: vs %d, vr %d, akcWait %d\n", vs, vr, framesToAck.Len())
 				} else {
-					if nil != segmentToAck {
-						fmt.Printf("server: vs %d, vr %d, akcWait[vs %d, vr %d]\n", vs, vr, segmentToAck.ns, segmentToAck.nr)
-					} else {
-						fmt.Printf("server: vs %d, vr %d\n", vs, vr)
-					}
+					fmt.Printf("server: vs %d, vr %d, akcWait %d\n", vs, vr, framesToAck.Len())
 				}
 			}
 
@@ -2860,7 +3457,15 @@ mainLoop:
 
 			if HDLC_CONTROL_I == frame.control {
 				if STATE_CONNECTED == state {
-					if /* received in sequence frame */ frame.ns == vr && /* and received frame within ack window */ (frame.nr == vs) {
+					acked, ok := acknowledge(frame.nr)
+					if !ok {
+						// N(R) not within transmit window, sequence numbers cannot be resynchronized without resetting the link
+						if htran.client {
+							resetLink(&HdlcLinkResetError{Reason: fmt.Sprintf("sequence error, received N(R) %d, V(S) %d", frame.nr, vs)})
+						} else {
+							rejectFrame(frame, HDLC_FRMR_Z)
+						}
+					} else if /* received in sequence frame */ frame.ns == vr {
 
 						// Accept frame.
 
@@ -2889,13 +3494,9 @@ mainLoop:
 							}
 						}
 
-						if nil != segmentToAck {
-							// acknoledge transmitted segment
-							segmentToAck = nil // set not yet acknowledged segment to nil to prevent retransmisson
-							htran.readAck <- map[string]interface{}{"err": nil}
-
+						if acked > 0 {
 							htran.readQueueMtx.Lock()
-							if 0 == htran.readQueue.Len() {
+							if (0 == htran.readQueue.Len()) && (0 == framesToAck.Len()) {
 
 								if htran.cosem && ((false == frame.segmentation) && frame.poll) {
 									/*
@@ -2937,95 +3538,42 @@ mainLoop:
 							htran.readQueueMtx.Unlock()
 						}
 					} else {
-
-						if /* received frame not within ack window */ frame.nr != vs {
-
-							// reject frame
-
-							frame = new(HdlcFrame)
-							if htran.client {
-								frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
-							} else {
-								frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
-							}
-							frame.poll = false
-							frame.control = HDLC_CONTROL_FRMR
-							frame.infoField = []byte("unexpected frame")
-							framesToSend.PushBack(frame)
-
-							// resynchronize frames
-
-							if nil != segmentToAck {
-								// retransmit all unacknowledged frames to insure that peer received frames we transmitted in last poll
-								framesToSend.PushBack(segmentToAck)
-							} else {
-								// sent RR to insure that peer received last acknowledgement we sent
-								frame = new(HdlcFrame)
-								if htran.client {
-									frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
-								} else {
-									frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
-								}
-								frame.poll = false
-								frame.control = HDLC_CONTROL_RR
-								frame.ns = vs
-								frame.nr = vr
-								framesToSend.PushBack(frame)
-							}
-						} else {
-							// ignore out of sequence frame
-						}
+						// Ignore out of sequence frame, N(R) we send in next poll makes peer transmit it again.
 					}
 
+				} else if !htran.client && (STATE_DISCONNECTED == state) && frame.poll {
+					respondDM()
 				} else {
 					// ignore frame
 				}
 
-			} else if HDLC_CONTROL_RR == frame.control {
-				if STATE_CONNECTED == state {
-
-					if /* received frame within ack widow */ frame.nr == vs {
-						if nil != segmentToAck {
-							// acknoledge transmitted segment
-							segmentToAck = nil
-							htran.readAck <- map[string]interface{}{"err": nil}
-						}
-					} else {
-						// retransmit all unacknowledged frames to insure that peer received frames we transmitted in last poll
-						if nil != segmentToAck {
-							framesToSend.PushBack(segmentToAck)
-						}
-					}
-				} else {
-				}
-			} else if HDLC_CONTROL_RNR == frame.control {
+			} else if (HDLC_CONTROL_RR == frame.control) || (HDLC_CONTROL_REJ == frame.control) || (HDLC_CONTROL_RNR == frame.control) {
 				if STATE_CONNECTED == state {
-
-					if /* received frame within ack widow */ frame.nr == vs {
-						if nil != segmentToAck {
-							// acknoledge transmitted segment
-							segmentToAck = nil
-							htran.readAck <- map[string]interface{}{"err": nil}
+					// Frames not acknowledged by peer are transmitted again in next poll.
+					if _, ok := acknowledge(frame.nr); !ok {
+						if htran.client {
+							resetLink(&HdlcLinkResetError{Reason: fmt.Sprintf("sequence error, received N(R) %d, V(S) %d", frame.nr, vs)})
+						} else {
+							rejectFrame(frame, HDLC_FRMR_Z)
 						}
-
-					} else {
-						// ignore frame with unexpected ucknowledgemnet
 					}
-
+				} else if !htran.client && (STATE_DISCONNECTED == state) && frame.poll {
+					respondDM()
 				} else {
 					// ignore frame
 				}
 			} else if HDLC_CONTROL_SNRM == frame.control {
-				if STATE_CONNECTED == state {
-					// in case SRRM is retransmitted due to lost UA we go to disconnected state agian
+				if (STATE_CONNECTED == state) || (STATE_FRAME_REJECT == state) {
+					// in case SRRM is retransmitted due to lost UA or client resets the link we go to disconnected state agian
+					failWrites(&HdlcLinkResetError{Reason: "link reset by client"})
 					framesToSend = list.New()
-					segmentToAck = nil
 					state = STATE_DISCONNECTED
 				}
 				if STATE_DISCONNECTED == state {
 
 					err, maxInfoFieldLengthTransmit, maxInfoFieldLengthReceive, windowSizeTransmit, windowSizeReceive := htran.decodeLinkParameters(frame)
 					if nil != err {
+						rejected := frame
 						frame = new(HdlcFrame)
 						if htran.client {
 							frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
@@ -3034,7 +3582,7 @@ mainLoop:
 						}
 						frame.poll = true
 						frame.control = HDLC_CONTROL_FRMR
-						frame.infoField = []byte("malformed parameters")
+						frame.infoField = encodeFrmr(rejected, vs, vr, HDLC_FRMR_W|HDLC_FRMR_X)
 						framesToSend.PushBack(frame)
 						continue mainLoop
 					}
@@ -3044,51 +3592,12 @@ mainLoop:
 					frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND // only client may send SNRM
 					frame.control = HDLC_CONTROL_UA
 
-					// negotiate link parameters
+					// negotiate link parameters, SNRM carries them from client's point of view and UA from server's point of view
 
-					if nil != maxInfoFieldLengthTransmit {
-						if *maxInfoFieldLengthTransmit > htran.maxInfoFieldLengthReceive {
-							*maxInfoFieldLengthTransmit = htran.maxInfoFieldLengthReceive
-						}
-					} else {
-						*maxInfoFieldLengthTransmit = htran.maxInfoFieldLengthReceive
-					}
+					htran.negotiateInfoFieldLength(maxInfoFieldLengthTransmit, maxInfoFieldLengthReceive)
+					htran.negotiateWindowSize(windowSizeTransmit, windowSizeReceive)
 
-					if nil != maxInfoFieldLengthReceive {
-						if *maxInfoFieldLengthReceive > htran.maxInfoFieldLengthTransmit {
-							*maxInfoFieldLengthReceive = htran.maxInfoFieldLengthTransmit
-						}
-					} else {
-						*maxInfoFieldLengthReceive = htran.maxInfoFieldLengthTransmit
-					}
-
-					if nil != windowSizeTransmit {
-						if *windowSizeTransmit > htran.windowSizeTransmit {
-							*windowSizeTransmit = htran.windowSizeTransmit
-						}
-					} else {
-						*windowSizeTransmit = htran.windowSizeTransmit
-					}
-
-					if nil != windowSizeReceive {
-						if *windowSizeReceive > htran.windowSizeReceive {
-							*windowSizeReceive = htran.windowSizeReceive
-						}
-					} else {
-						*windowSizeReceive = htran.windowSizeReceive
-					}
-
-					if *windowSizeTransmit != *windowSizeReceive {
-						*windowSizeReceive = *windowSizeTransmit
-					}
-
-					// replace default parameters by negotiated parameters
-					htran.maxInfoFieldLengthTransmit = *maxInfoFieldLengthTransmit
-					htran.maxInfoFieldLengthReceive = *maxInfoFieldLengthReceive
-					htran.windowSizeTransmit = *windowSizeTransmit
-					htran.windowSizeReceive = *windowSizeReceive
-
-					err = htran.encodeLinkParameters(frame, maxInfoFieldLengthTransmit, maxInfoFieldLengthReceive, windowSizeTransmit, windowSizeReceive)
+					err = htran.encodeLinkParameters(frame, &htran.maxInfoFieldLengthTransmit, &htran.maxInfoFieldLengthReceive, &htran.windowSizeTransmit, &htran.windowSizeReceive)
 					if nil != err {
 						break mainLoop
 					}
@@ -3096,23 +3605,29 @@ mainLoop:
 					state = STATE_CONNECTED
 					vs = 0
 					vr = 0
-					segmentToAck = nil
+					framesToAck = list.New()
+					window = int(htran.windowSizeTransmit)
+					sentInPoll = 0
 					serverRcnt = 0
 					framesToSend.PushBack(frame)
+					if nil != htran.linkConnected {
+						htran.linkConnected()
+					}
 				} else {
 					// ignore frame
 				}
 			} else if HDLC_CONTROL_DISC == frame.control {
 
-				if STATE_CONNECTED == state {
+				if (STATE_CONNECTED == state) || (STATE_FRAME_REJECT == state) {
 					frame = new(HdlcFrame)
 					frame.poll = true
 					frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND // only client may send DISC
 					frame.control = HDLC_CONTROL_UA
 					state = STATE_DISCONNECTED
-					segmentToAck = nil        // since we are disconnected there's no need to retransmit unacknowledged frame
-					framesToSend = list.New() // do not transmit anything scheduled for the next poll, we are disconnected
+					failWrites(HdlcErrorDisconnected) // since we are disconnected there's no need to retransmit unacknowledged frame
+					framesToSend = list.New()         // do not transmit anything scheduled for the next poll, we are disconnected
 					framesToSend.PushBack(frame)
+					disconnectPending = true
 				} else if STATE_DISCONNECTED == state {
 					frame = new(HdlcFrame)
 					frame.poll = true
@@ -3126,6 +3641,9 @@ mainLoop:
 				if STATE_DISCONNECTING == state {
 					state = STATE_DISCONNECTED
 					htran.controlAck <- map[string]interface{}{"err": nil}
+				} else if STATE_CONNECTED == state {
+					// server lost the link (e.g. it was restarted or disconnected on inactivity)
+					resetLink(&HdlcLinkResetError{Reason: "disconnected mode reported by server"})
 				} else {
 					// ignore frame
 				}
@@ -3141,30 +3659,8 @@ mainLoop:
 					if nil != err {
 						break mainLoop
 					}
-					if nil != maxInfoFieldLengthTransmit {
-						htran.maxInfoFieldLengthTransmit = *maxInfoFieldLengthTransmit
-					} else {
-						htran.maxInfoFieldLengthTransmit = MaxInfoFieldLength
-					}
-					if nil != maxInfoFieldLengthReceive {
-						htran.maxInfoFieldLengthReceive = *maxInfoFieldLengthReceive
-					} else {
-						htran.maxInfoFieldLengthReceive = MaxInfoFieldLength
-					}
-					if nil != windowSizeTransmit {
-						htran.windowSizeTransmit = *windowSizeTransmit
-					} else {
-						htran.windowSizeTransmit = 1
-					}
-					if nil != windowSizeReceive {
-						htran.windowSizeReceive = *windowSizeReceive
-					} else {
-						htran.windowSizeReceive = 1
-					}
-
-					if htran.windowSizeTransmit != htran.windowSizeReceive {
-						panic("windowSizeTransmit != htran.windowSizeReceive")
-					}
+					htran.negotiateInfoFieldLength(maxInfoFieldLengthTransmit, maxInfoFieldLengthReceive)
+					htran.negotiateWindowSize(windowSizeTransmit, windowSizeReceive)
 
 					if htran.cosem {
 						state = STATE_CONNECTED_SEGMENT_WAIT
@@ -3174,26 +3670,53 @@ mainLoop:
 					}
 					vs = 0
 					vr = 0
-					segmentToAck = nil
+					framesToAck = list.New()
+					window = int(htran.windowSizeTransmit)
+					sentInPoll = 0
 					clientRcnt = 0
-					htran.controlAck <- map[string]interface{}{"err": nil}
+					if recovering {
+						warnLog("link re-established")
+						recovering = false
+					} else {
+						htran.controlAck <- map[string]interface{}{"err": nil}
+					}
 				} else {
 					// ignore frame
 				}
 			} else if HDLC_CONTROL_UI == frame.control {
-				if STATE_CONNECTED == state {
-					panic("handling of UI frames is not implementd")
-				} else {
-					// ignore frame
+				// UI frames are received in any state
+				uiSegments.Write(frame.infoField)
+				if !frame.segmentation {
+					p := make([]byte, uiSegments.Len())
+					copy(p, uiSegments.Bytes())
+					uiSegments.Reset()
+
+					htran.uiReceiveQueueMtx.Lock()
+					if htran.uiReceiveQueue.Len() >= hdlcUIReceiveQueueLength {
+						warnLog("dropping oldest received UI message")
+						htran.uiReceiveQueue.Remove(htran.uiReceiveQueue.Front())
+					}
+					htran.uiReceiveQueue.PushBack(p)
+					htran.uiReceiveQueueMtx.Unlock()
+					select {
+					case htran.uiReceiveAck <- map[string]interface{}{"err": nil}:
+					default:
+						// reader is woken up by acknowledgements already waiting in channel
+					}
 				}
 			} else if HDLC_CONTROL_FRMR == frame.control {
-				warnLog("frame rejected, reason: %s", string(frame.infoField))
-				/*
-					serr := fmt.Sprintf("frame rejected, reason: %s", string(frame.infoField))
-					errorLog(serr)
-					err = errors.New(fmt.Sprintf("frame rejected, reason: %s", string(frame.infoField)))
-					break mainLoop
-				*/
+				e := &HdlcLinkResetError{Reason: "frame rejected"}
+				if err, frmr := decodeFrmr(frame.infoField); nil == err {
+					e.Frmr = frmr
+				} else {
+					// peers not following ISO/IEC 13239 may send just text
+					e.Reason = fmt.Sprintf("frame rejected, reason: %q", frame.infoField)
+				}
+				if htran.client && (STATE_CONNECTED == state) {
+					resetLink(e)
+				} else {
+					warnLog("ignoring FRMR: %s", e)
+				}
 			} else {
 				// ignore frame
 			}
@@ -3207,6 +3730,13 @@ mainLoop:
 		close(htran.readAck)
 		htran.controlAck <- map[string]interface{}{"err": err}
 		close(htran.controlAck)
+		htran.uiSendAck <- map[string]interface{}{"err": err}
+		close(htran.uiSendAck)
+		select {
+		case htran.uiReceiveAck <- map[string]interface{}{"err": err}:
+		default:
+		}
+		close(htran.uiReceiveAck)
 	}
 
 	htran.closedAck <- map[string]interface{}{"err": nil}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -46,6 +47,14 @@ func hdlcTestInit(t *testing.T) {
 	}
 }
 
+// Creates transport reading frames using test implementation 'readFrameImpl', it must be set before transport goroutine starts.
+func newHdlcTestTransport(rw io.ReadWriter, responseTimeout time.Duration, client bool, clientId uint8, logicalDeviceId uint16, physicalDeviceId *uint16, readFrameImpl int) *HdlcTransport {
+	htran := newHdlcTransport(rw, responseTimeout, client, clientId, logicalDeviceId, physicalDeviceId, nil)
+	htran.readFrameImpl = readFrameImpl
+	go htran.handleHdlc()
+	return htran
+}
+
 func TestHdlc_hdlcPipe(t *testing.T) {
 	hdlcTestInit(t)
 
@@ -276,11 +285,9 @@ func TestHdlc_WriteRead_i30_drop_every_5th_frame(t *testing.T) {
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 1 // this read frame implementation drops every 5th frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 1) // this read frame implementation drops every 5th frame
 	defer client.Close()
-	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	server.readFrameImpl = 1 // this read frame implementation drops every 5th frame
+	server := newHdlcTestTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, 1) // this read frame implementation drops every 5th frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(30)
@@ -338,11 +345,9 @@ func TestHdlc_WriteRead_i30_drop_every_3rd_frame(t *testing.T) {
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 2 // this read frame implementation drops every 3rd frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 2) // this read frame implementation drops every 3rd frame
 	defer client.Close()
-	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	server.readFrameImpl = 2 // this read frame implementation drops every 3rd frame
+	server := newHdlcTestTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, 2) // this read frame implementation drops every 3rd frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(30)
@@ -402,11 +407,9 @@ func TestHdlc_WriteRead_i30_drop_random_5(t *testing.T) {
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 3 // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 3) // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
 	defer client.Close()
 	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 3 // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(30)
@@ -812,11 +815,9 @@ func TestHdlc_WriteRead_i22_w3_parallel_transmit_drop_every_5th_frame(t *testing
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 1 // this read frame implementation drops every 5th frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 1) // this read frame implementation drops every 5th frame
 	defer client.Close()
-	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	server.readFrameImpl = 1 // this read frame implementation drops every 5th frame
+	server := newHdlcTestTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, 1) // this read frame implementation drops every 5th frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(22)
@@ -932,11 +933,9 @@ func TestHdlc_WriteRead_i22_w3_parallel_transmit_drop_every_3rd_frame(t *testing
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 2 // this read frame implementation drops every 3rd frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 2) // this read frame implementation drops every 3rd frame
 	defer client.Close()
-	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	server.readFrameImpl = 2 // this read frame implementation drops every 3rd frame
+	server := newHdlcTestTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, 2) // this read frame implementation drops every 3rd frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(22)
@@ -1054,11 +1053,9 @@ func TestHdlc_WriteRead_i22_w3_parallel_transmit_drop_random_5(t *testing.T) {
 	physicalDeviceId := new(uint16)
 	*physicalDeviceId = 3
 
-	client := NewHdlcTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
-	client.readFrameImpl = 3 // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
+	client := newHdlcTestTransport(crw, time.Duration(1)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, 3) // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
 	defer client.Close()
-	server := NewHdlcTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
-	server.readFrameImpl = 3 // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
+	server := newHdlcTestTransport(srw, time.Duration(1)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, 3) // this read frame implementation randomly drops every 1st, 2nd, 3rd, 4th or 5th frame
 	defer server.Close()
 
 	maxInfoFieldLengthTransmit := uint16(22)
@@ -1161,3 +1158,615 @@ func TestHdlc_WriteRead_i22_w3_parallel_transmit_drop_random_5(t *testing.T) {
 	t.Logf("%s\n", <-chf)
 
 }
+
+func TestHdlc_decodeLinkParameters_windowSize(t *testing.T) {
+	htran := newHdlcFramer(nil, true, 1, 1, nil, HDLC_ADDRESS_LENGTH_1)
+
+	// some meters encode window size on single byte
+	frame := new(HdlcFrame)
+	frame.infoField = []byte{0x81, 0x80, 0x0C, 0x05, 0x01, 0x80, 0x06, 0x01, 0x80, 0x07, 0x01, 0x01, 0x08, 0x01, 0x03}
+	err, _, _, windowSizeTransmit, windowSizeReceive := htran.decodeLinkParameters(frame)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if (1 != *windowSizeTransmit) || (3 != *windowSizeReceive) {
+		t.Fatalf("wrong window size: %d, %d", *windowSizeTransmit, *windowSizeReceive)
+	}
+
+	// peer's receive window limits own transmit window and vice versa
+	htran.windowSizeTransmit = 7
+	htran.windowSizeReceive = 7
+	htran.negotiateWindowSize(windowSizeTransmit, windowSizeReceive)
+	if (3 != htran.windowSizeTransmit) || (1 != htran.windowSizeReceive) {
+		t.Fatalf("wrong window size: %d, %d", htran.windowSizeTransmit, htran.windowSizeReceive)
+	}
+
+	// window size is 1 if not present, at most 7
+	windowSizeTransmit = nil
+	*windowSizeReceive = 100
+	htran.windowSizeTransmit = 7
+	htran.windowSizeReceive = 7
+	htran.negotiateWindowSize(windowSizeTransmit, windowSizeReceive)
+	if (7 != htran.windowSizeTransmit) || (1 != htran.windowSizeReceive) {
+		t.Fatalf("wrong window size: %d, %d", htran.windowSizeTransmit, htran.windowSizeReceive)
+	}
+}
+
+func TestHdlc_SendSNRM_windowSize(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	clientId := uint8(1)
+	logicalDeviceId := uint16(2)
+
+	defer func(windowSize uint32) { HdlcWindowSize = windowSize }(HdlcWindowSize)
+	HdlcWindowSize = 7
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, clientId, logicalDeviceId, nil, nil)
+	defer client.Close()
+
+	HdlcWindowSize = 3
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, clientId, logicalDeviceId, nil, nil)
+	defer server.Close()
+
+	maxInfoFieldLengthTransmit := uint16(30)
+	maxInfoFieldLengthReceive := uint16(30)
+	err := client.SendSNRM(&maxInfoFieldLengthTransmit, &maxInfoFieldLengthReceive)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+	if (3 != client.windowSizeTransmit) || (3 != client.windowSizeReceive) {
+		t.Fatalf("wrong window size: %d, %d", client.windowSizeTransmit, client.windowSizeReceive)
+	}
+
+	bc := generateBytes(1000)
+	ch := make(chan error, 1)
+	go func() {
+		_, err := client.Write(bc)
+		ch <- err
+	}()
+
+	bs := make([]byte, len(bc))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bc, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+	err = <-ch
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+}
+
+// Connection delivering written data to peer after delay, simulates link with high latency.
+type tDelayedConn struct {
+	net.Conn
+	delay  time.Duration
+	writes chan tDelayedWrite
+	closed chan bool
+}
+
+type tDelayedWrite struct {
+	at time.Time
+	p  []byte
+}
+
+func newDelayedPipe(delay time.Duration) (conn1 net.Conn, conn2 net.Conn) {
+	c1, c2 := net.Pipe()
+	return newDelayedConn(c1, delay), newDelayedConn(c2, delay)
+}
+
+func newDelayedConn(conn net.Conn, delay time.Duration) *tDelayedConn {
+	dconn := &tDelayedConn{Conn: conn, delay: delay, writes: make(chan tDelayedWrite, 1000), closed: make(chan bool)}
+	go func() {
+		for {
+			select {
+			case w := <-dconn.writes:
+				time.Sleep(time.Until(w.at))
+				_, err := dconn.Conn.Write(w.p)
+				if nil != err {
+					return
+				}
+			case <-dconn.closed:
+				return
+			}
+		}
+	}()
+	return dconn
+}
+
+func (conn *tDelayedConn) Write(p []byte) (n int, err error) {
+	conn.writes <- tDelayedWrite{at: time.Now().Add(conn.delay), p: append([]byte(nil), p...)}
+	return len(p), nil
+}
+
+func (conn *tDelayedConn) Close() error {
+	close(conn.closed)
+	return conn.Conn.Close()
+}
+
+// Returns time it takes to transfer 'b' from client to server over link with latency 'delay'.
+func hdlcTransferTime(t *testing.T, windowSize uint32, delay time.Duration, b []byte) time.Duration {
+	defer func(windowSize uint32) { HdlcWindowSize = windowSize }(HdlcWindowSize)
+	HdlcWindowSize = windowSize
+
+	crw, srw := newDelayedPipe(delay)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := NewHdlcTransport(crw, 10*delay, true, 1, 2, nil, nil)
+	defer client.Close()
+	server := NewHdlcTransport(srw, 10*delay, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	maxInfoFieldLengthTransmit := uint16(128)
+	maxInfoFieldLengthReceive := uint16(128)
+	err := client.SendSNRM(&maxInfoFieldLengthTransmit, &maxInfoFieldLengthReceive)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+	if windowSize != client.windowSizeTransmit {
+		t.Fatalf("wrong window size: %d", client.windowSizeTransmit)
+	}
+
+	start := time.Now()
+	ch := make(chan error, 1)
+	go func() {
+		_, err := client.Write(b)
+		ch <- err
+	}()
+	bs := make([]byte, len(b))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(b, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+	err = <-ch
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	return time.Since(start)
+}
+
+func TestHdlc_WriteRead_windowThroughput(t *testing.T) {
+	delay := time.Duration(10) * time.Millisecond
+	b := generateBytes(3000)
+
+	d1 := hdlcTransferTime(t, 1, delay, b)
+	d7 := hdlcTransferTime(t, 7, delay, b)
+	t.Logf("window 1: %v, window 7: %v", d1, d7)
+	if d7*3 > d1 {
+		t.Fatalf("no throughput improvement, window 1: %v, window 7: %v", d1, d7)
+	}
+}
+
+// Connection counting I frames written to it, I frame number 'drop' (counted from 1) is not delivered to peer.
+type tIFrameCountingConn struct {
+	net.Conn
+	drop    int
+	mtx     sync.Mutex
+	iFrames int
+}
+
+func (conn *tIFrameCountingConn) Write(p []byte) (n int, err error) {
+	// each frame is written at once: flag, frame format (2 bytes), destination and source address (last byte has bit 0 set), control
+	i := 3
+	for addr := 0; (addr < 2) && (i < len(p)); i++ {
+		if 0x01 == p[i]&0x01 {
+			addr += 1
+		}
+	}
+	if (i < len(p)) && (0x00 == p[i]&0x01) {
+		conn.mtx.Lock()
+		conn.iFrames += 1
+		drop := conn.iFrames == conn.drop
+		conn.mtx.Unlock()
+		if drop {
+			return len(p), nil
+		}
+	}
+	return conn.Conn.Write(p)
+}
+
+func (conn *tIFrameCountingConn) count() int {
+	conn.mtx.Lock()
+	defer conn.mtx.Unlock()
+	return conn.iFrames
+}
+
+func TestHdlc_WriteRead_retransmission(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	defer func(windowSize uint32) { HdlcWindowSize = windowSize }(HdlcWindowSize)
+	HdlcWindowSize = 7
+
+	cconn := &tIFrameCountingConn{Conn: crw, drop: 2}
+	client := NewHdlcTransport(cconn, time.Duration(1)*time.Second, true, 1, 2, nil, nil)
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(1)*time.Second, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	maxInfoFieldLengthTransmit := uint16(128)
+	maxInfoFieldLengthReceive := uint16(128)
+	err := client.SendSNRM(&maxInfoFieldLengthTransmit, &maxInfoFieldLengthReceive)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	// 7 I frames fill the window, peer accepts only in sequence frames so all frames following the lost one are transmitted again
+	bc := generateBytes(6*128 + 50)
+	ch := make(chan error, 1)
+	go func() {
+		_, err := client.Write(bc)
+		ch <- err
+	}()
+	bs := make([]byte, len(bc))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bc, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+	err = <-ch
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if 7+6 != cconn.count() {
+		t.Fatalf("%d I frames retransmitted, expected 6", cconn.count()-7)
+	}
+}
+
+func TestHdlc_SendSNRM_maxInfoFieldLength(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	clientId := uint8(1)
+	logicalDeviceId := uint16(2)
+
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, clientId, logicalDeviceId, nil, nil)
+	defer client.Close()
+
+	defer func(maxInfoFieldLength uint16) { MaxInfoFieldLength = maxInfoFieldLength }(MaxInfoFieldLength)
+	MaxInfoFieldLength = 40
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, clientId, logicalDeviceId, nil, nil)
+	defer server.Close()
+
+	// server lowers client's transmit length, client's receive length is kept
+	maxInfoFieldLengthTransmit := uint16(60)
+	maxInfoFieldLengthReceive := uint16(20)
+	err := client.SendSNRM(&maxInfoFieldLengthTransmit, &maxInfoFieldLengthReceive)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+	if (40 != client.maxInfoFieldLengthTransmit) || (20 != client.maxInfoFieldLengthReceive) {
+		t.Fatalf("wrong client max info field length: %d, %d", client.maxInfoFieldLengthTransmit, client.maxInfoFieldLengthReceive)
+	}
+
+	bc := generateBytes(1000)
+	_, err = client.Write(bc)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	bs := make([]byte, len(bc))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bc, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+	if (20 != server.maxInfoFieldLengthTransmit) || (40 != server.maxInfoFieldLengthReceive) {
+		t.Fatalf("wrong server max info field length: %d, %d", server.maxInfoFieldLengthTransmit, server.maxInfoFieldLengthReceive)
+	}
+
+	_, err = server.Write(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	n, err = client.Read(bc)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bs, bc[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+}
+
+func TestHdlc_hdlcTransportReceive_maxPduSize(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, 1, 2, nil, nil)
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	err := client.SendSNRM(nil, nil)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	pdu := generateBytes(1000)
+	for _, maxPduSize := range []uint16{1000, 999} {
+		err = hdlcTransportSend(client, llcHeaderCommand, pdu)
+		if nil != err {
+			t.Fatalf("%v", err)
+		}
+		p, err := hdlcTransportReceive(server, llcHeaderCommand, maxPduSize)
+		if 1000 == maxPduSize {
+			if nil != err {
+				t.Fatalf("%v", err)
+			}
+			if !bytes.Equal(pdu, p) {
+				t.Fatalf("bytes does not match")
+			}
+		} else if nil == err {
+			t.Fatalf("pdu exceeding max receive pdu size received")
+		}
+	}
+}
+
+func TestHdlc_BroadcastUI(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	clientId := uint8(1)
+	logicalDeviceId := uint16(2)
+	physicalDeviceId := new(uint16)
+	*physicalDeviceId = 3
+
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, clientId, logicalDeviceId, physicalDeviceId, nil)
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, clientId, logicalDeviceId, physicalDeviceId, nil)
+	defer server.Close()
+
+	err := server.BroadcastUI([]byte{0x01})
+	if HdlcErrorNotClient != err {
+		t.Fatalf("server must not broadcast")
+	}
+
+	// UI frames are transmitted also when connection is not established, message is segmented
+	b := generateBytes(1000)
+	err = client.BroadcastUI(b)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	p, err := server.ReadUI()
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(b, p) {
+		t.Fatalf("bytes does not match")
+	}
+}
+
+func TestHdlc_WriteUI(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, 1, 2, nil, nil)
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	err := client.SendSNRM(nil, nil)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	// server transmits UI frame when it is polled
+	b := []byte{0x01, 0x02, 0x03}
+	err = server.WriteUI(b)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	p, err := client.ReadUI()
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(b, p) {
+		t.Fatalf("bytes does not match")
+	}
+
+	// I frames are not affected by UI frames
+	bc := generateBytes(100)
+	_, err = client.Write(bc)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	bs := make([]byte, len(bc))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bc, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+}
+
+func TestHdlc_decodeFrmr(t *testing.T) {
+	frame := &HdlcFrame{direction: HDLC_FRAME_DIRECTION_SERVER_INBOUND, controlField: 0x54}
+	err, frmr := decodeFrmr(encodeFrmr(frame, 3, 5, HDLC_FRMR_Z))
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if (0x54 != frmr.RejectedControl) || (3 != frmr.Vs) || (5 != frmr.Vr) || frmr.Response || frmr.W || frmr.X || frmr.Y || !frmr.Z {
+		t.Fatalf("wrong FRMR: %s", frmr)
+	}
+
+	err, _ = decodeFrmr([]byte("unexpected frame"))
+	if HdlcErrorInfoFieldFormat != err {
+		t.Fatalf("malformed FRMR decoded")
+	}
+}
+
+// Returns HdlcLinkResetError if 'err' is one.
+func linkResetError(t *testing.T, err error) *HdlcLinkResetError {
+	if nil == err {
+		t.Fatalf("link not reset")
+	}
+	e, ok := err.(*HdlcLinkResetError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return e
+}
+
+func TestHdlc_linkReset_FRMR(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := NewHdlcTransport(crw, time.Duration(100)*time.Millisecond, true, 1, 2, nil, nil)
+	defer client.Close()
+	server := newHdlcTestTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, 4) // this read frame implementation corrupts N(R) of every 5th I frame
+	defer server.Close()
+
+	err := client.SendSNRM(nil, nil)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	bs := make([]byte, 100)
+	for i := 1; i <= 6; i++ {
+		bc := []byte{byte(i)}
+		_, err = client.Write(bc)
+		if 5 == i {
+			// server rejects frame with invalid N(R), client resets the link
+			e := linkResetError(t, err)
+			if (nil == e.Frmr) || !e.Frmr.Z {
+				t.Fatalf("wrong FRMR diagnostics: %v", e)
+			}
+			e = linkResetError(t, func() error { _, err := client.Read(bs); return err }())
+			if (nil == e.Frmr) || !e.Frmr.Z {
+				t.Fatalf("wrong FRMR diagnostics: %v", e)
+			}
+			continue
+		}
+		if nil != err {
+			t.Fatalf("%v", err)
+		}
+		n, err := server.Read(bs)
+		if nil != err {
+			t.Fatalf("%v", err)
+		}
+		if !bytes.Equal(bc, bs[0:n]) {
+			t.Fatalf("bytes does not match")
+		}
+	}
+}
+
+func TestHdlc_linkReset_sequenceError(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := newHdlcTestTransport(crw, time.Duration(100)*time.Millisecond, true, 1, 2, nil, 4) // this read frame implementation corrupts N(R) of every 5th I frame
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	err := client.SendSNRM(nil, nil)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	bc := make([]byte, 100)
+	for i := 1; i <= 6; i++ {
+		bs := []byte{byte(i)}
+		_, err = server.Write(bs)
+		if 5 == i {
+			// client detects invalid N(R) and resets the link, frame in transit is lost
+			linkResetError(t, err)
+			linkResetError(t, func() error { _, err := client.Read(bc); return err }())
+			continue
+		}
+		if nil != err {
+			t.Fatalf("%v", err)
+		}
+		n, err := client.Read(bc)
+		if nil != err {
+			t.Fatalf("%v", err)
+		}
+		if !bytes.Equal(bs, bc[0:n]) {
+			t.Fatalf("bytes does not match")
+		}
+	}
+}
+
+func TestHdlc_linkReset_DM(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer crw.Close()
+	defer srw.Close()
+
+	client := newHdlcTestTransport(crw, time.Duration(100)*time.Millisecond, true, 1, 2, nil, 5) // this read frame implementation replaces 5th RR frame with DM
+	defer client.Close()
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	err := client.SendSNRM(nil, nil)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	defer client.SendDISC()
+
+	// client polls server while idle and receives DM
+	bc := make([]byte, 100)
+	_, err = client.Read(bc)
+	e := linkResetError(t, err)
+	if nil != e.Frmr {
+		t.Fatalf("unexpected FRMR diagnostics: %v", e)
+	}
+
+	// link is re-established
+	bc = generateBytes(1000)
+	_, err = client.Write(bc)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	bs := make([]byte, len(bc))
+	n, err := server.Read(bs)
+	if nil != err {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(bc, bs[0:n]) {
+		t.Fatalf("bytes does not match")
+	}
+}
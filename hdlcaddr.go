@@ -0,0 +1,310 @@
+package gocosem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Server address of HDLC device as passed to HdlcConnect().
+type HdlcAddress struct {
+	LogicalDevice  uint16
+	PhysicalDevice *uint16 // nil if address is 1 byte long
+	AddressLength  int     // server address length in bytes: 1, 2 or 4
+}
+
+// Device responding to SNRM found by HdlcDiscover().
+type HdlcDevice struct {
+	HdlcAddress
+	Accepted                   bool   // false if device answered with DM, e.g. because it is connected to other client
+	MaxInfoFieldLengthTransmit uint16 // announced by device in UA, 128 if not announced
+	MaxInfoFieldLengthReceive  uint16 // announced by device in UA, 128 if not announced
+	WindowSizeTransmit         uint32 // announced by device in UA, 1 if not announced
+	WindowSizeReceive          uint32 // announced by device in UA, 1 if not announced
+}
+
+func (a *HdlcAddress) String() string {
+	if nil == a.PhysicalDevice {
+		return fmt.Sprintf("logical device %d (%d byte address)", a.LogicalDevice, a.AddressLength)
+	}
+	return fmt.Sprintf("logical device %d, physical device %d (%d byte address)", a.LogicalDevice, *a.PhysicalDevice, a.AddressLength)
+}
+
+/*
+Returns physical device address computed from meter serial number as lower 4
+decimal digits plus 16, convention used by many meter vendors. Resulting
+address needs 4 byte server address.
+*/
+func HdlcPhysicalDeviceFromSerial(serialNumber string) (physicalDevice uint16, err error) {
+	digits := serialNumber
+	if len(digits) > 4 {
+		digits = digits[len(digits)-4:]
+	}
+	if 0 == len(digits) {
+		err = fmt.Errorf("empty serial number")
+		errorLog("%s", err)
+		return 0, err
+	}
+	for _, c := range digits {
+		if (c < '0') || (c > '9') {
+			err = fmt.Errorf("serial number does not end with decimal digits: %s", serialNumber)
+			errorLog("%s", err)
+			return 0, err
+		}
+		physicalDevice = physicalDevice*10 + uint16(c-'0')
+	}
+	return physicalDevice + 16, nil
+}
+
+// Returns shortest server address length fitting in 'logicalDevice' and 'physicalDevice' (nil if not used).
+func HdlcServerAddressLength(logicalDevice uint16, physicalDevice *uint16) int {
+	if nil == physicalDevice {
+		if logicalDevice <= 0x7F {
+			return HDLC_ADDRESS_LENGTH_1
+		}
+		return HDLC_ADDRESS_LENGTH_4
+	}
+	if (logicalDevice <= 0x7F) && (*physicalDevice <= 0x7F) {
+		return HDLC_ADDRESS_LENGTH_2
+	}
+	return HDLC_ADDRESS_LENGTH_4
+}
+
+// Returns address of 'logicalDevice' on meter with serial number 'serialNumber', see HdlcPhysicalDeviceFromSerial().
+func HdlcAddressFromSerial(logicalDevice uint16, serialNumber string) (a *HdlcAddress, err error) {
+	physicalDevice, err := HdlcPhysicalDeviceFromSerial(serialNumber)
+	if nil != err {
+		return nil, err
+	}
+	a = new(HdlcAddress)
+	a.LogicalDevice = logicalDevice
+	a.PhysicalDevice = &physicalDevice
+	a.AddressLength = HDLC_ADDRESS_LENGTH_4
+	return a, nil
+}
+
+// Returns addresses of 'logicalDevice' on physical devices 'firstPhysicalDevice' up to 'lastPhysicalDevice' including, all of the same length.
+func HdlcAddressRange(logicalDevice uint16, firstPhysicalDevice uint16, lastPhysicalDevice uint16) (addresses []*HdlcAddress) {
+	addressLength := HdlcServerAddressLength(logicalDevice, &lastPhysicalDevice)
+	for physicalDevice := uint32(firstPhysicalDevice); physicalDevice <= uint32(lastPhysicalDevice); physicalDevice++ {
+		a := new(HdlcAddress)
+		a.LogicalDevice = logicalDevice
+		a.PhysicalDevice = new(uint16)
+		*a.PhysicalDevice = uint16(physicalDevice)
+		a.AddressLength = addressLength
+		addresses = append(addresses, a)
+	}
+	return addresses
+}
+
+// Returns all-station (broadcast) address of length 'addressLength'.
+func HdlcAllStationsAddress(addressLength int) *HdlcAddress {
+	a := new(HdlcAddress)
+	a.AddressLength = addressLength
+	switch addressLength {
+	case HDLC_ADDRESS_LENGTH_1:
+		a.LogicalDevice = 0x7F
+	case HDLC_ADDRESS_LENGTH_2:
+		a.LogicalDevice = 0x7F
+		a.PhysicalDevice = new(uint16)
+		*a.PhysicalDevice = 0x7F
+	default:
+		a.LogicalDevice = 0x3FFF
+		a.PhysicalDevice = new(uint16)
+		*a.PhysicalDevice = 0x3FFF
+	}
+	return a
+}
+
+/*
+Probes 'addresses' on line 'rw' (serial port, RS-485 bus, HDLC over TCP
+gateway connection) by SNRM sent from 'applicationClient' and returns devices
+which answered within 'responseTimeout'. SNRM proposes MaxInfoFieldLength and
+HdlcWindowSize so that devices announce what they support. Devices accepting
+SNRM are disconnected again by DISC. Probing all-station address (see
+HdlcAllStationsAddress()) finds devices of unknown address, on a bus this
+works only if single device is attached since responses would collide. 'rw'
+must support read deadline (net.Conn or *os.File).
+*/
+func HdlcDiscover(rw io.ReadWriter, applicationClient uint16, addresses []*HdlcAddress, responseTimeout time.Duration) (devices []*HdlcDevice, err error) {
+	return HdlcDiscoverContext(context.Background(), rw, applicationClient, addresses, responseTimeout)
+}
+
+// Same as HdlcDiscover() but probing is aborted when 'ctx' is done, devices found so far are returned then.
+func HdlcDiscoverContext(ctx context.Context, rw io.ReadWriter, applicationClient uint16, addresses []*HdlcAddress, responseTimeout time.Duration) (devices []*HdlcDevice, err error) {
+	conn, ok := rw.(tReadDeadliner)
+	if !ok {
+		err = fmt.Errorf("line must support read deadline")
+		errorLog("%s", err)
+		return nil, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	for _, a := range addresses {
+		select {
+		case <-ctx.Done():
+			return devices, ctx.Err()
+		default:
+		}
+		err, found := discoverHdlcAddress(rw, conn, uint8(applicationClient), a, responseTimeout)
+		if nil != err {
+			return devices, err
+		}
+		devices = append(devices, found...)
+	}
+	return devices, nil
+}
+
+func discoverHdlcAddress(rw io.ReadWriter, conn tReadDeadliner, clientId uint8, a *HdlcAddress, responseTimeout time.Duration) (err error, devices []*HdlcDevice) {
+	err, probed := encodeHdlcRawServerAddress(a)
+	if nil != err {
+		return err, nil
+	}
+	broadcast := isHdlcBroadcastAddress(probed)
+
+	htran := newHdlcFramer(rw, true, clientId, a.LogicalDevice, a.PhysicalDevice, a.AddressLength)
+	frame := new(HdlcFrame)
+	frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
+	frame.poll = true
+	frame.control = HDLC_CONTROL_SNRM
+	maxInfoFieldLength := MaxInfoFieldLength
+	windowSize := HdlcWindowSize
+	err = htran.encodeLinkParameters(frame, &maxInfoFieldLength, &maxInfoFieldLength, &windowSize, &windowSize)
+	if nil != err {
+		return err, nil
+	}
+	err = htran.writeFrame(frame)
+	if nil != err {
+		return err, nil
+	}
+
+	// all stations may answer to broadcast so wait for responses until timeout
+	conn.SetReadDeadline(time.Now().Add(responseTimeout))
+	for {
+		err, p := readHdlcRawFrame(rw)
+		if nil != err {
+			if isTimeOutErr(err) {
+				break
+			}
+			return err, devices
+		}
+		e, dst, src, _ := decodeHdlcRawFrameHeader(p)
+		if (nil != e) || (1 != len(dst)) || (clientId != dst[0]) {
+			continue
+		}
+		if !broadcast && !bytes.Equal(probed, src) {
+			continue
+		}
+		device := decodeHdlcDiscoveryResponse(p, clientId, src)
+		if nil == device {
+			continue
+		}
+		devices = append(devices, device)
+		if !broadcast {
+			break
+		}
+	}
+
+	for _, device := range devices {
+		if device.Accepted {
+			err = disconnectHdlcDevice(rw, conn, clientId, device, responseTimeout)
+			if nil != err {
+				return err, devices
+			}
+		}
+	}
+	return nil, devices
+}
+
+// Returns device answering SNRM by UA or DM in raw frame 'p', nil if frame is other response.
+func decodeHdlcDiscoveryResponse(p []byte, clientId uint8, src []byte) (device *HdlcDevice) {
+	device = new(HdlcDevice)
+	device.LogicalDevice, device.PhysicalDevice, device.AddressLength = decodeHdlcRawServerAddress(src)
+
+	htran := newHdlcFramer(bytes.NewBuffer(p), true, clientId, device.LogicalDevice, device.PhysicalDevice, device.AddressLength)
+	err, frame := htran.readFrame(HDLC_FRAME_DIRECTION_CLIENT_INBOUND)
+	if nil != err {
+		return nil
+	}
+	if HDLC_CONTROL_DM == frame.control {
+		return device
+	}
+	if HDLC_CONTROL_UA != frame.control {
+		return nil
+	}
+	err, maxInfoFieldLengthTransmit, maxInfoFieldLengthReceive, windowSizeTransmit, windowSizeReceive := htran.decodeLinkParameters(frame)
+	if nil != err {
+		warnLog("%s: malformed link parameters in UA", &device.HdlcAddress)
+		return nil
+	}
+	device.Accepted = true
+	device.MaxInfoFieldLengthTransmit = 128
+	if nil != maxInfoFieldLengthTransmit {
+		device.MaxInfoFieldLengthTransmit = *maxInfoFieldLengthTransmit
+	}
+	device.MaxInfoFieldLengthReceive = 128
+	if nil != maxInfoFieldLengthReceive {
+		device.MaxInfoFieldLengthReceive = *maxInfoFieldLengthReceive
+	}
+	device.WindowSizeTransmit = 1
+	if nil != windowSizeTransmit {
+		device.WindowSizeTransmit = *windowSizeTransmit
+	}
+	device.WindowSizeReceive = 1
+	if nil != windowSizeReceive {
+		device.WindowSizeReceive = *windowSizeReceive
+	}
+	return device
+}
+
+// Sends DISC to 'device' and waits for its response or timeout.
+func disconnectHdlcDevice(rw io.ReadWriter, conn tReadDeadliner, clientId uint8, device *HdlcDevice, responseTimeout time.Duration) (err error) {
+	err, address := encodeHdlcRawServerAddress(&device.HdlcAddress)
+	if nil != err {
+		return err
+	}
+	htran := newHdlcFramer(rw, true, clientId, device.LogicalDevice, device.PhysicalDevice, device.AddressLength)
+	frame := new(HdlcFrame)
+	frame.direction = HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND
+	frame.poll = true
+	frame.control = HDLC_CONTROL_DISC
+	err = htran.writeFrame(frame)
+	if nil != err {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(responseTimeout))
+	for {
+		err, p := readHdlcRawFrame(rw)
+		if nil != err {
+			if isTimeOutErr(err) {
+				warnLog("%s: no response to DISC", &device.HdlcAddress)
+				return nil
+			}
+			return err
+		}
+		e, dst, src, _ := decodeHdlcRawFrameHeader(p)
+		if (nil == e) && (1 == len(dst)) && (clientId == dst[0]) && bytes.Equal(address, src) {
+			return nil
+		}
+	}
+}
+
+// Returns server address bytes as transmitted on the line.
+func encodeHdlcRawServerAddress(a *HdlcAddress) (err error, p []byte) {
+	if !((HDLC_ADDRESS_LENGTH_1 == a.AddressLength) || (HDLC_ADDRESS_LENGTH_2 == a.AddressLength) || (HDLC_ADDRESS_LENGTH_4 == a.AddressLength)) {
+		err = fmt.Errorf("wrong server address length: %d", a.AddressLength)
+		errorLog("%s", err)
+		return err, nil
+	}
+	htran := newHdlcFramer(nil, true, 0, a.LogicalDevice, a.PhysicalDevice, a.AddressLength)
+	frame := new(HdlcFrame)
+	frame.content = new(bytes.Buffer)
+	err = htran.encodeServerAddress(frame)
+	if nil != err {
+		return err, nil
+	}
+	return nil, frame.content.Bytes()
+}
@@ -0,0 +1,131 @@
+package gocosem
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHdlcAddr_PhysicalDeviceFromSerial(t *testing.T) {
+	for serialNumber, expected := range map[string]uint16{"12345678": 5694, "LGZ0012": 28, "123": 139} {
+		physicalDevice, err := HdlcPhysicalDeviceFromSerial(serialNumber)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if expected != physicalDevice {
+			t.Fatalf("%s: expected %d, got %d", serialNumber, expected, physicalDevice)
+		}
+	}
+	_, err := HdlcPhysicalDeviceFromSerial("1234X")
+	if nil == err {
+		t.Fatalf("error expected")
+	}
+
+	a, err := HdlcAddressFromSerial(1, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (1 != a.LogicalDevice) || (5694 != *a.PhysicalDevice) || (HDLC_ADDRESS_LENGTH_4 != a.AddressLength) {
+		t.Fatalf("unexpected address: %s", a)
+	}
+}
+
+func TestHdlcAddr_ServerAddressLength(t *testing.T) {
+	physicalDevice := uint16(0x7F)
+	if HDLC_ADDRESS_LENGTH_1 != HdlcServerAddressLength(1, nil) {
+		t.Fatalf("1 byte expected")
+	}
+	if HDLC_ADDRESS_LENGTH_2 != HdlcServerAddressLength(1, &physicalDevice) {
+		t.Fatalf("2 bytes expected")
+	}
+	physicalDevice = 0x80
+	if HDLC_ADDRESS_LENGTH_4 != HdlcServerAddressLength(1, &physicalDevice) {
+		t.Fatalf("4 bytes expected")
+	}
+
+	addresses := HdlcAddressRange(1, 0x7E, 0x80)
+	if 3 != len(addresses) {
+		t.Fatalf("wrong number of addresses: %d", len(addresses))
+	}
+	for i, a := range addresses {
+		if (0x7E+uint16(i) != *a.PhysicalDevice) || (HDLC_ADDRESS_LENGTH_4 != a.AddressLength) {
+			t.Fatalf("unexpected address: %s", a)
+		}
+	}
+}
+
+// Meter answering SNRM and DISC sent to its address or to all-station address.
+func serveTestHdlcMeter(t *testing.T, line net.Conn, clientId uint8, a *HdlcAddress, discs chan bool) {
+	err, address := encodeHdlcRawServerAddress(a)
+	if nil != err {
+		t.Errorf("%s\n", err)
+		return
+	}
+	htran := newHdlcFramer(line, false, clientId, a.LogicalDevice, a.PhysicalDevice, a.AddressLength)
+	for {
+		err, p := readHdlcRawFrame(line)
+		if nil != err {
+			return
+		}
+		err, dst, _, control := decodeHdlcRawFrameHeader(p)
+		if (nil != err) || !(bytes.Equal(address, dst) || isHdlcBroadcastAddress(dst)) {
+			continue
+		}
+		frame := &HdlcFrame{direction: HDLC_FRAME_DIRECTION_SERVER_OUTBOUND, control: HDLC_CONTROL_UA, poll: true}
+		if 0x83 == control&0xEF {
+			maxInfoFieldLengthTransmit := uint16(256)
+			maxInfoFieldLengthReceive := uint16(128)
+			windowSize := uint32(1)
+			err = htran.encodeLinkParameters(frame, &maxInfoFieldLengthTransmit, &maxInfoFieldLengthReceive, &windowSize, &windowSize)
+			if nil != err {
+				t.Errorf("%s\n", err)
+				return
+			}
+		} else if 0x43 == control&0xEF {
+			discs <- true
+		} else {
+			continue
+		}
+		err = htran.writeFrame(frame)
+		if nil != err {
+			return
+		}
+	}
+}
+
+func TestHdlcAddr_Discover(t *testing.T) {
+	meter, err := HdlcAddressFromSerial(1, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	discs := make(chan bool, 10)
+	line, bus := net.Pipe()
+	defer line.Close()
+	go serveTestHdlcMeter(t, bus, 0x21, meter, discs)
+
+	for _, addresses := range [][]*HdlcAddress{
+		HdlcAddressRange(1, 5690, 5699),
+		[]*HdlcAddress{HdlcAllStationsAddress(HDLC_ADDRESS_LENGTH_4)},
+	} {
+		devices, err := HdlcDiscover(line, 0x21, addresses, 50*time.Millisecond)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if 1 != len(devices) {
+			t.Fatalf("wrong number of devices: %d", len(devices))
+		}
+		device := devices[0]
+		if (1 != device.LogicalDevice) || (5694 != *device.PhysicalDevice) || (HDLC_ADDRESS_LENGTH_4 != device.AddressLength) {
+			t.Fatalf("unexpected device address: %s", &device.HdlcAddress)
+		}
+		if !device.Accepted || (256 != device.MaxInfoFieldLengthTransmit) || (128 != device.MaxInfoFieldLengthReceive) || (1 != device.WindowSizeTransmit) || (1 != device.WindowSizeReceive) {
+			t.Fatalf("unexpected device parameters: %+v", device)
+		}
+		select {
+		case <-discs:
+		case <-time.After(time.Second):
+			t.Fatalf("device not disconnected")
+		}
+	}
+}
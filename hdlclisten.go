@@ -0,0 +1,520 @@
+package gocosem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+HDLC link accepted by HdlcListener. Link is established by SNRM sent by
+client to server address LogicalDevice (and PhysicalDevice if server address
+is 2 or 4 bytes long) from client address ClientId. Read() returns io.EOF
+after client disconnected the link by DISC or the line was closed.
+*/
+type HdlcLink struct {
+	LogicalDevice  uint16
+	PhysicalDevice *uint16 // nil if server address is 1 byte long
+	ClientId       uint8   // client address byte as passed to HdlcConnect()
+	AddressLength  int     // server address length in bytes
+
+	line  *tHdlcLine
+	key   string
+	htran *HdlcTransport
+	conn  *tHdlcLinkConn
+
+	announced bool // set by transport goroutine only
+	closeOnce sync.Once
+}
+
+type HdlcLinkHandler interface {
+	HandleLink(link *HdlcLink)
+}
+
+// Adapter allowing use of ordinary function as HdlcLinkHandler.
+type HdlcLinkHandlerFunc func(link *HdlcLink)
+
+func (f HdlcLinkHandlerFunc) HandleLink(link *HdlcLink) {
+	f(link)
+}
+
+/*
+Accepts HDLC links on serial lines or TCP connections. Each link established
+by client is passed to handler in separate goroutine and closed after
+handler returns. Links sharing one line are demultiplexed by server and
+client address.
+*/
+type HdlcListener struct {
+	handler HdlcLinkHandler
+
+	mtx     sync.Mutex
+	closed  bool
+	closers map[io.Closer]bool
+}
+
+// Line (serial port or TCP connection) carrying HDLC links of several server and client addresses.
+type tHdlcLine struct {
+	rwc     io.ReadWriteCloser
+	handler HdlcLinkHandler
+
+	wmtx sync.Mutex // frames of different links must not interleave
+
+	mtx   sync.Mutex
+	links map[string]*HdlcLink // by destination and source address bytes as received
+}
+
+// Stream of frames routed to single link, writes go directly to the line.
+type tHdlcLinkConn struct {
+	line io.Writer
+
+	mtx      sync.Mutex
+	buf      bytes.Buffer
+	closed   bool
+	err      error // returned by Read() after close
+	deadline time.Time
+	ready    chan bool
+}
+
+// Returned by Read() of tHdlcLinkConn when read deadline expires.
+type tHdlcTimeoutError struct{}
+
+func (e tHdlcTimeoutError) Error() string   { return "i/o timeout" }
+func (e tHdlcTimeoutError) Timeout() bool   { return true }
+func (e tHdlcTimeoutError) Temporary() bool { return true }
+
+func NewHdlcListener(handler HdlcLinkHandler) *HdlcListener {
+	hl := new(HdlcListener)
+	hl.handler = handler
+	hl.closers = make(map[io.Closer]bool)
+	return hl
+}
+
+// Accepts HDLC over TCP connections on 'addr' and serves links on them in background until listener is closed.
+func HdlcListen(addr string, handler HdlcLinkHandler) (hl *HdlcListener, ln net.Listener, err error) {
+	hl = NewHdlcListener(handler)
+	ln, err = hl.Listen(addr)
+	if nil != err {
+		return nil, nil, err
+	}
+	return hl, ln, nil
+}
+
+// Serves links on single line 'rwc'. Returns after line was closed, all links are closed then.
+func HdlcServe(rwc io.ReadWriteCloser, handler HdlcLinkHandler) (err error) {
+	return NewHdlcListener(handler).Serve(rwc)
+}
+
+// Registers 'c' to be closed by Close(), returns false if listener is already closed.
+func (hl *HdlcListener) track(c io.Closer) bool {
+	hl.mtx.Lock()
+	defer hl.mtx.Unlock()
+	if hl.closed {
+		c.Close()
+		return false
+	}
+	hl.closers[c] = true
+	return true
+}
+
+func (hl *HdlcListener) untrack(c io.Closer) {
+	hl.mtx.Lock()
+	defer hl.mtx.Unlock()
+	delete(hl.closers, c)
+}
+
+func (hl *HdlcListener) isClosed() bool {
+	hl.mtx.Lock()
+	defer hl.mtx.Unlock()
+	return hl.closed
+}
+
+// Accepts HDLC over TCP connections on 'addr' and serves them in background until listener is closed.
+func (hl *HdlcListener) Listen(addr string) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		errorLog("net.Listen() failed: %v", err)
+		return nil, err
+	}
+	if !hl.track(ln) {
+		return nil, ErrServerClosed
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				if !hl.isClosed() {
+					errorLog("ln.Accept() failed: %v", err)
+				}
+				return
+			}
+			go hl.Serve(conn)
+		}
+	}()
+	return ln, nil
+}
+
+// Serves links on single line 'rwc'. Returns after peer closed the line or listener was closed.
+func (hl *HdlcListener) Serve(rwc io.ReadWriteCloser) (err error) {
+	if !hl.track(rwc) {
+		return ErrServerClosed
+	}
+	defer hl.untrack(rwc)
+	defer rwc.Close()
+
+	line := new(tHdlcLine)
+	line.rwc = rwc
+	line.handler = hl.handler
+	line.links = make(map[string]*HdlcLink)
+
+	err = line.serve()
+	if (io.EOF == err) || hl.isClosed() {
+		return nil
+	}
+	return err
+}
+
+// Closes all listeners, lines and links.
+func (hl *HdlcListener) Close() (err error) {
+	hl.mtx.Lock()
+	defer hl.mtx.Unlock()
+	hl.closed = true
+	for c := range hl.closers {
+		c.Close()
+	}
+	hl.closers = make(map[io.Closer]bool)
+	return nil
+}
+
+func (line *tHdlcLine) Read(p []byte) (n int, err error) {
+	return line.rwc.Read(p)
+}
+
+func (line *tHdlcLine) Write(p []byte) (n int, err error) {
+	line.wmtx.Lock()
+	defer line.wmtx.Unlock()
+	return line.rwc.Write(p)
+}
+
+func (line *tHdlcLine) serve() (err error) {
+	defer line.close()
+
+	for {
+		err, p := readHdlcRawFrame(line)
+		if nil != err {
+			return err
+		}
+		err, dst, src, control := decodeHdlcRawFrameHeader(p)
+		if nil != err {
+			warnLog("dropping frame: %v", err)
+			continue
+		}
+		key := string(dst) + string(src)
+
+		line.mtx.Lock()
+		link := line.links[key]
+		if (nil == link) && (0x83 == control&0xEF) { // SNRM
+			link = line.newLink(key, dst, src)
+		}
+		line.mtx.Unlock()
+
+		if nil != link {
+			link.conn.deliver(p)
+		} else if isHdlcBroadcastAddress(dst) {
+			line.mtx.Lock()
+			for _, link := range line.links {
+				link.conn.deliver(p)
+			}
+			line.mtx.Unlock()
+		} else if (control&0x10 > 0) && (0x03 != control&0xEF) {
+			// polled station not having link answers in disconnected mode
+			line.respondDM(dst, src)
+		}
+	}
+}
+
+// Creates link for SNRM received on unknown address pair. Returns nil if addresses are not valid for link. Caller holds line.mtx.
+func (line *tHdlcLine) newLink(key string, dst []byte, src []byte) (link *HdlcLink) {
+	if (1 != len(src)) || isHdlcBroadcastAddress(dst) {
+		return nil
+	}
+
+	link = new(HdlcLink)
+	link.line = line
+	link.key = key
+	link.LogicalDevice, link.PhysicalDevice, link.AddressLength = decodeHdlcRawServerAddress(dst)
+	link.ClientId = src[0]
+
+	link.conn = newHdlcLinkConn(line)
+
+	link.htran = newHdlcTransport(link.conn, 0, false, link.ClientId, link.LogicalDevice, link.PhysicalDevice, &link.AddressLength)
+	link.htran.linkConnected = func() {
+		// retransmitted SNRM must not announce link again
+		if !link.announced {
+			link.announced = true
+			go func() {
+				line.handler.HandleLink(link)
+				link.Close()
+			}()
+		}
+	}
+	link.htran.linkDisconnected = func() {
+		line.remove(link)
+		select {
+		case link.htran.writeAck <- map[string]interface{}{"err": io.EOF}:
+		default:
+		}
+	}
+	go link.htran.handleHdlc()
+
+	line.links[key] = link
+	return link
+}
+
+func (line *tHdlcLine) remove(link *HdlcLink) {
+	line.mtx.Lock()
+	defer line.mtx.Unlock()
+	if link == line.links[link.key] {
+		delete(line.links, link.key)
+	}
+}
+
+func (line *tHdlcLine) respondDM(dst []byte, src []byte) {
+	if 1 != len(src) {
+		return
+	}
+	logicalDevice, physicalDevice, addressLength := decodeHdlcRawServerAddress(dst)
+	htran := newHdlcFramer(line, false, src[0], logicalDevice, physicalDevice, addressLength)
+	frame := new(HdlcFrame)
+	frame.direction = HDLC_FRAME_DIRECTION_SERVER_OUTBOUND
+	frame.poll = true
+	frame.control = HDLC_CONTROL_DM
+	err := htran.writeFrame(frame)
+	if nil != err {
+		errorLog("cannot send DM: %v", err)
+	}
+}
+
+func (line *tHdlcLine) close() {
+	line.mtx.Lock()
+	links := make([]*HdlcLink, 0, len(line.links))
+	for _, link := range line.links {
+		links = append(links, link)
+	}
+	line.links = make(map[string]*HdlcLink)
+	line.mtx.Unlock()
+
+	for _, link := range links {
+		link.Close()
+	}
+}
+
+func (link *HdlcLink) Read(p []byte) (n int, err error) {
+	n, err = link.htran.Read(p)
+	if HdlcErrorTransportClosed == err {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (link *HdlcLink) Write(p []byte) (n int, err error) {
+	return link.htran.Write(p)
+}
+
+// Closes link, peer is not notified and receives DM if it keeps on using the link.
+func (link *HdlcLink) Close() (err error) {
+	link.closeOnce.Do(func() {
+		link.line.remove(link)
+		link.conn.Close()
+		err = link.htran.Close()
+	})
+	return err
+}
+
+func newHdlcLinkConn(line io.Writer) *tHdlcLinkConn {
+	conn := new(tHdlcLinkConn)
+	conn.line = line
+	conn.err = io.EOF
+	conn.ready = make(chan bool, 1)
+	return conn
+}
+
+func (conn *tHdlcLinkConn) notify() {
+	select {
+	case conn.ready <- true:
+	default:
+	}
+}
+
+func (conn *tHdlcLinkConn) deliver(p []byte) {
+	conn.mtx.Lock()
+	if !conn.closed {
+		conn.buf.Write(p)
+	}
+	conn.mtx.Unlock()
+	conn.notify()
+}
+
+func (conn *tHdlcLinkConn) Read(p []byte) (n int, err error) {
+	for {
+		conn.mtx.Lock()
+		if conn.buf.Len() > 0 {
+			n, err = conn.buf.Read(p)
+			conn.mtx.Unlock()
+			return n, err
+		}
+		if conn.closed {
+			conn.mtx.Unlock()
+			return 0, conn.err
+		}
+		deadline := conn.deadline
+		conn.mtx.Unlock()
+
+		if deadline.IsZero() {
+			<-conn.ready
+			continue
+		}
+		d := deadline.Sub(time.Now())
+		if d <= 0 {
+			return 0, tHdlcTimeoutError{}
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-conn.ready:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Needed by client HdlcTransport, zero 't' means no deadline.
+func (conn *tHdlcLinkConn) SetReadDeadline(t time.Time) error {
+	conn.mtx.Lock()
+	conn.deadline = t
+	conn.mtx.Unlock()
+	conn.notify()
+	return nil
+}
+
+func (conn *tHdlcLinkConn) Write(p []byte) (n int, err error) {
+	return conn.line.Write(p)
+}
+
+func (conn *tHdlcLinkConn) Close() (err error) {
+	return conn.closeWithError(io.EOF)
+}
+
+// Closes conn, pending and subsequent reads return 'err' once received frames are consumed.
+func (conn *tHdlcLinkConn) closeWithError(err error) error {
+	conn.mtx.Lock()
+	if !conn.closed {
+		conn.closed = true
+		conn.err = err
+	}
+	conn.mtx.Unlock()
+	conn.notify()
+	return nil
+}
+
+// Reads whole frame including opening and closing flag, bytes preceding opening flag and frames with bad FCS are skipped.
+func readHdlcRawFrame(r io.Reader) (err error, p []byte) {
+	b := make([]byte, 1)
+	flag := false
+	for {
+		_, err = io.ReadFull(r, b)
+		if nil != err {
+			return err, nil
+		}
+		if 0x7E == b[0] {
+			flag = true
+			continue
+		}
+		if !flag || (0xA0 != b[0]&0xF0) {
+			flag = false
+			continue
+		}
+		flag = false
+
+		format := b[0]
+		_, err = io.ReadFull(r, b)
+		if nil != err {
+			return err, nil
+		}
+		length := int(uint16(format&0x07)<<8 + uint16(b[0]))
+		if length < 2 {
+			continue
+		}
+
+		p = make([]byte, 3+length-2+1) // flag, format field, rest of frame and closing flag
+		p[0] = 0x7E
+		p[1] = format
+		p[2] = b[0]
+		_, err = io.ReadFull(r, p[3:])
+		if nil != err {
+			return err, nil
+		}
+		if PPPGOODFCS16 != pppfcs16(PPPINITFCS16, p[1:len(p)-1]) {
+			warnLog("dropping frame: wrong FCS")
+			continue
+		}
+		return nil, p
+	}
+}
+
+// Returns destination and source address bytes and control field of raw frame.
+func decodeHdlcRawFrameHeader(p []byte) (err error, dst []byte, src []byte, control byte) {
+	i := 3
+	address := func(maxLength int) []byte {
+		for j := i; (j < len(p)) && (j-i < maxLength); j++ {
+			if p[j]&0x01 > 0 {
+				a := p[i : j+1]
+				i = j + 1
+				return a
+			}
+		}
+		return nil
+	}
+	dst = address(HDLC_ADDRESS_LENGTH_4)
+	if (nil == dst) || (3 == len(dst)) {
+		return fmt.Errorf("malformed destination address"), nil, nil, 0
+	}
+	src = address(HDLC_ADDRESS_LENGTH_4)
+	if (nil == src) || (3 == len(src)) {
+		return fmt.Errorf("malformed source address"), nil, nil, 0
+	}
+	if i >= len(p)-1 {
+		return fmt.Errorf("missing control field"), nil, nil, 0
+	}
+	return nil, dst, src, p[i]
+}
+
+// Decodes server address bytes as received on the line.
+func decodeHdlcRawServerAddress(a []byte) (logicalDevice uint16, physicalDevice *uint16, addressLength int) {
+	switch len(a) {
+	case HDLC_ADDRESS_LENGTH_1:
+		return uint16(a[0] >> 1), nil, HDLC_ADDRESS_LENGTH_1
+	case HDLC_ADDRESS_LENGTH_2:
+		physicalDevice = new(uint16)
+		*physicalDevice = uint16(a[1] >> 1)
+		return uint16(a[0] >> 1), physicalDevice, HDLC_ADDRESS_LENGTH_2
+	default:
+		physicalDevice = new(uint16)
+		*physicalDevice = uint16(a[2]>>1)<<7 | uint16(a[3]>>1)
+		return uint16(a[0]>>1)<<7 | uint16(a[1]>>1), physicalDevice, HDLC_ADDRESS_LENGTH_4
+	}
+}
+
+// Returns true if server address bytes are all station address.
+func isHdlcBroadcastAddress(a []byte) bool {
+	logicalDevice, physicalDevice, _ := decodeHdlcRawServerAddress(a)
+	switch len(a) {
+	case HDLC_ADDRESS_LENGTH_1:
+		return 0x7F == logicalDevice
+	case HDLC_ADDRESS_LENGTH_2:
+		return 0x7F == *physicalDevice
+	default:
+		return 0x3FFF == *physicalDevice
+	}
+}
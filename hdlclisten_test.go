@@ -0,0 +1,138 @@
+package gocosem
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func receiveLink(t *testing.T, ch chan *HdlcLink) *HdlcLink {
+	select {
+	case link := <-ch:
+		return link
+	case <-time.After(5 * time.Second):
+		t.Fatalf("link not accepted")
+	}
+	return nil
+}
+
+func TestHdlcListen_cosemServer(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	ch := make(chan *HdlcLink, 10)
+	hl, ln, err := HdlcListen("localhost:0", HdlcLinkHandlerFunc(func(link *HdlcLink) {
+		ch <- link
+		srv.ServeHdlcLink(link)
+	}))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer hl.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	for logicalDevice := uint16(1); logicalDevice <= 2; logicalDevice++ {
+		dconn, err := HdlcConnect("localhost", port, 1, logicalDevice, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		link := receiveLink(t, ch)
+		if (logicalDevice != link.LogicalDevice) || (nil != link.PhysicalDevice) || (1 != link.ClientId) {
+			t.Fatalf("unexpected link addresses: %d, %v, %d", link.LogicalDevice, link.PhysicalDevice, link.ClientId)
+		}
+
+		aconn, err := dconn.AppConnectWithPassword(01, logicalDevice, 0, "")
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		vals := []*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+		}
+		rep, err := aconn.SendRequest(vals)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+			t.Fatalf("value differs")
+		}
+		dconn.Close()
+	}
+}
+
+func TestHdlcListen_demultiplex(t *testing.T) {
+	ch := make(chan *HdlcLink, 10)
+	eofs := make(chan uint16, 10)
+
+	client, line := net.Pipe()
+	defer client.Close()
+	go HdlcServe(line, HdlcLinkHandlerFunc(func(link *HdlcLink) {
+		ch <- link
+		_, err := link.Read(make([]byte, 10))
+		if io.EOF == err {
+			eofs <- link.LogicalDevice
+		}
+	}))
+
+	framers := make(map[uint16]*HdlcTransport)
+	for _, logicalDevice := range []uint16{1, 2, 3} {
+		framers[logicalDevice] = newHdlcFramer(client, true, 0x21, logicalDevice, nil, HDLC_ADDRESS_LENGTH_1)
+	}
+	exchange := func(logicalDevice uint16, control int) int {
+		client.SetDeadline(time.Now().Add(5 * time.Second))
+		htran := framers[logicalDevice]
+		err := htran.writeFrame(&HdlcFrame{direction: HDLC_FRAME_DIRECTION_CLIENT_OUTBOUND, control: control, poll: true})
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		err, frame := htran.readFrame(HDLC_FRAME_DIRECTION_CLIENT_INBOUND)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if (logicalDevice != frame.logicalDeviceId) || (0x21 != frame.clientId) {
+			t.Fatalf("unexpected response addresses: %d, %d", frame.logicalDeviceId, frame.clientId)
+		}
+		return frame.control
+	}
+
+	for _, logicalDevice := range []uint16{1, 2} {
+		if HDLC_CONTROL_UA != exchange(logicalDevice, HDLC_CONTROL_SNRM) {
+			t.Fatalf("SNRM not acknowledged")
+		}
+		link := receiveLink(t, ch)
+		if (logicalDevice != link.LogicalDevice) || (0x21 != link.ClientId) {
+			t.Fatalf("unexpected link addresses: %d, %d", link.LogicalDevice, link.ClientId)
+		}
+	}
+
+	// no link to logical device 3
+	if HDLC_CONTROL_DM != exchange(3, HDLC_CONTROL_RR) {
+		t.Fatalf("DM expected")
+	}
+
+	if HDLC_CONTROL_UA != exchange(1, HDLC_CONTROL_DISC) {
+		t.Fatalf("DISC not acknowledged")
+	}
+	select {
+	case logicalDevice := <-eofs:
+		if 1 != logicalDevice {
+			t.Fatalf("wrong link disconnected: %d", logicalDevice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("link not disconnected")
+	}
+
+	// link to logical device 2 is still up
+	if HDLC_CONTROL_RR != exchange(2, HDLC_CONTROL_RR) {
+		t.Fatalf("RR expected")
+	}
+	if HDLC_CONTROL_DM != exchange(1, HDLC_CONTROL_RR) {
+		t.Fatalf("DM expected")
+	}
+}
@@ -0,0 +1,249 @@
+package gocosem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var HdlcErrorAddressInUse = errors.New("hdlc address already in use on the line")
+
+/*
+Shares one line (RS-485 bus, HDLC over TCP gateway) among independent HDLC
+connections to several servers. Each connection is identified by client
+address, logical device and physical device. Line is arbitrated so that only
+one connection polls at a time: connection gets the line with first frame
+it transmits and releases it when response with final bit arrives, when
+response times out or when connection is closed. Received frames are routed
+to connections by address.
+*/
+type HdlcMux struct {
+	rwc  io.ReadWriteCloser
+	wmtx sync.Mutex
+
+	token chan bool // held by connection using the line
+
+	mtx    sync.Mutex
+	conns  map[tHdlcMuxKey]*tHdlcMuxConn
+	holder *tHdlcMuxConn
+	closed bool
+	err    error // line failure
+	done   chan bool
+}
+
+// Address triple identifying connection on the line.
+type tHdlcMuxKey struct {
+	clientId       uint8
+	logicalDevice  uint16
+	physicalDevice int // -1 if not present
+}
+
+type tHdlcMuxConn struct {
+	*tHdlcLinkConn
+	mux       *HdlcMux
+	key       tHdlcMuxKey
+	done      chan bool
+	closeOnce sync.Once
+}
+
+// Creates multiplexer on line 'rwc'. Line is closed when multiplexer is closed.
+func NewHdlcMux(rwc io.ReadWriteCloser) *HdlcMux {
+	mux := new(HdlcMux)
+	mux.rwc = rwc
+	mux.token = make(chan bool, 1)
+	mux.conns = make(map[tHdlcMuxKey]*tHdlcMuxConn)
+	mux.done = make(chan bool)
+	go mux.receive()
+	return mux
+}
+
+func newHdlcMuxKey(clientId uint8, logicalDevice uint16, physicalDevice *uint16) tHdlcMuxKey {
+	key := tHdlcMuxKey{clientId: clientId, logicalDevice: logicalDevice, physicalDevice: -1}
+	if nil != physicalDevice {
+		key.physicalDevice = int(*physicalDevice)
+	}
+	return key
+}
+
+/*
+Connects HDLC transport to server addressed by 'logicalDevice' and
+'physicalDevice' over shared line. For meaning of parameters see
+HdlcConnect(). Closing returned connection does not close the line.
+*/
+func (mux *HdlcMux) Connect(applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	return mux.ConnectContext(context.Background(), applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
+
+// Same as Connect() but connecting is aborted when 'ctx' is done.
+func (mux *HdlcMux) ConnectContext(ctx context.Context, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	err, conn := mux.open(newHdlcMuxKey(uint8(applicationClient), logicalDevice, physicalDevice))
+	if nil != err {
+		return nil, err
+	}
+	return HdlcConnectRWContext(ctx, conn, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
+
+func (mux *HdlcMux) open(key tHdlcMuxKey) (err error, conn *tHdlcMuxConn) {
+	mux.mtx.Lock()
+	defer mux.mtx.Unlock()
+	if mux.closed {
+		if nil != mux.err {
+			return mux.err, nil
+		}
+		return HdlcErrorTransportClosed, nil
+	}
+	if nil != mux.conns[key] {
+		errorLog("%s: client %d, logical device %d, physical device %d", HdlcErrorAddressInUse, key.clientId, key.logicalDevice, key.physicalDevice)
+		return HdlcErrorAddressInUse, nil
+	}
+	conn = new(tHdlcMuxConn)
+	conn.tHdlcLinkConn = newHdlcLinkConn(mux.rwc)
+	conn.mux = mux
+	conn.key = key
+	conn.done = make(chan bool)
+	mux.conns[key] = conn
+	return nil, conn
+}
+
+// Closes the line and all connections using it.
+func (mux *HdlcMux) Close() (err error) {
+	mux.mtx.Lock()
+	if mux.closed {
+		mux.mtx.Unlock()
+		return nil
+	}
+	mux.closed = true
+	close(mux.done)
+	mux.mtx.Unlock()
+	return mux.rwc.Close()
+}
+
+func (mux *HdlcMux) receive() {
+	var err error
+	for {
+		var p []byte
+		err, p = readHdlcRawFrame(mux.rwc)
+		if nil != err {
+			break
+		}
+		e, dst, src, control := decodeHdlcRawFrameHeader(p)
+		if nil != e {
+			warnLog("dropping frame: %v", e)
+			continue
+		}
+		if 1 != len(dst) {
+			debugLog("dropping frame not addressed to client")
+			continue
+		}
+		logicalDevice, physicalDevice, _ := decodeHdlcRawServerAddress(src)
+
+		mux.mtx.Lock()
+		conn := mux.conns[newHdlcMuxKey(dst[0], logicalDevice, physicalDevice)]
+		mux.mtx.Unlock()
+		if nil == conn {
+			debugLog("dropping frame from unknown address")
+			continue
+		}
+		conn.deliver(p)
+		if control&0x10 > 0 {
+			// final bit, server gives the line back
+			mux.release(conn)
+		}
+	}
+
+	mux.mtx.Lock()
+	if !mux.closed {
+		errorLog("line failed: %v", err)
+		mux.closed = true
+		mux.err = err
+		close(mux.done)
+	} else {
+		err = HdlcErrorTransportClosed
+	}
+	conns := mux.conns
+	mux.conns = make(map[tHdlcMuxKey]*tHdlcMuxConn)
+	mux.mtx.Unlock()
+
+	for _, conn := range conns {
+		conn.closeWithError(err)
+	}
+}
+
+// Waits until line is free unless 'conn' already has it.
+func (mux *HdlcMux) acquire(conn *tHdlcMuxConn) (err error) {
+	mux.mtx.Lock()
+	if conn == mux.holder {
+		mux.mtx.Unlock()
+		return nil
+	}
+	mux.mtx.Unlock()
+
+	select {
+	case mux.token <- true:
+	case <-conn.done:
+		return HdlcErrorTransportClosed
+	case <-mux.done:
+		return HdlcErrorTransportClosed
+	}
+
+	mux.mtx.Lock()
+	mux.holder = conn
+	mux.mtx.Unlock()
+	return nil
+}
+
+func (mux *HdlcMux) release(conn *tHdlcMuxConn) {
+	mux.mtx.Lock()
+	defer mux.mtx.Unlock()
+	if conn == mux.holder {
+		mux.holder = nil
+		<-mux.token
+	}
+}
+
+func (conn *tHdlcMuxConn) Read(p []byte) (n int, err error) {
+	n, err = conn.tHdlcLinkConn.Read(p)
+	if isTimeOutErr(err) {
+		// no response, let other connections use the line
+		conn.mux.release(conn)
+	}
+	return n, err
+}
+
+func (conn *tHdlcMuxConn) Write(p []byte) (n int, err error) {
+	err = conn.mux.acquire(conn)
+	if nil != err {
+		return 0, err
+	}
+	conn.mux.wmtx.Lock()
+	n, err = conn.mux.rwc.Write(p)
+	conn.mux.wmtx.Unlock()
+	if nil != err {
+		conn.mux.release(conn)
+		return n, err
+	}
+
+	e, dst, _, control := decodeHdlcRawFrameHeader(p)
+	if (nil == e) && isHdlcBroadcastAddress(dst) && (0 == control&0x10) {
+		// nobody answers to broadcast
+		conn.mux.release(conn)
+	}
+	return n, nil
+}
+
+// Closes connection leaving the line open.
+func (conn *tHdlcMuxConn) Close() (err error) {
+	conn.closeOnce.Do(func() {
+		close(conn.done)
+		conn.tHdlcLinkConn.Close()
+		conn.mux.mtx.Lock()
+		if conn == conn.mux.conns[conn.key] {
+			delete(conn.mux.conns, conn.key)
+		}
+		conn.mux.mtx.Unlock()
+		conn.mux.release(conn)
+	})
+	return nil
+}
@@ -0,0 +1,85 @@
+package gocosem
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestHdlcMux_Connect(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	// meters on the bus
+	ch := make(chan *HdlcLink, 10)
+	bus, line := net.Pipe()
+	go HdlcServe(bus, HdlcLinkHandlerFunc(func(link *HdlcLink) {
+		ch <- link
+		srv.ServeHdlcLink(link)
+	}))
+
+	mux := NewHdlcMux(line)
+	defer mux.Close()
+
+	physicalDevices := []uint16{17, 18}
+	dconns := make([]*DlmsConn, len(physicalDevices))
+	for i := range physicalDevices {
+		dconn, err := mux.Connect(1, 1, &physicalDevices[i], nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		defer dconn.Close()
+		dconns[i] = dconn
+
+		link := receiveLink(t, ch)
+		if (1 != link.LogicalDevice) || (nil == link.PhysicalDevice) || (physicalDevices[i] != *link.PhysicalDevice) || (HDLC_ADDRESS_LENGTH_2 != link.AddressLength) {
+			t.Fatalf("unexpected link addresses: %d, %v, %d", link.LogicalDevice, link.PhysicalDevice, link.AddressLength)
+		}
+	}
+
+	_, err := mux.Connect(1, 1, &physicalDevices[0], nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if HdlcErrorAddressInUse != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// both meters are read concurrently
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for _, dconn := range dconns {
+		wg.Add(1)
+		go func(dconn *DlmsConn) {
+			defer wg.Done()
+			aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+			if nil != err {
+				errs <- err
+				return
+			}
+			vals := []*DlmsRequest{
+				&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+			}
+			for i := 0; i < 5; i++ {
+				rep, err := aconn.SendRequest(vals)
+				if nil != err {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+					errs <- errors.New("value differs")
+					return
+				}
+			}
+		}(dconn)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("%s\n", err)
+	}
+}
@@ -0,0 +1,558 @@
+package gocosem
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	CLASS_ID_DATA               DlmsClassId = 1
+	CLASS_ID_REGISTER           DlmsClassId = 3
+	CLASS_ID_EXTENDED_REGISTER  DlmsClassId = 4
+	CLASS_ID_PROFILE_GENERIC    DlmsClassId = 7
+	CLASS_ID_CLOCK              DlmsClassId = 8
+	CLASS_ID_ASSOCIATION_LN     DlmsClassId = 15
+	CLASS_ID_IMAGE_TRANSFER     DlmsClassId = 18
+	CLASS_ID_DISCONNECT_CONTROL DlmsClassId = 70
+)
+
+const (
+	DISCONNECT_CONTROL_STATE_DISCONNECTED        uint8 = 0
+	DISCONNECT_CONTROL_STATE_CONNECTED           uint8 = 1
+	DISCONNECT_CONTROL_STATE_READY_FOR_RECONNECT uint8 = 2
+)
+
+// Default clock object used as restricting object when reading profile by range.
+var ClockInstanceId = DlmsOid{0x00, 0x00, 0x01, 0x00, 0x00, 0xFF}
+
+type DataAccessError struct {
+	ClassId          DlmsClassId
+	InstanceId       DlmsOid
+	AttributeId      DlmsAttributeId
+	DataAccessResult DlmsDataAccessResult
+}
+
+func (e *DataAccessError) Error() string {
+	return fmt.Sprintf("class %d, instance %s, attribute %d: data access result: %d", e.ClassId, e.InstanceId, e.AttributeId, e.DataAccessResult)
+}
+
+type ActionError struct {
+	ClassId      DlmsClassId
+	InstanceId   DlmsOid
+	MethodId     DlmsMethodId
+	ActionResult DlmsActionResult
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("class %d, instance %s, method %d: action result: %d", e.ClassId, e.InstanceId, e.MethodId, e.ActionResult)
+}
+
+// Base of all typed interface class wrappers.
+type IcObject struct {
+	aconn      *AppConn
+	ClassId    DlmsClassId
+	InstanceId DlmsOid
+}
+
+func NewIcObject(aconn *AppConn, classId DlmsClassId, instanceId *DlmsOid) *IcObject {
+	obj := new(IcObject)
+	obj.aconn = aconn
+	obj.ClassId = classId
+	obj.InstanceId = *instanceId
+	return obj
+}
+
+func (obj *IcObject) GetAttributeSelective(attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameter *DlmsData) (data *DlmsData, err error) {
+	instanceId := obj.InstanceId
+	rep, err := obj.aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: obj.ClassId, InstanceId: &instanceId, AttributeId: attributeId, AccessSelector: accessSelector, AccessParameter: accessParameter},
+	})
+	if nil != err {
+		return nil, err
+	}
+	if dataAccessResult_success != rep.DataAccessResultAt(0) {
+		return nil, &DataAccessError{obj.ClassId, obj.InstanceId, attributeId, rep.DataAccessResultAt(0)}
+	}
+	data = rep.DataAt(0)
+	if nil == data {
+		return nil, fmt.Errorf("class %d, instance %s, attribute %d: no data", obj.ClassId, obj.InstanceId, attributeId)
+	}
+	return data, nil
+}
+
+func (obj *IcObject) GetAttribute(attributeId DlmsAttributeId) (data *DlmsData, err error) {
+	return obj.GetAttributeSelective(attributeId, 0, nil)
+}
+
+func (obj *IcObject) SetAttribute(attributeId DlmsAttributeId, data *DlmsData) (err error) {
+	instanceId := obj.InstanceId
+	rep, err := obj.aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: obj.ClassId, InstanceId: &instanceId, AttributeId: attributeId, Data: data},
+	})
+	if nil != err {
+		return err
+	}
+	if dataAccessResult_success != rep.DataAccessResultAt(0) {
+		return &DataAccessError{obj.ClassId, obj.InstanceId, attributeId, rep.DataAccessResultAt(0)}
+	}
+	return nil
+}
+
+func (obj *IcObject) Invoke(methodId DlmsMethodId, methodParameters *DlmsData) (data *DlmsData, err error) {
+	instanceId := obj.InstanceId
+	rep, err := obj.aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: obj.ClassId, InstanceId: &instanceId, MethodId: methodId, MethodParameters: methodParameters},
+	})
+	if nil != err {
+		return nil, err
+	}
+	if actionResult_success != rep.ActionResultAt(0) {
+		return nil, &ActionError{obj.ClassId, obj.InstanceId, methodId, rep.ActionResultAt(0)}
+	}
+	return rep.DataAt(0), nil
+}
+
+func (obj *IcObject) LogicalName() (instanceId *DlmsOid, err error) {
+	data, err := obj.GetAttribute(1)
+	if nil != err {
+		return nil, err
+	}
+	return oidFromData(data)
+}
+
+func (obj *IcObject) getTyped(attributeId DlmsAttributeId, typ uint8) (data *DlmsData, err error) {
+	data, err = obj.GetAttribute(attributeId)
+	if nil != err {
+		return nil, err
+	}
+	if typ != data.GetType() {
+		err = fmt.Errorf("class %d, instance %s, attribute %d: unexpected data type: %d", obj.ClassId, obj.InstanceId, attributeId, data.GetType())
+		errorLog("%s", err)
+		return nil, err
+	}
+	return data, nil
+}
+
+func (obj *IcObject) getDateTime(attributeId DlmsAttributeId) (dateTime *DlmsDateTime, err error) {
+	data, err := obj.GetAttribute(attributeId)
+	if nil != err {
+		return nil, err
+	}
+	return dateTimeFromData(data)
+}
+
+func (obj *IcObject) invokeWithInteger(methodId DlmsMethodId) (err error) {
+	methodParameters := new(DlmsData)
+	methodParameters.SetInteger(0)
+	_, err = obj.Invoke(methodId, methodParameters)
+	return err
+}
+
+func oidFromData(data *DlmsData) (oid *DlmsOid, err error) {
+	if (DATA_TYPE_OCTET_STRING != data.GetType()) || (6 != len(data.GetOctetString())) {
+		err = fmt.Errorf("not a logical name")
+		errorLog("%s", err)
+		return nil, err
+	}
+	oid = new(DlmsOid)
+	copy(oid[:], data.GetOctetString())
+	return oid, nil
+}
+
+func dateTimeFromData(data *DlmsData) (dateTime *DlmsDateTime, err error) {
+	var b []byte
+	switch data.GetType() {
+	case DATA_TYPE_OCTET_STRING:
+		b = data.GetOctetString()
+	case DATA_TYPE_DATETIME:
+		b = data.GetDateTime()
+	default:
+		err = fmt.Errorf("not a date-time, data type: %d", data.GetType())
+		errorLog("%s", err)
+		return nil, err
+	}
+	if 12 != len(b) {
+		err = fmt.Errorf("not a date-time, length: %d", len(b))
+		errorLog("%s", err)
+		return nil, err
+	}
+	return DlmsDateTimeFromBytes(b), nil
+}
+
+// Converts any numeric data to float64.
+func DlmsDataToFloat64(data *DlmsData) (f float64, err error) {
+	switch data.GetType() {
+	case DATA_TYPE_DOUBLE_LONG:
+		return float64(data.GetDoubleLong()), nil
+	case DATA_TYPE_DOUBLE_LONG_UNSIGNED:
+		return float64(data.GetDoubleLongUnsigned()), nil
+	case DATA_TYPE_FLOATING_POINT:
+		return float64(data.GetFloatingPoint()), nil
+	case DATA_TYPE_INTEGER:
+		return float64(data.GetInteger()), nil
+	case DATA_TYPE_LONG:
+		return float64(data.GetLong()), nil
+	case DATA_TYPE_UNSIGNED:
+		return float64(data.GetUnsigned()), nil
+	case DATA_TYPE_LONG_UNSIGNED:
+		return float64(data.GetLongUnsigned()), nil
+	case DATA_TYPE_LONG64:
+		return float64(data.GetLong64()), nil
+	case DATA_TYPE_UNSIGNED_LONG64:
+		return float64(data.GetUnsignedLong64()), nil
+	case DATA_TYPE_ENUM:
+		return float64(data.GetEnum()), nil
+	case DATA_TYPE_REAL32:
+		return float64(data.GetReal32()), nil
+	case DATA_TYPE_REAL64:
+		return data.GetReal64(), nil
+	default:
+		err = fmt.Errorf("not a numeric value, data type: %d", data.GetType())
+		errorLog("%s", err)
+		return 0, err
+	}
+}
+
+// Data (class_id 1)
+
+type DataObject struct {
+	IcObject
+}
+
+func NewDataObject(aconn *AppConn, instanceId *DlmsOid) *DataObject {
+	return &DataObject{*NewIcObject(aconn, CLASS_ID_DATA, instanceId)}
+}
+
+func (obj *DataObject) GetValue() (data *DlmsData, err error) {
+	return obj.GetAttribute(2)
+}
+
+func (obj *DataObject) SetValue(data *DlmsData) (err error) {
+	return obj.SetAttribute(2, data)
+}
+
+// Register (class_id 3)
+
+type ScalerUnit struct {
+	Scaler int8
+	Unit   uint8
+}
+
+type Register struct {
+	IcObject
+}
+
+func NewRegister(aconn *AppConn, instanceId *DlmsOid) *Register {
+	return &Register{*NewIcObject(aconn, CLASS_ID_REGISTER, instanceId)}
+}
+
+func (obj *Register) GetValue() (data *DlmsData, err error) {
+	return obj.GetAttribute(2)
+}
+
+func (obj *Register) GetScalerUnit() (scalerUnit *ScalerUnit, err error) {
+	data, err := obj.getTyped(3, DATA_TYPE_STRUCTURE)
+	if nil != err {
+		return nil, err
+	}
+	if (2 != len(data.Arr)) || (DATA_TYPE_INTEGER != data.Arr[0].GetType()) || (DATA_TYPE_ENUM != data.Arr[1].GetType()) {
+		err = fmt.Errorf("malformed scaler_unit")
+		errorLog("%s", err)
+		return nil, err
+	}
+	return &ScalerUnit{Scaler: data.Arr[0].GetInteger(), Unit: data.Arr[1].GetEnum()}, nil
+}
+
+// Returns value multiplied by 10^scaler and the unit.
+func (obj *Register) GetValueScaled() (value float64, unit uint8, err error) {
+	scalerUnit, err := obj.GetScalerUnit()
+	if nil != err {
+		return 0, 0, err
+	}
+	data, err := obj.GetValue()
+	if nil != err {
+		return 0, 0, err
+	}
+	value, err = DlmsDataToFloat64(data)
+	if nil != err {
+		return 0, 0, err
+	}
+	return value * math.Pow10(int(scalerUnit.Scaler)), scalerUnit.Unit, nil
+}
+
+func (obj *Register) Reset() (err error) {
+	return obj.invokeWithInteger(1)
+}
+
+// Extended Register (class_id 4)
+
+type ExtendedRegister struct {
+	Register
+}
+
+func NewExtendedRegister(aconn *AppConn, instanceId *DlmsOid) *ExtendedRegister {
+	return &ExtendedRegister{Register{*NewIcObject(aconn, CLASS_ID_EXTENDED_REGISTER, instanceId)}}
+}
+
+func (obj *ExtendedRegister) GetStatus() (data *DlmsData, err error) {
+	return obj.GetAttribute(4)
+}
+
+func (obj *ExtendedRegister) GetCaptureTime() (dateTime *DlmsDateTime, err error) {
+	return obj.getDateTime(5)
+}
+
+// Clock (class_id 8)
+
+type Clock struct {
+	IcObject
+}
+
+func NewClock(aconn *AppConn, instanceId *DlmsOid) *Clock {
+	return &Clock{*NewIcObject(aconn, CLASS_ID_CLOCK, instanceId)}
+}
+
+func (obj *Clock) GetTime() (dateTime *DlmsDateTime, err error) {
+	return obj.getDateTime(2)
+}
+
+func (obj *Clock) SetTime(dateTime *DlmsDateTime) (err error) {
+	data := new(DlmsData)
+	data.SetOctetString(dateTime.ToBytes())
+	return obj.SetAttribute(2, data)
+}
+
+// Deviation of local time from UTC in minutes.
+func (obj *Clock) GetTimeZone() (timeZone int16, err error) {
+	data, err := obj.getTyped(3, DATA_TYPE_LONG)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetLong(), nil
+}
+
+func (obj *Clock) GetStatus() (status uint8, err error) {
+	data, err := obj.getTyped(4, DATA_TYPE_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetUnsigned(), nil
+}
+
+// Profile Generic (class_id 7)
+
+type CaptureObject struct {
+	ClassId     DlmsClassId
+	InstanceId  DlmsOid
+	AttributeId int8
+	DataIndex   uint16
+}
+
+type ProfileEntry []*DlmsData
+
+type ProfileGeneric struct {
+	IcObject
+}
+
+func NewProfileGeneric(aconn *AppConn, instanceId *DlmsOid) *ProfileGeneric {
+	return &ProfileGeneric{*NewIcObject(aconn, CLASS_ID_PROFILE_GENERIC, instanceId)}
+}
+
+func captureObjectFromData(data *DlmsData) (captureObject *CaptureObject, err error) {
+	if (DATA_TYPE_STRUCTURE != data.GetType()) || (4 != len(data.Arr)) || (DATA_TYPE_LONG_UNSIGNED != data.Arr[0].GetType()) || (DATA_TYPE_INTEGER != data.Arr[2].GetType()) || (DATA_TYPE_LONG_UNSIGNED != data.Arr[3].GetType()) {
+		err = fmt.Errorf("malformed capture object definition")
+		errorLog("%s", err)
+		return nil, err
+	}
+	instanceId, err := oidFromData(data.Arr[1])
+	if nil != err {
+		return nil, err
+	}
+	captureObject = new(CaptureObject)
+	captureObject.ClassId = DlmsClassId(data.Arr[0].GetLongUnsigned())
+	captureObject.InstanceId = *instanceId
+	captureObject.AttributeId = data.Arr[2].GetInteger()
+	captureObject.DataIndex = data.Arr[3].GetLongUnsigned()
+	return captureObject, nil
+}
+
+func (captureObject *CaptureObject) toData() *DlmsData {
+	data := new(DlmsData)
+	data.SetStructure(4)
+	data.Arr[0].SetLongUnsigned(uint16(captureObject.ClassId))
+	data.Arr[1].SetOctetString(captureObject.InstanceId[:])
+	data.Arr[2].SetInteger(captureObject.AttributeId)
+	data.Arr[3].SetLongUnsigned(captureObject.DataIndex)
+	return data
+}
+
+func entriesFromData(data *DlmsData) (entries []ProfileEntry, err error) {
+	if DATA_TYPE_ARRAY != data.GetType() {
+		err = fmt.Errorf("profile buffer is not an array, data type: %d", data.GetType())
+		errorLog("%s", err)
+		return nil, err
+	}
+	entries = make([]ProfileEntry, len(data.Arr))
+	for i, d := range data.Arr {
+		if DATA_TYPE_STRUCTURE != d.GetType() {
+			err = fmt.Errorf("profile entry is not a structure, data type: %d", d.GetType())
+			errorLog("%s", err)
+			return nil, err
+		}
+		entries[i] = ProfileEntry(d.Arr)
+	}
+	return entries, nil
+}
+
+func (obj *ProfileGeneric) GetCaptureObjects() (captureObjects []*CaptureObject, err error) {
+	data, err := obj.getTyped(3, DATA_TYPE_ARRAY)
+	if nil != err {
+		return nil, err
+	}
+	captureObjects = make([]*CaptureObject, len(data.Arr))
+	for i, d := range data.Arr {
+		captureObjects[i], err = captureObjectFromData(d)
+		if nil != err {
+			return nil, err
+		}
+	}
+	return captureObjects, nil
+}
+
+// Capture period in seconds.
+func (obj *ProfileGeneric) GetCapturePeriod() (capturePeriod uint32, err error) {
+	data, err := obj.getTyped(4, DATA_TYPE_DOUBLE_LONG_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetDoubleLongUnsigned(), nil
+}
+
+func (obj *ProfileGeneric) GetSortMethod() (sortMethod uint8, err error) {
+	data, err := obj.getTyped(5, DATA_TYPE_ENUM)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetEnum(), nil
+}
+
+func (obj *ProfileGeneric) GetSortObject() (sortObject *CaptureObject, err error) {
+	data, err := obj.GetAttribute(6)
+	if nil != err {
+		return nil, err
+	}
+	return captureObjectFromData(data)
+}
+
+func (obj *ProfileGeneric) GetEntriesInUse() (entriesInUse uint32, err error) {
+	data, err := obj.getTyped(7, DATA_TYPE_DOUBLE_LONG_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetDoubleLongUnsigned(), nil
+}
+
+func (obj *ProfileGeneric) GetProfileEntries() (profileEntries uint32, err error) {
+	data, err := obj.getTyped(8, DATA_TYPE_DOUBLE_LONG_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetDoubleLongUnsigned(), nil
+}
+
+// Reads whole buffer.
+func (obj *ProfileGeneric) ReadAll() (entries []ProfileEntry, err error) {
+	data, err := obj.GetAttribute(2)
+	if nil != err {
+		return nil, err
+	}
+	return entriesFromData(data)
+}
+
+// Reads buffer entries captured between 'from' and 'to' (range_descriptor) using clock as restricting object.
+func (obj *ProfileGeneric) ReadByRange(from *DlmsDateTime, to *DlmsDateTime) (entries []ProfileEntry, err error) {
+	return obj.ReadByRangeRestricted(&CaptureObject{ClassId: CLASS_ID_CLOCK, InstanceId: ClockInstanceId, AttributeId: 2, DataIndex: 0}, from, to, nil)
+}
+
+// Reads buffer entries (range_descriptor) restricted by arbitrary capture object. If 'selectedValues' is empty all columns are returned.
+func (obj *ProfileGeneric) ReadByRangeRestricted(restrictingObject *CaptureObject, from *DlmsDateTime, to *DlmsDateTime, selectedValues []*CaptureObject) (entries []ProfileEntry, err error) {
+	accessParameter := new(DlmsData)
+	accessParameter.SetStructure(4)
+	accessParameter.Arr[0] = restrictingObject.toData()   // restricting_object
+	accessParameter.Arr[1].SetOctetString(from.ToBytes()) // from_value
+	accessParameter.Arr[2].SetOctetString(to.ToBytes())   // to_value
+	accessParameter.Arr[3].SetArray(len(selectedValues))  // selected_values
+	for i, captureObject := range selectedValues {
+		accessParameter.Arr[3].Arr[i] = captureObject.toData()
+	}
+	data, err := obj.GetAttributeSelective(2, 1, accessParameter)
+	if nil != err {
+		return nil, err
+	}
+	return entriesFromData(data)
+}
+
+// Reads buffer entries 'fromEntry' to 'toEntry' (entry_descriptor), entries are numbered from 1, 'toEntry' 0 means the last entry.
+func (obj *ProfileGeneric) ReadByEntry(fromEntry uint32, toEntry uint32) (entries []ProfileEntry, err error) {
+	accessParameter := new(DlmsData)
+	accessParameter.SetStructure(4)
+	accessParameter.Arr[0].SetDoubleLongUnsigned(fromEntry) // from_entry
+	accessParameter.Arr[1].SetDoubleLongUnsigned(toEntry)   // to_entry
+	accessParameter.Arr[2].SetLongUnsigned(1)               // from_selected_value
+	accessParameter.Arr[3].SetLongUnsigned(0)               // to_selected_value
+	data, err := obj.GetAttributeSelective(2, 2, accessParameter)
+	if nil != err {
+		return nil, err
+	}
+	return entriesFromData(data)
+}
+
+func (obj *ProfileGeneric) Reset() (err error) {
+	return obj.invokeWithInteger(1)
+}
+
+func (obj *ProfileGeneric) Capture() (err error) {
+	return obj.invokeWithInteger(2)
+}
+
+// Disconnect Control (class_id 70)
+
+type DisconnectControl struct {
+	IcObject
+}
+
+func NewDisconnectControl(aconn *AppConn, instanceId *DlmsOid) *DisconnectControl {
+	return &DisconnectControl{*NewIcObject(aconn, CLASS_ID_DISCONNECT_CONTROL, instanceId)}
+}
+
+func (obj *DisconnectControl) GetOutputState() (outputState bool, err error) {
+	data, err := obj.getTyped(2, DATA_TYPE_BOOLEAN)
+	if nil != err {
+		return false, err
+	}
+	return data.GetBoolean(), nil
+}
+
+func (obj *DisconnectControl) GetControlState() (controlState uint8, err error) {
+	data, err := obj.getTyped(3, DATA_TYPE_ENUM)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetEnum(), nil
+}
+
+func (obj *DisconnectControl) GetControlMode() (controlMode uint8, err error) {
+	data, err := obj.getTyped(4, DATA_TYPE_ENUM)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetEnum(), nil
+}
+
+func (obj *DisconnectControl) RemoteDisconnect() (err error) {
+	return obj.invokeWithInteger(1)
+}
+
+func (obj *DisconnectControl) RemoteConnect() (err error) {
+	return obj.invokeWithInteger(2)
+}
@@ -0,0 +1,275 @@
+package gocosem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIc_Register(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{1, 0, 1, 8, 0, 255}
+	obj := srv.AddObject(CLASS_ID_REGISTER, instanceId)
+	value := new(DlmsData)
+	value.SetDoubleLongUnsigned(12345)
+	obj.SetAttribute(2, value)
+	scalerUnit := new(DlmsData)
+	scalerUnit.SetStructure(2)
+	scalerUnit.Arr[0].SetInteger(-2)
+	scalerUnit.Arr[1].SetEnum(30) // Wh
+	obj.SetAttribute(3, scalerUnit)
+	resets := 0
+	obj.SetMethod(1, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		resets += 1
+		return actionResult_success, nil, nil
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	reg := NewRegister(aconn, instanceId)
+	val, unit, err := reg.GetValueScaled()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (val < 123.449) || (val > 123.451) || (30 != unit) {
+		t.Fatalf("value: %f, unit: %d", val, unit)
+	}
+
+	ln, err := reg.LogicalName()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if *instanceId != *ln {
+		t.Fatalf("logical name differs")
+	}
+
+	err = reg.Reset()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 1 != resets {
+		t.Fatalf("reset not invoked")
+	}
+
+	_, err = NewRegister(aconn, &DlmsOid{1, 0, 2, 8, 0, 255}).GetValue()
+	if e, ok := err.(*DataAccessError); !ok || (dataAccessResult_objectUndefined != e.DataAccessResult) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIc_Clock(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &ClockInstanceId
+	obj := srv.AddObject(CLASS_ID_CLOCK, instanceId)
+	obj.SetAttribute(2, new(DlmsData))
+	timeZone := new(DlmsData)
+	timeZone.SetLong(-60)
+	obj.SetAttribute(3, timeZone)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	clock := NewClock(aconn, instanceId)
+
+	dateTime := new(DlmsDateTime)
+	dateTime.Year = 2016
+	dateTime.Month = 3
+	dateTime.DayOfMonth = 14
+	dateTime.DayOfWeek = 1
+	dateTime.Hour = 10
+	dateTime.Minute = 20
+	dateTime.Second = 30
+	dateTime.SetDeviationWildcard()
+	err := clock.SetTime(dateTime)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	tm, err := clock.GetTime()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if *dateTime != *tm {
+		t.Fatalf("time differs: %s", tm.PrintDateTime())
+	}
+
+	tz, err := clock.GetTimeZone()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if -60 != tz {
+		t.Fatalf("time zone: %d", tz)
+	}
+}
+
+func TestIc_ProfileGeneric(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{1, 0, 99, 1, 0, 255}
+	obj := srv.AddObject(CLASS_ID_PROFILE_GENERIC, instanceId)
+
+	captureObjects := new(DlmsData)
+	captureObjects.SetArray(2)
+	captureObjects.Arr[0] = (&CaptureObject{CLASS_ID_CLOCK, ClockInstanceId, 2, 0}).toData()
+	captureObjects.Arr[1] = (&CaptureObject{CLASS_ID_REGISTER, DlmsOid{1, 0, 1, 8, 0, 255}, 2, 0}).toData()
+	obj.SetAttribute(3, captureObjects)
+
+	buffer := new(DlmsData)
+	buffer.SetArray(10)
+	for i := 0; i < 10; i++ {
+		dateTime := new(DlmsDateTime)
+		dateTime.Year = 2016
+		dateTime.Month = 1
+		dateTime.DayOfMonth = uint8(i + 1)
+		buffer.Arr[i].SetStructure(2)
+		buffer.Arr[i].Arr[0].SetOctetString(dateTime.ToBytes())
+		buffer.Arr[i].Arr[1].SetDoubleLongUnsigned(uint32(i))
+	}
+	entriesInUse := new(DlmsData)
+	entriesInUse.SetDoubleLongUnsigned(10)
+	obj.SetAttribute(7, entriesInUse)
+
+	obj.SetAttributeGetter(2, func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (DlmsDataAccessResult, *DlmsData) {
+		entries := buffer.Arr
+		switch accessSelector {
+		case 0:
+		case 1:
+			from := DlmsDateTimeFromBytes(accessParameters.Arr[1].GetOctetString())
+			to := DlmsDateTimeFromBytes(accessParameters.Arr[2].GetOctetString())
+			entries = make([]*DlmsData, 0)
+			for _, entry := range buffer.Arr {
+				day := DlmsDateTimeFromBytes(entry.Arr[0].GetOctetString()).DayOfMonth
+				if (day >= from.DayOfMonth) && (day <= to.DayOfMonth) {
+					entries = append(entries, entry)
+				}
+			}
+		case 2:
+			entries = buffer.Arr[accessParameters.Arr[0].GetDoubleLongUnsigned()-1 : accessParameters.Arr[1].GetDoubleLongUnsigned()]
+		default:
+			return dataAccessResult_otherReason, nil
+		}
+		data := new(DlmsData)
+		data.SetArray(0)
+		data.Arr = entries
+		return dataAccessResult_success, data
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	profile := NewProfileGeneric(aconn, instanceId)
+
+	cos, err := profile.GetCaptureObjects()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (2 != len(cos)) || (CLASS_ID_CLOCK != cos[0].ClassId) || (CLASS_ID_REGISTER != cos[1].ClassId) || !bytes.Equal(ClockInstanceId[:], cos[0].InstanceId[:]) {
+		t.Fatalf("capture objects differ")
+	}
+
+	n, err := profile.GetEntriesInUse()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 10 != n {
+		t.Fatalf("entries in use: %d", n)
+	}
+
+	entries, err := profile.ReadAll()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 10 != len(entries) {
+		t.Fatalf("entries: %d", len(entries))
+	}
+
+	from := &DlmsDateTime{DlmsDate: DlmsDate{Year: 2016, Month: 1, DayOfMonth: 3}}
+	to := &DlmsDateTime{DlmsDate: DlmsDate{Year: 2016, Month: 1, DayOfMonth: 5}}
+	entries, err = profile.ReadByRange(from, to)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (3 != len(entries)) || (2 != entries[0][1].GetDoubleLongUnsigned()) || (4 != entries[2][1].GetDoubleLongUnsigned()) {
+		t.Fatalf("range read returned wrong entries: %d", len(entries))
+	}
+
+	entries, err = profile.ReadByEntry(8, 10)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (3 != len(entries)) || (7 != entries[0][1].GetDoubleLongUnsigned()) {
+		t.Fatalf("entry read returned wrong entries")
+	}
+}
+
+func TestIc_ProfileGeneric_malformedCaptureObject(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{1, 0, 99, 1, 0, 255}
+	obj := srv.AddObject(CLASS_ID_PROFILE_GENERIC, instanceId)
+
+	captureObjects := new(DlmsData)
+	captureObjects.SetArray(1)
+	captureObjects.Arr[0] = (&CaptureObject{CLASS_ID_REGISTER, DlmsOid{1, 0, 1, 8, 0, 255}, 2, 0}).toData()
+	captureObjects.Arr[0].Arr[2].SetUnsigned(2) // attribute index must be integer
+	obj.SetAttribute(3, captureObjects)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	_, err := NewProfileGeneric(aconn, instanceId).GetCaptureObjects()
+	if nil == err {
+		t.Fatalf("malformed capture object accepted")
+	}
+}
+
+func TestIc_DisconnectControl(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0, 0, 96, 3, 10, 255}
+	obj := srv.AddObject(CLASS_ID_DISCONNECT_CONTROL, instanceId)
+	setState := func(outputState bool, controlState uint8) {
+		data := new(DlmsData)
+		data.SetBoolean(outputState)
+		obj.SetAttribute(2, data)
+		data = new(DlmsData)
+		data.SetEnum(controlState)
+		obj.SetAttribute(3, data)
+	}
+	setState(true, DISCONNECT_CONTROL_STATE_CONNECTED)
+	obj.SetMethod(1, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		setState(false, DISCONNECT_CONTROL_STATE_DISCONNECTED)
+		return actionResult_success, nil, nil
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	dc := NewDisconnectControl(aconn, instanceId)
+	err := dc.RemoteDisconnect()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	outputState, err := dc.GetOutputState()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	controlState, err := dc.GetControlState()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if outputState || (DISCONNECT_CONTROL_STATE_DISCONNECTED != controlState) {
+		t.Fatalf("not disconnected")
+	}
+
+	err = dc.RemoteConnect()
+	if e, ok := err.(*ActionError); !ok || (actionResult_objectUndefined != e.ActionResult) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package gocosem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Baud rates of IEC 62056-21 protocol modes C and E indexed by baud rate character '0' to '6'.
+var iecBaudRates = []int{300, 600, 1200, 2400, 4800, 9600, 19200}
+
+const (
+	iecAck = 0x06
+
+	iecMaxLineLength = 128
+)
+
+// Identification message sent by meter in reply to IEC 62056-21 sign-on.
+type IecIdentification struct {
+	Manufacturer   string // three letter manufacturer id
+	BaudRate       int    // maximal baud rate proposed by meter
+	ModeE          bool   // meter announced HDLC protocol (mode E) using enhanced capability sequence "\2"
+	Identification string
+}
+
+func parseIecIdentification(line []byte) (ident *IecIdentification, err error) {
+	s := strings.TrimRight(string(line), "\r\n")
+	if (len(s) < 5) || ('/' != s[0]) {
+		err = fmt.Errorf("malformed identification message: %q", s)
+		errorLog("%s", err)
+		return nil, err
+	}
+	ident = new(IecIdentification)
+	ident.Manufacturer = s[1:4]
+	z := s[4]
+	if (z < '0') || (int(z-'0') >= len(iecBaudRates)) {
+		err = fmt.Errorf("identification message: unsupported baud rate character: %q", z)
+		errorLog("%s", err)
+		return nil, err
+	}
+	ident.BaudRate = iecBaudRates[z-'0']
+	s = s[5:]
+	if strings.HasPrefix(s, "\\2") {
+		ident.ModeE = true
+		s = s[2:]
+	}
+	ident.Identification = s
+	return ident, nil
+}
+
+// Reads one line terminated by CR LF. Parity bit is stripped.
+func iecReadLine(port io.Reader, timeout time.Duration) (line []byte, err error) {
+	if d, ok := port.(tReadDeadliner); ok {
+		d.SetReadDeadline(time.Now().Add(timeout))
+		defer d.SetReadDeadline(time.Time{})
+	}
+	var buf bytes.Buffer
+	p := make([]byte, 1)
+	for buf.Len() < iecMaxLineLength {
+		_, err = io.ReadFull(port, p)
+		if nil != err {
+			if isTimeOutErr(err) {
+				err = ErrDlmsTimeout
+			}
+			errorLog("reading identification message failed: %v", err)
+			return nil, err
+		}
+		buf.WriteByte(p[0] & 0x7F)
+		if bytes.HasSuffix(buf.Bytes(), []byte("\r\n")) {
+			return buf.Bytes(), nil
+		}
+	}
+	err = fmt.Errorf("identification message too long")
+	errorLog("%s", err)
+	return nil, err
+}
+
+/*
+Runs IEC 62056-21 mode E opening sequence on serial port opened at 300 baud
+7E1: sends sign-on request "/?<deviceAddress>!", reads identification message
+and selects binary HDLC mode with ACK option select message. Baud rate
+proposed by meter is limited to 'maxBaudRate' (0 means no limit). Port is then
+switched to selected baud rate 8N1 and it is ready to be passed to
+HdlcConnectRW().
+*/
+func IecModeE(port io.ReadWriteCloser, deviceAddress string, maxBaudRate int, timeout time.Duration) (ident *IecIdentification, err error) {
+	signOn := fmt.Sprintf("/?%s!\r\n", deviceAddress)
+	debugLog("iec 62056-21: sending sign-on: %q", signOn)
+	_, err = port.Write([]byte(signOn))
+	if nil != err {
+		errorLog("port.Write() failed: %v", err)
+		return nil, err
+	}
+
+	var line []byte
+	for {
+		line, err = iecReadLine(port, timeout)
+		if nil != err {
+			return nil, err
+		}
+		// optical heads may echo sent characters
+		if !bytes.HasPrefix(line, []byte("/?")) {
+			break
+		}
+	}
+	ident, err = parseIecIdentification(line)
+	if nil != err {
+		return nil, err
+	}
+	debugLog("iec 62056-21: identification: manufacturer: %s, baud rate: %d, mode E: %v, identification: %s", ident.Manufacturer, ident.BaudRate, ident.ModeE, ident.Identification)
+	if !ident.ModeE {
+		warnLog("iec 62056-21: meter did not announce mode E, trying HDLC anyway")
+	}
+
+	z := 0
+	for i, baudRate := range iecBaudRates {
+		if (baudRate <= ident.BaudRate) && ((0 == maxBaudRate) || (baudRate <= maxBaudRate)) {
+			z = i
+		}
+	}
+
+	// ACK V Z Y CR LF: protocol control character '2' and mode control character '2' select binary mode HDLC
+	ack := []byte{iecAck, '2', byte('0' + z), '2', '\r', '\n'}
+	debugLog("iec 62056-21: selecting mode E, baud rate: %d", iecBaudRates[z])
+	_, err = port.Write(ack)
+	if nil != err {
+		errorLog("port.Write() failed: %v", err)
+		return nil, err
+	}
+
+	err = SetSerialConfig(port, &SerialConfig{BaudRate: iecBaudRates[z], DataBits: 8, Parity: SerialParityNone, StopBits: 1})
+	if nil != err {
+		return nil, err
+	}
+	return ident, nil
+}
+
+// Connects hdlc transport over optical probe using IEC 62056-21 mode E. For meaning of other parameters see HdlcConnect().
+func HdlcConnectOptical(device string, deviceAddress string, maxBaudRate int, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, ident *IecIdentification, err error) {
+	debugLog("connecting hdlc transport over optical probe: %s\n", device)
+	port, err := OpenSerial(device, &SerialConfig{BaudRate: 300, DataBits: 7, Parity: SerialParityEven, StopBits: 1})
+	if nil != err {
+		return nil, nil, err
+	}
+	ident, err = IecModeE(port, deviceAddress, maxBaudRate, snrmTimeout)
+	if nil != err {
+		port.Close()
+		return nil, nil, err
+	}
+	dconn, err = HdlcConnectRW(port, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+	if nil != err {
+		return nil, nil, err
+	}
+	return dconn, ident, nil
+}
@@ -0,0 +1,30 @@
+package gocosem
+
+import (
+	"testing"
+)
+
+func TestIec_parseIecIdentification(t *testing.T) {
+	ident, err := parseIecIdentification([]byte("/ABC6\\2METER01\r\n"))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if ("ABC" != ident.Manufacturer) || (19200 != ident.BaudRate) || !ident.ModeE || ("METER01" != ident.Identification) {
+		t.Fatalf("unexpected identification: %+v", ident)
+	}
+
+	ident, err = parseIecIdentification([]byte("/XYZ4MT174\r\n"))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (4800 != ident.BaudRate) || ident.ModeE || ("MT174" != ident.Identification) {
+		t.Fatalf("unexpected identification: %+v", ident)
+	}
+
+	for _, s := range []string{"", "ABC6\r\n", "/ABC\r\n", "/ABCA\r\n", "/ABC9\r\n"} {
+		_, err = parseIecIdentification([]byte(s))
+		if nil == err {
+			t.Fatalf("%q: parsed", s)
+		}
+	}
+}
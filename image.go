@@ -0,0 +1,371 @@
+package gocosem
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Image Transfer (class_id 18)
+
+const (
+	IMAGE_TRANSFER_STATUS_NOT_INITIATED           uint8 = 0
+	IMAGE_TRANSFER_STATUS_INITIATED               uint8 = 1
+	IMAGE_TRANSFER_STATUS_VERIFICATION_INITIATED  uint8 = 2
+	IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL uint8 = 3
+	IMAGE_TRANSFER_STATUS_VERIFICATION_FAILED     uint8 = 4
+	IMAGE_TRANSFER_STATUS_ACTIVATION_INITIATED    uint8 = 5
+	IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL   uint8 = 6
+	IMAGE_TRANSFER_STATUS_ACTIVATION_FAILED       uint8 = 7
+)
+
+type ImageToActivateInfo struct {
+	Size           uint32
+	Identification []byte
+	Signature      []byte
+}
+
+type ImageTransfer struct {
+	IcObject
+}
+
+func NewImageTransfer(aconn *AppConn, instanceId *DlmsOid) *ImageTransfer {
+	return &ImageTransfer{*NewIcObject(aconn, CLASS_ID_IMAGE_TRANSFER, instanceId)}
+}
+
+func (obj *ImageTransfer) GetImageBlockSize() (blockSize uint32, err error) {
+	data, err := obj.getTyped(2, DATA_TYPE_DOUBLE_LONG_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetDoubleLongUnsigned(), nil
+}
+
+// Returns transfer status of each block, blocks are numbered from 0.
+func (obj *ImageTransfer) GetTransferredBlocksStatus() (transferred []bool, err error) {
+	data, err := obj.getTyped(3, DATA_TYPE_BIT_STRING)
+	if nil != err {
+		return nil, err
+	}
+	b, length := data.GetBitString()
+	transferred = make([]bool, length)
+	for i := 0; i < int(length); i++ {
+		transferred[i] = 0 != b[i/8]&(0x80>>uint(i%8))
+	}
+	return transferred, nil
+}
+
+func (obj *ImageTransfer) GetFirstNotTransferredBlockNumber() (blockNumber uint32, err error) {
+	data, err := obj.getTyped(4, DATA_TYPE_DOUBLE_LONG_UNSIGNED)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetDoubleLongUnsigned(), nil
+}
+
+func (obj *ImageTransfer) GetTransferEnabled() (enabled bool, err error) {
+	data, err := obj.getTyped(5, DATA_TYPE_BOOLEAN)
+	if nil != err {
+		return false, err
+	}
+	return data.GetBoolean(), nil
+}
+
+func (obj *ImageTransfer) GetTransferStatus() (status uint8, err error) {
+	data, err := obj.getTyped(6, DATA_TYPE_ENUM)
+	if nil != err {
+		return 0, err
+	}
+	return data.GetEnum(), nil
+}
+
+func (obj *ImageTransfer) GetImageToActivateInfo() (infos []*ImageToActivateInfo, err error) {
+	data, err := obj.getTyped(7, DATA_TYPE_ARRAY)
+	if nil != err {
+		return nil, err
+	}
+	infos = make([]*ImageToActivateInfo, len(data.Arr))
+	for i, d := range data.Arr {
+		if (DATA_TYPE_STRUCTURE != d.GetType()) || (3 != len(d.Arr)) {
+			err = fmt.Errorf("malformed image_to_activate_info")
+			errorLog("%s", err)
+			return nil, err
+		}
+		infos[i] = &ImageToActivateInfo{Size: d.Arr[0].GetDoubleLongUnsigned(), Identification: d.Arr[1].GetOctetString(), Signature: d.Arr[2].GetOctetString()}
+	}
+	return infos, nil
+}
+
+func (obj *ImageTransfer) Initiate(identifier []byte, size uint32) (err error) {
+	methodParameters := new(DlmsData)
+	methodParameters.SetStructure(2)
+	methodParameters.Arr[0].SetOctetString(identifier)
+	methodParameters.Arr[1].SetDoubleLongUnsigned(size)
+	_, err = obj.Invoke(1, methodParameters)
+	return err
+}
+
+func (obj *ImageTransfer) TransferBlock(blockNumber uint32, block []byte) (err error) {
+	methodParameters := new(DlmsData)
+	methodParameters.SetStructure(2)
+	methodParameters.Arr[0].SetDoubleLongUnsigned(blockNumber)
+	methodParameters.Arr[1].SetOctetString(block)
+	_, err = obj.Invoke(2, methodParameters)
+	return err
+}
+
+// Initiates image verification. Action result temporary-failure means verification is in progress.
+func (obj *ImageTransfer) Verify() (err error) {
+	return obj.invokeWithInteger(3)
+}
+
+// Initiates image activation. Action result temporary-failure means activation is in progress.
+func (obj *ImageTransfer) Activate() (err error) {
+	return obj.invokeWithInteger(4)
+}
+
+/*
+Image transfer workflow: initiates transfer, transfers image in blocks of
+image_block_size, resends blocks reported missing in
+image_transferred_blocks_status and finally verifies and activates image.
+
+If Upgrade() fails (e.g. association is dropped) it may be called again with
+same ImageUpdate and image transfer object of new association, transfer is
+resumed from where it stopped. Transfer is resumed only if image known to
+server is the one being transferred: image_to_activate_info must list image
+of same identifier and size or, before verification when server provides no
+image_to_activate_info, image_transferred_blocks_status must have as many
+blocks as the image. Otherwise new transfer is initiated.
+*/
+type ImageUpdate struct {
+	Identifier   []byte
+	Image        []byte
+	Progress     func(blocksTransferred uint32, blocksTotal uint32) // called after each transferred block
+	PollInterval time.Duration                                      // interval of polling image_transfer_status while verification or activation is in progress
+	PollTimeout  time.Duration                                      // maximum time to wait for verification or activation
+	MaxRetries   int                                                // number of attempts to resend missing blocks
+
+	// If true, image transfer initiated on server is resumed instead of initiating new one. Set after image transfer was initiated.
+	Resume bool
+}
+
+func NewImageUpdate(identifier []byte, image []byte) *ImageUpdate {
+	upd := new(ImageUpdate)
+	upd.Identifier = identifier
+	upd.Image = image
+	upd.PollInterval = time.Duration(1) * time.Second
+	upd.PollTimeout = time.Duration(60) * time.Second
+	upd.MaxRetries = 3
+	return upd
+}
+
+func (upd *ImageUpdate) progress(blocksTransferred uint32, blocksTotal uint32) {
+	if nil != upd.Progress {
+		upd.Progress(blocksTransferred, blocksTotal)
+	}
+}
+
+// Transfers, verifies and activates image.
+func (obj *ImageTransfer) Upgrade(upd *ImageUpdate) (err error) {
+	enabled, err := obj.GetTransferEnabled()
+	if nil != err {
+		return err
+	}
+	if !enabled {
+		err = fmt.Errorf("image transfer not enabled")
+		errorLog("%s", err)
+		return err
+	}
+
+	status, err := obj.GetTransferStatus()
+	if nil != err {
+		return err
+	}
+	if !upd.Resume {
+		status = IMAGE_TRANSFER_STATUS_NOT_INITIATED
+	}
+	if IMAGE_TRANSFER_STATUS_NOT_INITIATED != status {
+		err, same := obj.sameImage(upd, status)
+		if nil != err {
+			return err
+		}
+		if !same {
+			debugLog("image transfer: server has different image, initiating new transfer")
+			status = IMAGE_TRANSFER_STATUS_NOT_INITIATED
+		}
+	}
+	debugLog("image transfer status: %d", status)
+
+	switch status {
+	case IMAGE_TRANSFER_STATUS_NOT_INITIATED, IMAGE_TRANSFER_STATUS_INITIATED, IMAGE_TRANSFER_STATUS_VERIFICATION_FAILED:
+		err = obj.transferImage(upd, IMAGE_TRANSFER_STATUS_INITIATED == status)
+		if nil != err {
+			return err
+		}
+		err = obj.verify(upd)
+		if nil != err {
+			return err
+		}
+		return obj.activate(upd)
+	case IMAGE_TRANSFER_STATUS_VERIFICATION_INITIATED:
+		err = obj.waitForStatus(upd, IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL, IMAGE_TRANSFER_STATUS_VERIFICATION_FAILED)
+		if nil != err {
+			return err
+		}
+		return obj.activate(upd)
+	case IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL, IMAGE_TRANSFER_STATUS_ACTIVATION_FAILED:
+		return obj.activate(upd)
+	case IMAGE_TRANSFER_STATUS_ACTIVATION_INITIATED:
+		return obj.waitForStatus(upd, IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL, IMAGE_TRANSFER_STATUS_ACTIVATION_FAILED)
+	case IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL:
+		return nil
+	default:
+		err = fmt.Errorf("unknown image transfer status: %d", status)
+		errorLog("%s", err)
+		return err
+	}
+}
+
+// Returns true if image transfer on server is transfer of 'upd' image.
+func (obj *ImageTransfer) sameImage(upd *ImageUpdate, status uint8) (err error, same bool) {
+	infos, err := obj.GetImageToActivateInfo()
+	if nil != err {
+		return err, false
+	}
+	if len(infos) > 0 {
+		for _, info := range infos {
+			if (uint32(len(upd.Image)) == info.Size) && bytes.Equal(upd.Identifier, info.Identification) {
+				return nil, true
+			}
+		}
+		return nil, false
+	}
+	if IMAGE_TRANSFER_STATUS_INITIATED != status {
+		return nil, false
+	}
+
+	// image is not identified before verification, at least its size must match
+	blockSize, err := obj.GetImageBlockSize()
+	if nil != err {
+		return err, false
+	}
+	if 0 == blockSize {
+		return nil, false
+	}
+	transferred, err := obj.GetTransferredBlocksStatus()
+	if nil != err {
+		return err, false
+	}
+	return nil, len(transferred) == (len(upd.Image)+int(blockSize)-1)/int(blockSize)
+}
+
+func (obj *ImageTransfer) transferImage(upd *ImageUpdate, resume bool) (err error) {
+	blockSize, err := obj.GetImageBlockSize()
+	if nil != err {
+		return err
+	}
+	if 0 == blockSize {
+		err = fmt.Errorf("image block size is 0")
+		errorLog("%s", err)
+		return err
+	}
+	blocksTotal := uint32((len(upd.Image) + int(blockSize) - 1) / int(blockSize))
+
+	if !resume {
+		debugLog("initiating image transfer: %d bytes, %d blocks", len(upd.Image), blocksTotal)
+		err = obj.Initiate(upd.Identifier, uint32(len(upd.Image)))
+		if nil != err {
+			return err
+		}
+		upd.Resume = true
+	}
+
+	for retry := 0; ; retry++ {
+		transferred := make([]bool, blocksTotal)
+		if resume || (retry > 0) {
+			status, err := obj.GetTransferredBlocksStatus()
+			if nil != err {
+				return err
+			}
+			copy(transferred, status)
+		}
+
+		blocksTransferred := uint32(0)
+		for _, ok := range transferred {
+			if ok {
+				blocksTransferred++
+			}
+		}
+		if blocksTransferred == blocksTotal {
+			return nil
+		}
+		if retry > upd.MaxRetries {
+			err = fmt.Errorf("image transfer: %d blocks not transferred", blocksTotal-blocksTransferred)
+			errorLog("%s", err)
+			return err
+		}
+		debugLog("image transfer: transferring %d of %d blocks", blocksTotal-blocksTransferred, blocksTotal)
+
+		for i := uint32(0); i < blocksTotal; i++ {
+			if transferred[i] {
+				continue
+			}
+			end := (i + 1) * blockSize
+			if end > uint32(len(upd.Image)) {
+				end = uint32(len(upd.Image))
+			}
+			err = obj.TransferBlock(i, upd.Image[i*blockSize:end])
+			if nil != err {
+				return err
+			}
+			blocksTransferred++
+			upd.progress(blocksTransferred, blocksTotal)
+		}
+	}
+}
+
+// Invokes verification or activation, action result temporary-failure means that it is in progress.
+func (obj *ImageTransfer) invokeAndWait(upd *ImageUpdate, invoke func() error, successStatus uint8, failureStatus uint8) (err error) {
+	err = invoke()
+	if nil == err {
+		return nil
+	}
+	if e, ok := err.(*ActionError); !ok || (actionResult_temporaryFailure != e.ActionResult) {
+		return err
+	}
+	return obj.waitForStatus(upd, successStatus, failureStatus)
+}
+
+func (obj *ImageTransfer) verify(upd *ImageUpdate) (err error) {
+	debugLog("verifying image")
+	return obj.invokeAndWait(upd, obj.Verify, IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL, IMAGE_TRANSFER_STATUS_VERIFICATION_FAILED)
+}
+
+func (obj *ImageTransfer) activate(upd *ImageUpdate) (err error) {
+	debugLog("activating image")
+	return obj.invokeAndWait(upd, obj.Activate, IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL, IMAGE_TRANSFER_STATUS_ACTIVATION_FAILED)
+}
+
+func (obj *ImageTransfer) waitForStatus(upd *ImageUpdate, successStatus uint8, failureStatus uint8) (err error) {
+	deadline := time.Now().Add(upd.PollTimeout)
+	for {
+		status, err := obj.GetTransferStatus()
+		if nil != err {
+			return err
+		}
+		switch status {
+		case successStatus:
+			return nil
+		case failureStatus:
+			err = fmt.Errorf("image transfer failed, status: %d", status)
+			errorLog("%s", err)
+			return err
+		}
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("image transfer: timeout waiting for status %d, status: %d", successStatus, status)
+			errorLog("%s", err)
+			return err
+		}
+		time.Sleep(upd.PollInterval)
+	}
+}
@@ -0,0 +1,208 @@
+package gocosem
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var imageTransferInstanceId = DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}
+
+func connectMockCosemServer(t *testing.T) (dconn *DlmsConn, aconn *AppConn) {
+	dconn, err := TcpConnect("localhost", 4059)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn, err = dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		dconn.Close()
+		t.Fatalf("%s\n", err)
+	}
+	return dconn, aconn
+}
+
+func TestImage_Upgrade(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	it := mockCosemServer.setImageTransfer(&imageTransferInstanceId, 16, 1, 3)
+
+	dconn, aconn := connectMockCosemServer(t)
+	defer dconn.Close()
+
+	image := generateBytes(100)
+	upd := NewImageUpdate([]byte("fw-1.2.3"), image)
+	upd.PollInterval = time.Duration(10) * time.Millisecond
+	calls := 0
+	upd.Progress = func(blocksTransferred uint32, blocksTotal uint32) {
+		calls += 1
+		if (7 != blocksTotal) || (blocksTransferred > blocksTotal) {
+			t.Errorf("progress: %d of %d", blocksTransferred, blocksTotal)
+		}
+	}
+
+	obj := NewImageTransfer(aconn, &imageTransferInstanceId)
+	err := obj.Upgrade(upd)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(image, it.image()) {
+		t.Fatalf("image differs")
+	}
+	if 9 != calls {
+		t.Fatalf("progress called %d times", calls)
+	}
+
+	status, err := obj.GetTransferStatus()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL != status {
+		t.Fatalf("status: %d", status)
+	}
+	infos, err := obj.GetImageToActivateInfo()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (1 != len(infos)) || (100 != infos[0].Size) || !bytes.Equal([]byte("fw-1.2.3"), infos[0].Identification) {
+		t.Fatalf("unexpected image_to_activate_info")
+	}
+}
+
+func TestImage_Upgrade_resume(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	it := mockCosemServer.setImageTransfer(&imageTransferInstanceId, 10)
+
+	dconn, aconn := connectMockCosemServer(t)
+
+	image := generateBytes(95)
+	upd := NewImageUpdate([]byte("fw-1.2.4"), image)
+	upd.PollInterval = time.Duration(10) * time.Millisecond
+	upd.Progress = func(blocksTransferred uint32, blocksTotal uint32) {
+		if 4 == blocksTransferred {
+			// association dropped during transfer
+			dconn.Close()
+		}
+	}
+
+	err := NewImageTransfer(aconn, &imageTransferInstanceId).Upgrade(upd)
+	if nil == err {
+		t.Fatalf("transfer not interrupted")
+	}
+	if !upd.Resume {
+		t.Fatalf("transfer not resumable")
+	}
+
+	dconn, aconn = connectMockCosemServer(t)
+	defer dconn.Close()
+
+	first := uint32(0)
+	upd.Progress = func(blocksTransferred uint32, blocksTotal uint32) {
+		if 0 == first {
+			first = blocksTransferred
+		}
+	}
+	obj := NewImageTransfer(aconn, &imageTransferInstanceId)
+	blockNumber, err := obj.GetFirstNotTransferredBlockNumber()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 4 != blockNumber {
+		t.Fatalf("first not transferred block: %d", blockNumber)
+	}
+	err = obj.Upgrade(upd)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 5 != first {
+		t.Fatalf("transfer not resumed, first progress: %d", first)
+	}
+	if 1 != it.initiations {
+		t.Fatalf("transfer initiated %d times", it.initiations)
+	}
+	if !bytes.Equal(image, it.image()) {
+		t.Fatalf("image differs")
+	}
+}
+
+func TestImage_Upgrade_resumeDifferentImage(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	it := mockCosemServer.setImageTransfer(&imageTransferInstanceId, 10)
+
+	dconn, aconn := connectMockCosemServer(t)
+	defer dconn.Close()
+
+	// other image transferred and verified in the meantime
+	obj := NewImageTransfer(aconn, &imageTransferInstanceId)
+	err := obj.Initiate([]byte("fw-other"), 5)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	err = obj.TransferBlock(0, []byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	obj.Verify()
+	status, err := obj.GetTransferStatus()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL != status {
+		t.Fatalf("status: %d", status)
+	}
+
+	image := generateBytes(95)
+	upd := NewImageUpdate([]byte("fw-1.2.4"), image)
+	upd.PollInterval = time.Duration(10) * time.Millisecond
+	upd.Resume = true
+	err = obj.Upgrade(upd)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 2 != it.initiations {
+		t.Fatalf("transfer initiated %d times", it.initiations)
+	}
+	if !bytes.Equal(image, it.image()) {
+		t.Fatalf("image differs")
+	}
+}
+
+func TestImage_Upgrade_resumeDifferentSize(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	it := mockCosemServer.setImageTransfer(&imageTransferInstanceId, 10)
+
+	dconn, aconn := connectMockCosemServer(t)
+	defer dconn.Close()
+
+	// other image being transferred, server does not identify it before verification
+	obj := NewImageTransfer(aconn, &imageTransferInstanceId)
+	err := obj.Initiate([]byte("fw-other"), 50)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	image := generateBytes(95)
+	upd := NewImageUpdate([]byte("fw-1.2.4"), image)
+	upd.PollInterval = time.Duration(10) * time.Millisecond
+	upd.Resume = true
+	err = obj.Upgrade(upd)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 2 != it.initiations {
+		t.Fatalf("transfer initiated %d times", it.initiations)
+	}
+	if !bytes.Equal(image, it.image()) {
+		t.Fatalf("image differs")
+	}
+}
@@ -23,13 +23,56 @@ type tMockCosemObject struct {
 type tMockCosemServer struct {
 	//closed              bool
 	ln                  net.Listener
-	connections         *list.List  // list of *tMockCosemServerConnection
-	connections_mtx     *sync.Mutex // TODO: avoid mutex
-	objects             map[string]*tMockCosemObject
+	connections         *list.List                   // list of *tMockCosemServerConnection
+	connections_mtx     *sync.Mutex                  // TODO: avoid mutex
+	objects             map[string]*tMockCosemObject // guarded by connections_mtx
 	blockLength         int
 	replyDelayMsec      int
 	blockDelayMsec      int
 	blockDelayLastBlock bool
+	errorReply          []byte // if non nil then sent as reply to any request (ExceptionResponse, ConfirmedServiceError)
+	gbtBlockLength      int    // if > 0 then replies longer then 'gbtBlockLength' are sent using general block transfer
+	gbtWindowSize       uint8  // receive window used for inbound general block transfer
+	gbtLostBlock        uint16 // if > 0 then this general block transfer block is dropped when sent first time
+	pc                  net.PacketConn
+	udpConns            map[string]*tMockUdpConn // udp connections, key is client address
+	udpServing          int                      // count of udp connections being served
+	udpServingCond      *sync.Cond               // signalled when udp connection stops being served
+	udpDropDatagrams    int                      // count of received udp datagrams to be dropped
+}
+
+// Server side of DLMS wrapper over UDP, one per client address.
+type tMockUdpConn struct {
+	pc        net.PacketConn
+	addr      net.Addr
+	ch        chan []byte
+	closedCh  chan bool
+	closeOnce sync.Once
+	datagram  []byte // unread rest of last received datagram
+}
+
+func (conn *tMockUdpConn) Read(p []byte) (n int, err error) {
+	if 0 == len(conn.datagram) {
+		select {
+		case conn.datagram = <-conn.ch:
+		case <-conn.closedCh:
+			return 0, io.EOF
+		}
+	}
+	n = copy(p, conn.datagram)
+	conn.datagram = conn.datagram[n:]
+	return n, nil
+}
+
+func (conn *tMockUdpConn) Write(p []byte) (n int, err error) {
+	return conn.pc.WriteTo(p, conn.addr)
+}
+
+func (conn *tMockUdpConn) Close() error {
+	conn.closeOnce.Do(func() {
+		close(conn.closedCh)
+	})
+	return nil
 }
 
 type tMockCosemServerConnection struct {
@@ -47,6 +90,35 @@ type tMockCosemServerConnection struct {
 	methodIds        map[uint8][]DlmsAttributeId    // key is invokeId
 	accessSelectors  map[uint8][]DlmsAccessSelector // key is invokeId
 	accessParameters map[uint8][]*DlmsData          // key is invokeId
+	gbt              *tGbtEndpoint
+}
+
+func (conn *tMockCosemServerConnection) getGbt() *tGbtEndpoint {
+	if nil == conn.gbt {
+		dropped := false
+		conn.gbt = newGbtEndpoint(
+			func(pdu []byte) error {
+				// block-number is at offset 2, length of block-data at offset 6
+				if (0 != conn.srv.gbtLostBlock) && !dropped && (len(pdu) > 6) && (conn.srv.gbtLostBlock == binary.BigEndian.Uint16(pdu[2:4])) && (0 != pdu[6]) {
+					dropped = true
+					return nil
+				}
+				return ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, pdu)
+			},
+			func() ([]byte, error) {
+				pdu, _, _, err := ipTransportReceive(conn.rwc, &conn.applicationClient, &conn.logicalDevice)
+				return pdu, err
+			},
+			conn.srv.gbtWindowSize)
+	}
+	return conn.gbt
+}
+
+func (conn *tMockCosemServerConnection) sendReply(pdu []byte) (err error) {
+	if (conn.srv.gbtBlockLength > 0) && (len(pdu) > conn.srv.gbtBlockLength) {
+		return conn.getGbt().sendApdu(pdu, conn.srv.gbtBlockLength)
+	}
+	return ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, pdu)
 }
 
 //TODO: refactor
@@ -88,7 +160,7 @@ func (conn *tMockCosemServerConnection) sendEncodedReply(t *testing.T, b0 byte,
 			t.Errorf("%v\n", err)
 			return err
 		}
-		err = ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, buf.Bytes())
+		err = conn.sendReply(buf.Bytes())
 		if nil != err {
 			t.Errorf("%v\n", err)
 			return err
@@ -114,7 +186,7 @@ func (conn *tMockCosemServerConnection) sendEncodedReply(t *testing.T, b0 byte,
 			t.Errorf("%v\n", err)
 			return err
 		}
-		err = ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, buf.Bytes())
+		err = conn.sendReply(buf.Bytes())
 		if nil != err {
 			t.Errorf("%v\n", err)
 			return err
@@ -233,6 +305,31 @@ func (conn *tMockCosemServerConnection) setBlockListReply(t *testing.T, invokeId
 	return nil
 }
 
+func (conn *tMockCosemServerConnection) replyToRelease(t *testing.T, pdu []byte) (err error) {
+	err, _ = decode_RLRQapdu(bytes.NewReader(pdu))
+	if nil != err {
+		t.Errorf("%v\n", err)
+		return err
+	}
+
+	t.Logf("sending RLRE")
+
+	var buf bytes.Buffer
+	reason := RLRE_REASON_NORMAL
+	err = encode_RLREapdu(&buf, &RLREapdu{reason: &reason})
+	if nil != err {
+		t.Errorf("%v\n", err)
+		return err
+	}
+
+	err = ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, buf.Bytes())
+	if nil != err {
+		t.Errorf("%v\n", err)
+		return err
+	}
+	return nil
+}
+
 func (conn *tMockCosemServerConnection) replyToRequest(t *testing.T, r io.Reader) (err error) {
 	p := make([]byte, 3)
 	err = binary.Read(r, binary.BigEndian, p)
@@ -371,7 +468,7 @@ func (conn *tMockCosemServerConnection) replyToRequest(t *testing.T, r io.Reader
 				<-time.After(time.Millisecond * time.Duration(conn.srv.blockDelayMsec))
 			}
 		}
-		err = ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, buf.Bytes())
+		err = conn.sendReply(buf.Bytes())
 		if nil != err {
 			t.Errorf("%v\n", err)
 			return err
@@ -553,7 +650,7 @@ func (conn *tMockCosemServerConnection) replyToRequest(t *testing.T, r io.Reader
 			return err
 		}
 
-		err = ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, buf.Bytes())
+		err = conn.sendReply(buf.Bytes())
 		if nil != err {
 			t.Errorf("%v\n", err)
 			return err
@@ -577,7 +674,33 @@ func (conn *tMockCosemServerConnection) receiveAndReply(t *testing.T) {
 			break
 		}
 
-		if conn.srv.replyDelayMsec <= 0 {
+		if (len(pdu) > 0) && (0xE0 == pdu[0]) {
+			t.Logf("general block transfer")
+			pdu, err = conn.getGbt().receiveApdu(pdu)
+			if nil != err {
+				t.Errorf("%v\n", err)
+				conn.rwc.Close()
+				break
+			}
+		}
+
+		if nil != conn.srv.errorReply {
+			t.Logf("sending error reply")
+			err := ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, conn.srv.errorReply)
+			if nil != err {
+				t.Errorf("%v\n", err)
+				conn.rwc.Close()
+				break
+			}
+		} else if (len(pdu) > 0) && (0x62 == pdu[0]) {
+			t.Logf("RLRQ")
+			err := conn.replyToRelease(t, pdu)
+			if nil != err {
+				t.Errorf("%v\n", err)
+				conn.rwc.Close()
+				break
+			}
+		} else if conn.srv.replyDelayMsec <= 0 {
 			err := conn.replyToRequest(t, bytes.NewBuffer(pdu))
 			if nil != err {
 				t.Errorf("%v\n", err)
@@ -604,6 +727,8 @@ func (srv *tMockCosemServer) getData(t *testing.T, classId DlmsClassId, instance
 	if nil == instanceId {
 		panic("assertion failed")
 	}
+	srv.connections_mtx.Lock()
+	defer srv.connections_mtx.Unlock()
 	key := srv.objectKey(instanceId)
 	obj, ok := srv.objects[key]
 	if !ok {
@@ -628,6 +753,8 @@ func (srv *tMockCosemServer) setData(t *testing.T, classId DlmsClassId, instance
 	if nil == instanceId {
 		panic("assertion failed")
 	}
+	srv.connections_mtx.Lock()
+	defer srv.connections_mtx.Unlock()
 	key := srv.objectKey(instanceId)
 	obj, ok := srv.objects[key]
 	if !ok {
@@ -653,6 +780,8 @@ func (srv *tMockCosemServer) callMethod(t *testing.T, classId DlmsClassId, insta
 	if nil == instanceId {
 		panic("assertion failed")
 	}
+	srv.connections_mtx.Lock()
+	defer srv.connections_mtx.Unlock()
 	key := srv.objectKey(instanceId)
 	obj, ok := srv.objects[key]
 	if !ok {
@@ -674,6 +803,8 @@ func (srv *tMockCosemServer) callMethod(t *testing.T, classId DlmsClassId, insta
 }
 
 func (srv *tMockCosemServer) setAttribute(instanceId *DlmsOid, classId DlmsClassId, attributeId DlmsAttributeId, data *DlmsData) {
+	srv.connections_mtx.Lock()
+	defer srv.connections_mtx.Unlock()
 
 	key := srv.objectKey(instanceId)
 	obj := srv.objects[key]
@@ -691,6 +822,8 @@ func (srv *tMockCosemServer) setAttribute(instanceId *DlmsOid, classId DlmsClass
 }
 
 func (srv *tMockCosemServer) setMethod(instanceId *DlmsOid, classId DlmsClassId, methodId DlmsMethodId, method tMockCosemObjectMethod) {
+	srv.connections_mtx.Lock()
+	defer srv.connections_mtx.Unlock()
 
 	key := srv.objectKey(instanceId)
 	obj := srv.objects[key]
@@ -711,7 +844,145 @@ func noopMethod(obj *tMockCosemObject, methodParameters *DlmsData) (DlmsActionRe
 	return 0, nil, nil
 }
 
+// Emulated image transfer (class_id 18) object.
+type tMockImageTransfer struct {
+	blockSize   uint32
+	identifier  []byte
+	size        uint32
+	blocks      map[uint32][]byte
+	lostBlocks  map[uint32]bool // blocks discarded when received first time
+	initiations int
+}
+
+func (it *tMockImageTransfer) blocksCount() uint32 {
+	return (it.size + it.blockSize - 1) / it.blockSize
+}
+
+func (it *tMockImageTransfer) image() []byte {
+	var buf bytes.Buffer
+	for i := uint32(0); i < it.blocksCount(); i++ {
+		buf.Write(it.blocks[i])
+	}
+	return buf.Bytes()
+}
+
+func (it *tMockImageTransfer) update(obj *tMockCosemObject, status uint8) {
+	n := it.blocksCount()
+	b := make([]byte, (n+7)/8)
+	first := n
+	for i := uint32(0); i < n; i++ {
+		if _, ok := it.blocks[i]; ok {
+			b[i/8] |= 0x80 >> (i % 8)
+		} else if first == n {
+			first = i
+		}
+	}
+	obj.attributes[3] = new(DlmsData)
+	obj.attributes[3].SetBitString(b, uint16(n))
+	obj.attributes[4] = new(DlmsData)
+	obj.attributes[4].SetDoubleLongUnsigned(first)
+	obj.attributes[6] = new(DlmsData)
+	obj.attributes[6].SetEnum(status)
+}
+
+/*
+Adds image transfer object. Verification and activation complete
+immediately but temporary-failure is returned to make client poll
+image_transfer_status.
+*/
+func (srv *tMockCosemServer) setImageTransfer(instanceId *DlmsOid, blockSize uint32, lostBlocks ...uint32) *tMockImageTransfer {
+	it := new(tMockImageTransfer)
+	it.blockSize = blockSize
+	it.blocks = make(map[uint32][]byte)
+	it.lostBlocks = make(map[uint32]bool)
+	for _, blockNumber := range lostBlocks {
+		it.lostBlocks[blockNumber] = true
+	}
+
+	data := new(DlmsData)
+	data.SetDoubleLongUnsigned(blockSize)
+	srv.setAttribute(instanceId, CLASS_ID_IMAGE_TRANSFER, 2, data)
+	data = new(DlmsData)
+	data.SetBoolean(true)
+	srv.setAttribute(instanceId, CLASS_ID_IMAGE_TRANSFER, 5, data)
+	data = new(DlmsData)
+	data.SetArray(0)
+	srv.setAttribute(instanceId, CLASS_ID_IMAGE_TRANSFER, 7, data)
+	it.update(srv.objects[srv.objectKey(instanceId)], IMAGE_TRANSFER_STATUS_NOT_INITIATED)
+
+	// image_transfer_initiate
+	srv.setMethod(instanceId, CLASS_ID_IMAGE_TRANSFER, 1, func(obj *tMockCosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if (nil == methodParameters) || (2 != len(methodParameters.Arr)) {
+			return actionResult_typeUnmatched, nil, nil
+		}
+		it.identifier = methodParameters.Arr[0].GetOctetString()
+		it.size = methodParameters.Arr[1].GetDoubleLongUnsigned()
+		it.blocks = make(map[uint32][]byte)
+		it.initiations += 1
+		it.update(obj, IMAGE_TRANSFER_STATUS_INITIATED)
+		return actionResult_success, nil, nil
+	})
+
+	// image_block_transfer
+	srv.setMethod(instanceId, CLASS_ID_IMAGE_TRANSFER, 2, func(obj *tMockCosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if (nil == methodParameters) || (2 != len(methodParameters.Arr)) {
+			return actionResult_typeUnmatched, nil, nil
+		}
+		if IMAGE_TRANSFER_STATUS_INITIATED != obj.attributes[6].GetEnum() {
+			return actionResult_readWriteDenied, nil, nil
+		}
+		blockNumber := methodParameters.Arr[0].GetDoubleLongUnsigned()
+		if blockNumber >= it.blocksCount() {
+			return actionResult_otherReason, nil, nil
+		}
+		if it.lostBlocks[blockNumber] {
+			delete(it.lostBlocks, blockNumber)
+		} else {
+			it.blocks[blockNumber] = methodParameters.Arr[1].GetOctetString()
+		}
+		it.update(obj, IMAGE_TRANSFER_STATUS_INITIATED)
+		return actionResult_success, nil, nil
+	})
+
+	// image_verify
+	srv.setMethod(instanceId, CLASS_ID_IMAGE_TRANSFER, 3, func(obj *tMockCosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if uint32(len(it.blocks)) != it.blocksCount() {
+			it.update(obj, IMAGE_TRANSFER_STATUS_VERIFICATION_FAILED)
+			return actionResult_otherReason, nil, nil
+		}
+		info := new(DlmsData)
+		info.SetArray(1)
+		info.Arr[0].SetStructure(3)
+		info.Arr[0].Arr[0].SetDoubleLongUnsigned(it.size)
+		info.Arr[0].Arr[1].SetOctetString(it.identifier)
+		info.Arr[0].Arr[2].SetOctetString([]byte{})
+		obj.attributes[7] = info
+		it.update(obj, IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL)
+		return actionResult_temporaryFailure, nil, nil
+	})
+
+	// image_activate
+	srv.setMethod(instanceId, CLASS_ID_IMAGE_TRANSFER, 4, func(obj *tMockCosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if IMAGE_TRANSFER_STATUS_VERIFICATION_SUCCESSFUL != obj.attributes[6].GetEnum() {
+			return actionResult_otherReason, nil, nil
+		}
+		it.update(obj, IMAGE_TRANSFER_STATUS_ACTIVATION_SUCCESSFUL)
+		return actionResult_success, nil, nil
+	})
+
+	return it
+}
+
 func (srv *tMockCosemServer) acceptApp(t *testing.T, rwc io.ReadWriteCloser, aare []byte) (err error) {
+	err, conn := srv.newConnection(t, rwc, aare)
+	if nil != err {
+		return err
+	}
+	go conn.receiveAndReply(t)
+	return nil
+}
+
+func (srv *tMockCosemServer) newConnection(t *testing.T, rwc io.ReadWriteCloser, aare []byte) (err error, conn *tMockCosemServerConnection) {
 	t.Logf("mock server waiting for client to connect")
 
 	// receive aarq
@@ -720,7 +991,7 @@ func (srv *tMockCosemServer) acceptApp(t *testing.T, rwc io.ReadWriteCloser, aar
 		if io.EOF != err {
 			t.Errorf("%v\n", err)
 		}
-		return err
+		return err, nil
 	}
 
 	logicalDevice := dst
@@ -731,10 +1002,10 @@ func (srv *tMockCosemServer) acceptApp(t *testing.T, rwc io.ReadWriteCloser, aar
 	if nil != err {
 		t.Errorf("%v\n", err)
 		rwc.Close()
-		return err
+		return err, nil
 	}
 
-	conn := new(tMockCosemServerConnection)
+	conn = new(tMockCosemServerConnection)
 	conn.srv = srv
 	conn.rwc = rwc
 	conn.logicalDevice = logicalDevice
@@ -754,8 +1025,23 @@ func (srv *tMockCosemServer) acceptApp(t *testing.T, rwc io.ReadWriteCloser, aar
 	srv.connections.PushBack(conn)
 	mockCosemServer.connections_mtx.Unlock()
 
-	go conn.receiveAndReply(t)
-	return nil
+	return nil, conn
+}
+
+// Serves udp connection, Close() waits until it returns.
+func (srv *tMockCosemServer) serveUdp(t *testing.T, conn *tMockUdpConn, aare []byte) {
+	defer func() {
+		srv.connections_mtx.Lock()
+		srv.udpServing -= 1
+		srv.udpServingCond.Broadcast()
+		srv.connections_mtx.Unlock()
+	}()
+
+	err, sconn := srv.newConnection(t, conn, aare)
+	if nil != err {
+		return
+	}
+	sconn.receiveAndReply(t)
 }
 
 func (srv *tMockCosemServer) accept(t *testing.T, tcpAddr string, aare []byte) (err error) {
@@ -781,6 +1067,62 @@ func (srv *tMockCosemServer) accept(t *testing.T, tcpAddr string, aare []byte) (
 	return nil
 }
 
+func (srv *tMockCosemServer) acceptUdp(t *testing.T, udpAddr string, aare []byte) (err error) {
+	pc, err := net.ListenPacket("udp", udpAddr)
+	if err != nil {
+		t.Errorf("%v\n", err)
+		return err
+	}
+	srv.pc = pc
+
+	t.Logf("mock server bound to udp %s", udpAddr)
+
+	go func() {
+		p := make([]byte, udpMaxDatagramSize)
+		for {
+			n, addr, err := pc.ReadFrom(p)
+			if err != nil {
+				t.Errorf("%v\n", err)
+				return
+			}
+			datagram := append([]byte(nil), p[0:n]...)
+
+			srv.connections_mtx.Lock()
+			drop := srv.udpDropDatagrams > 0
+			if drop {
+				srv.udpDropDatagrams -= 1
+			}
+			conn, ok := srv.udpConns[addr.String()]
+			// new connection starts with AARQ, other datagrams may be late datagrams of client of previous test
+			stale := !ok && !drop && ((n <= 8) || (0x60 != datagram[8]))
+			if !ok && !drop && !stale {
+				conn = &tMockUdpConn{pc: pc, addr: addr, ch: make(chan []byte, 16), closedCh: make(chan bool)}
+				srv.udpConns[addr.String()] = conn
+				srv.udpServing += 1
+			}
+			srv.connections_mtx.Unlock()
+
+			if stale {
+				continue
+			}
+			if drop {
+				t.Logf("dropping udp datagram")
+				continue
+			}
+			if !ok {
+				go srv.serveUdp(t, conn, aare)
+			}
+			select {
+			case conn.ch <- datagram:
+			case <-conn.closedCh:
+			default:
+				t.Logf("udp connection not reading, dropping datagram")
+			}
+		}
+	}()
+	return nil
+}
+
 var mockCosemServer *tMockCosemServer
 
 func startMockCosemServer(t *testing.T, addr string, port int, aare []byte) {
@@ -790,10 +1132,16 @@ func startMockCosemServer(t *testing.T, addr string, port int, aare []byte) {
 	mockCosemServer = new(tMockCosemServer)
 	mockCosemServer.connections = list.New()
 	mockCosemServer.connections_mtx = &sync.Mutex{}
+	mockCosemServer.udpConns = make(map[string]*tMockUdpConn)
+	mockCosemServer.udpServingCond = sync.NewCond(mockCosemServer.connections_mtx)
 	err := mockCosemServer.accept(t, tcpAddr, aare)
 	if nil != err {
 		t.Fatal(err)
 	}
+	err = mockCosemServer.acceptUdp(t, tcpAddr, aare)
+	if nil != err {
+		t.Fatal(err)
+	}
 }
 
 func (srv *tMockCosemServer) Close() {
@@ -805,6 +1153,14 @@ func (srv *tMockCosemServer) Close() {
 		}
 	}
 	srv.connections = list.New()
+	for _, conn := range srv.udpConns {
+		conn.Close()
+	}
+	srv.udpConns = make(map[string]*tMockUdpConn)
+	// udp connections would otherwise keep reading objects of next test
+	for srv.udpServing > 0 {
+		srv.udpServingCond.Wait()
+	}
 	srv.connections_mtx.Unlock()
 }
 
@@ -813,12 +1169,33 @@ func (srv *tMockCosemServer) Init() {
 
 	srv.connections_mtx.Lock()
 	srv.connections = list.New()
-	srv.connections_mtx.Unlock()
 	srv.objects = make(map[string]*tMockCosemObject)
+	srv.connections_mtx.Unlock()
 	srv.blockLength = 0
 	srv.replyDelayMsec = 0
 	srv.blockDelayMsec = 0
 	srv.blockDelayLastBlock = false
+	srv.errorReply = nil
+	srv.gbtBlockLength = 0
+	srv.gbtWindowSize = 0
+	srv.gbtLostBlock = 0
+	srv.connections_mtx.Lock()
+	srv.udpDropDatagrams = 0
+	srv.connections_mtx.Unlock()
+}
+
+func (srv *tMockCosemServer) setExceptionResponse(stateError uint8, serviceError uint8) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xD8})
+	encode_ExceptionResponse(&buf, &ExceptionResponseError{StateError: stateError, ServiceError: serviceError})
+	srv.errorReply = buf.Bytes()
+}
+
+func (srv *tMockCosemServer) setConfirmedServiceError(service uint8, class uint8, value uint8) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x0E})
+	encode_ConfirmedServiceError(&buf, &ConfirmedServiceError{Service: service, Class: class, Value: value})
+	srv.errorReply = buf.Bytes()
 }
 
 const c_TEST_ADDR = "localhost"
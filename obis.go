@@ -0,0 +1,255 @@
+package gocosem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Inclusive range of values of one OBIS group.
+type ObisRange struct {
+	Min uint8
+	Max uint8
+}
+
+// OBIS code pattern, each of groups A to F matches range of values.
+type ObisPattern [6]ObisRange
+
+type ObisInfo struct {
+	Pattern ObisPattern
+	Name    string
+	ClassId DlmsClassId // expected class id
+	Unit    uint8       // unit enumeration as in scaler_unit, 0 if not applicable
+}
+
+var obisRegistryMtx sync.RWMutex
+var obisRegistry []*ObisInfo
+
+// Well-known OBIS codes.
+var obisWellKnown = []struct {
+	pattern string
+	name    string
+	classId DlmsClassId
+	unit    uint8
+}{
+	{"0-0:1.0.0.255", "clock", CLASS_ID_CLOCK, 0},
+	{"0-0:40.0.0.255", "current association", CLASS_ID_ASSOCIATION_LN, 0},
+	{"0-0:40.0.[1-255].255", "association", CLASS_ID_ASSOCIATION_LN, 0},
+	{"0-0:41.0.0.255", "SAP assignment", 17, 0},
+	{"0-0:42.0.0.255", "COSEM logical device name", CLASS_ID_DATA, 0},
+	{"0-0:43.0.*.255", "security setup", 64, 0},
+	{"0-0:44.0.0.255", "image transfer", CLASS_ID_IMAGE_TRANSFER, 0},
+	{"0-0:13.0.0.255", "activity calendar", 20, 0},
+	{"0-*:25.9.0.255", "push setup", 40, 0},
+	{"0-0:96.1.0.255", "meter serial number", CLASS_ID_DATA, 0},
+	{"0-*:96.3.10.255", "disconnect control", CLASS_ID_DISCONNECT_CONTROL, 0},
+	{"0-0:99.98.*.255", "event log", CLASS_ID_PROFILE_GENERIC, 0},
+	{"1-0:99.1.0.255", "load profile 1", CLASS_ID_PROFILE_GENERIC, 0},
+	{"1-0:99.2.0.255", "load profile 2", CLASS_ID_PROFILE_GENERIC, 0},
+	{"1-*:1.7.0.255", "active power import", CLASS_ID_REGISTER, 27},
+	{"1-*:2.7.0.255", "active power export", CLASS_ID_REGISTER, 27},
+	{"1-*:1.8.*.255", "active energy import", CLASS_ID_REGISTER, 30},
+	{"1-*:2.8.*.255", "active energy export", CLASS_ID_REGISTER, 30},
+	{"1-*:3.8.*.255", "reactive energy import", CLASS_ID_REGISTER, 32},
+	{"1-*:4.8.*.255", "reactive energy export", CLASS_ID_REGISTER, 32},
+	{"1-*:14.7.0.255", "supply frequency", CLASS_ID_REGISTER, 44},
+	{"1-*:31.7.0.255", "current L1", CLASS_ID_REGISTER, 33},
+	{"1-*:51.7.0.255", "current L2", CLASS_ID_REGISTER, 33},
+	{"1-*:71.7.0.255", "current L3", CLASS_ID_REGISTER, 33},
+	{"1-*:32.7.0.255", "voltage L1", CLASS_ID_REGISTER, 35},
+	{"1-*:52.7.0.255", "voltage L2", CLASS_ID_REGISTER, 35},
+	{"1-*:72.7.0.255", "voltage L3", CLASS_ID_REGISTER, 35},
+}
+
+func init() {
+	for _, wk := range obisWellKnown {
+		err := RegisterOBIS(wk.pattern, wk.name, wk.classId, wk.unit)
+		if nil != err {
+			panic(err)
+		}
+	}
+}
+
+// Splits OBIS code into groups. Accepts both 'A-B:C.D.E*F' and 'A.B.C.D.E.F' notation.
+func splitObis(s string) (groups []string, err error) {
+	groups = make([]string, 0, 6)
+	var token []byte
+	bracket := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '[' == c:
+			bracket = true
+			token = append(token, c)
+		case ']' == c:
+			bracket = false
+			token = append(token, c)
+		case bracket:
+			token = append(token, c)
+		case ('*' == c) && (0 == len(token)):
+			// wildcard group
+			token = append(token, c)
+		case ('-' == c) || (':' == c) || ('.' == c) || ('*' == c) || ('&' == c):
+			groups = append(groups, string(token))
+			token = nil
+		default:
+			token = append(token, c)
+		}
+	}
+	groups = append(groups, string(token))
+	if 6 != len(groups) {
+		err = fmt.Errorf("invalid OBIS code: '%s'", s)
+		errorLog("%s", err)
+		return nil, err
+	}
+	return groups, nil
+}
+
+func parseObisGroup(s string) (value uint8, err error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if nil != err {
+		err = fmt.Errorf("invalid OBIS group value: '%s'", s)
+		errorLog("%s", err)
+		return 0, err
+	}
+	return uint8(v), nil
+}
+
+func ParseOBIS(s string) (oid *DlmsOid, err error) {
+	groups, err := splitObis(s)
+	if nil != err {
+		return nil, err
+	}
+	oid = new(DlmsOid)
+	for i, group := range groups {
+		oid[i], err = parseObisGroup(group)
+		if nil != err {
+			return nil, err
+		}
+	}
+	return oid, nil
+}
+
+// Like ParseOBIS() but panics on error. Intended for constant OBIS codes.
+func MustParseOBIS(s string) *DlmsOid {
+	oid, err := ParseOBIS(s)
+	if nil != err {
+		panic(err)
+	}
+	return oid
+}
+
+// Formats OBIS code in 'A-B:C.D.E*F' notation.
+func (oid DlmsOid) String() string {
+	return fmt.Sprintf("%d-%d:%d.%d.%d*%d", oid[0], oid[1], oid[2], oid[3], oid[4], oid[5])
+}
+
+// Parses OBIS pattern. Each group may be a value, '*' for any value or '[min-max]' for range of values.
+func ParseOBISPattern(s string) (pattern *ObisPattern, err error) {
+	groups, err := splitObis(s)
+	if nil != err {
+		return nil, err
+	}
+	pattern = new(ObisPattern)
+	for i, group := range groups {
+		switch {
+		case "*" == group:
+			pattern[i] = ObisRange{0, 255}
+		case strings.HasPrefix(group, "[") && strings.HasSuffix(group, "]"):
+			bounds := strings.Split(group[1:len(group)-1], "-")
+			if 2 != len(bounds) {
+				err = fmt.Errorf("invalid OBIS range: '%s'", group)
+				errorLog("%s", err)
+				return nil, err
+			}
+			pattern[i].Min, err = parseObisGroup(bounds[0])
+			if nil != err {
+				return nil, err
+			}
+			pattern[i].Max, err = parseObisGroup(bounds[1])
+			if nil != err {
+				return nil, err
+			}
+			if pattern[i].Min > pattern[i].Max {
+				err = fmt.Errorf("invalid OBIS range: '%s'", group)
+				errorLog("%s", err)
+				return nil, err
+			}
+		default:
+			value, err := parseObisGroup(group)
+			if nil != err {
+				return nil, err
+			}
+			pattern[i] = ObisRange{value, value}
+		}
+	}
+	return pattern, nil
+}
+
+func (pattern *ObisPattern) Match(oid *DlmsOid) bool {
+	for i, r := range pattern {
+		if (oid[i] < r.Min) || (oid[i] > r.Max) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pattern ObisPattern) String() string {
+	groups := make([]interface{}, 6)
+	for i, r := range pattern {
+		switch {
+		case (0 == r.Min) && (255 == r.Max):
+			groups[i] = "*"
+		case r.Min == r.Max:
+			groups[i] = fmt.Sprintf("%d", r.Min)
+		default:
+			groups[i] = fmt.Sprintf("[%d-%d]", r.Min, r.Max)
+		}
+	}
+	return fmt.Sprintf("%s-%s:%s.%s.%s*%s", groups...)
+}
+
+// Number of OBIS codes matched by pattern, used to prefer more specific registry entries.
+func (pattern *ObisPattern) width() (w uint64) {
+	w = 1
+	for _, r := range pattern {
+		w *= uint64(r.Max) - uint64(r.Min) + 1
+	}
+	return w
+}
+
+// Adds OBIS code or pattern to registry of known objects.
+func RegisterOBIS(pattern string, name string, classId DlmsClassId, unit uint8) (err error) {
+	p, err := ParseOBISPattern(pattern)
+	if nil != err {
+		return err
+	}
+	obisRegistryMtx.Lock()
+	obisRegistry = append(obisRegistry, &ObisInfo{Pattern: *p, Name: name, ClassId: classId, Unit: unit})
+	obisRegistryMtx.Unlock()
+	return nil
+}
+
+// Returns most specific registry entry matching OBIS code or nil if there is none. Entries registered later take precedence.
+func LookupOBIS(oid *DlmsOid) (info *ObisInfo) {
+	obisRegistryMtx.RLock()
+	defer obisRegistryMtx.RUnlock()
+	for i := len(obisRegistry) - 1; i >= 0; i-- {
+		_info := obisRegistry[i]
+		if _info.Pattern.Match(oid) && ((nil == info) || (_info.Pattern.width() < info.Pattern.width())) {
+			info = _info
+		}
+	}
+	return info
+}
+
+// OBIS code followed by registered name if known.
+func (oid *DlmsOid) Describe() string {
+	info := LookupOBIS(oid)
+	if nil == info {
+		return oid.String()
+	}
+	return fmt.Sprintf("%s (%s)", oid.String(), info.Name)
+}
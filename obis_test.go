@@ -0,0 +1,88 @@
+package gocosem
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestObis_ParseOBIS(t *testing.T) {
+	expected := DlmsOid{1, 0, 99, 1, 0, 255}
+	for _, s := range []string{"1-0:99.1.0.255", "1-0:99.1.0*255", "1.0.99.1.0.255", "1-0:99.1.0&255"} {
+		oid, err := ParseOBIS(s)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if expected != *oid {
+			t.Fatalf("%s: parsed as %s", s, oid)
+		}
+	}
+	for _, s := range []string{"", "1-0:99.1.0", "1-0:99.1.0.255.1", "1-0:99.1.0.256", "1-0:x.1.0.255", "1-0:*.1.0.255"} {
+		_, err := ParseOBIS(s)
+		if nil == err {
+			t.Fatalf("%s: parsed", s)
+		}
+	}
+}
+
+func TestObis_String(t *testing.T) {
+	oid := DlmsOid{0, 0, 1, 0, 0, 255}
+	if "0-0:1.0.0*255" != oid.String() {
+		t.Fatalf("%s", oid.String())
+	}
+	if *MustParseOBIS(oid.String()) != oid {
+		t.Fatalf("round trip failed")
+	}
+}
+
+func TestObis_PatternMatch(t *testing.T) {
+	pattern, err := ParseOBISPattern("1-*:1.8.[0-4].255")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if "1-*:1.8.[0-4]*255" != pattern.String() {
+		t.Fatalf("%s", pattern.String())
+	}
+	if !pattern.Match(&DlmsOid{1, 0, 1, 8, 0, 255}) || !pattern.Match(&DlmsOid{1, 3, 1, 8, 4, 255}) {
+		t.Fatalf("pattern does not match")
+	}
+	if pattern.Match(&DlmsOid{1, 0, 1, 8, 5, 255}) || pattern.Match(&DlmsOid{1, 0, 2, 8, 0, 255}) {
+		t.Fatalf("pattern matches")
+	}
+	_, err = ParseOBISPattern("1-*:1.8.[4-0].255")
+	if nil == err {
+		t.Fatalf("invalid range parsed")
+	}
+}
+
+func TestObis_Registry(t *testing.T) {
+	info := LookupOBIS(&ClockInstanceId)
+	if (nil == info) || (CLASS_ID_CLOCK != info.ClassId) {
+		t.Fatalf("clock not found")
+	}
+	info = LookupOBIS(MustParseOBIS("0-0:40.0.0.255"))
+	if (nil == info) || ("current association" != info.Name) {
+		t.Fatalf("current association not found")
+	}
+	info = LookupOBIS(MustParseOBIS("1-0:1.8.0.255"))
+	if (nil == info) || (CLASS_ID_REGISTER != info.ClassId) || (30 != info.Unit) {
+		t.Fatalf("active energy import not found")
+	}
+	if nil != LookupOBIS(MustParseOBIS("1-0:1.8.0.254")) {
+		t.Fatalf("unexpected match")
+	}
+
+	err := RegisterOBIS("1-0:1.8.1.255", "active energy import tariff 1", CLASS_ID_REGISTER, 30)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	info = LookupOBIS(MustParseOBIS("1-0:1.8.1.255"))
+	if (nil == info) || ("active energy import tariff 1" != info.Name) {
+		t.Fatalf("more specific entry not preferred")
+	}
+
+	data := new(DlmsData)
+	data.SetOctetString(ClockInstanceId[:])
+	if !strings.Contains(data.Print(), "0-0:1.0.0*255 clock") {
+		t.Fatalf("%s", data.Print())
+	}
+}
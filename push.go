@@ -0,0 +1,505 @@
+package gocosem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	pduTagDataNotification         = 0x0F
+	pduTagEventNotificationRequest = 0xC2
+	pduTagGeneralGloCiphering      = 0xDB
+	pduTagGeneralDedCiphering      = 0xDC
+)
+
+type DlmsDataNotification struct {
+	LongInvokeIdAndPriority uint32
+	DateTime                *DlmsDateTime // nil if not present
+	Body                    *DlmsData
+}
+
+type DlmsEventNotification struct {
+	Time        *DlmsDateTime // nil if not present
+	ClassId     DlmsClassId
+	InstanceId  DlmsOid
+	AttributeId DlmsAttributeId
+	Value       *DlmsData
+}
+
+// Push received from meter.
+type DlmsPush struct {
+	Src               uint16                 // wrapper port or logical device id of sending meter
+	Dst               uint16                 // wrapper port or hdlc address of client push was sent to
+	SystemTitle       []byte                 // system title of sender if push was ciphered
+	DataNotification  *DlmsDataNotification  // set if push is DataNotification
+	EventNotification *DlmsEventNotification // set if push is EventNotificationRequest
+}
+
+type PushHandler interface {
+	HandlePush(push *DlmsPush)
+}
+
+// Adapter allowing use of ordinary function as PushHandler.
+type PushHandlerFunc func(push *DlmsPush)
+
+func (f PushHandlerFunc) HandlePush(push *DlmsPush) {
+	f(push)
+}
+
+func encodeDateTimeOctetString(w io.Writer, dateTime *DlmsDateTime) (err error) {
+	if nil == dateTime {
+		_, err = w.Write([]byte{0x00})
+	} else {
+		_, err = w.Write(append([]byte{0x0C}, dateTime.ToBytes()...))
+	}
+	if nil != err {
+		errorLog("w.Write() failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Decodes date-time encoded as octet string, it is absent if octet string is empty.
+func decodeDateTimeOctetString(r io.Reader) (err error, dateTime *DlmsDateTime) {
+	err, length := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil
+	}
+	if 0 == length {
+		return nil, nil
+	}
+	if 12 != length {
+		err = fmt.Errorf("wrong date-time length: %d", length)
+		errorLog("%s", err)
+		return err, nil
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(r, b)
+	if nil != err {
+		errorLog("io.ReadFull() failed: %v", err)
+		return err, nil
+	}
+	return nil, DlmsDateTimeFromBytes(b)
+}
+
+func encode_DataNotification(w io.Writer, notification *DlmsDataNotification) (err error) {
+	err = binary.Write(w, binary.BigEndian, notification.LongInvokeIdAndPriority)
+	if nil != err {
+		errorLog("binary.Write() failed: %v", err)
+		return err
+	}
+	err = encodeDateTimeOctetString(w, notification.DateTime)
+	if nil != err {
+		return err
+	}
+	return notification.Body.Encode(w)
+}
+
+func decode_DataNotification(r io.Reader) (err error, notification *DlmsDataNotification) {
+	notification = new(DlmsDataNotification)
+	err = binary.Read(r, binary.BigEndian, &notification.LongInvokeIdAndPriority)
+	if nil != err {
+		errorLog("binary.Read() failed: %v", err)
+		return err, nil
+	}
+	err, notification.DateTime = decodeDateTimeOctetString(r)
+	if nil != err {
+		return err, nil
+	}
+	notification.Body = new(DlmsData)
+	err = notification.Body.Decode(r)
+	if nil != err {
+		return err, nil
+	}
+	return nil, notification
+}
+
+func encode_EventNotificationRequest(w io.Writer, notification *DlmsEventNotification) (err error) {
+	// time OCTET STRING OPTIONAL
+	if nil == notification.Time {
+		_, err = w.Write([]byte{0x00})
+	} else {
+		_, err = w.Write([]byte{0x01})
+		if nil == err {
+			err = encodeDateTimeOctetString(w, notification.Time)
+		}
+	}
+	if nil != err {
+		errorLog("w.Write() failed: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, notification.ClassId)
+	if nil != err {
+		errorLog("binary.Write() failed: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, notification.InstanceId)
+	if nil != err {
+		errorLog("binary.Write() failed: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, notification.AttributeId)
+	if nil != err {
+		errorLog("binary.Write() failed: %v", err)
+		return err
+	}
+	return notification.Value.Encode(w)
+}
+
+func decode_EventNotificationRequest(r io.Reader) (err error, notification *DlmsEventNotification) {
+	notification = new(DlmsEventNotification)
+
+	var timePresent uint8
+	err = binary.Read(r, binary.BigEndian, &timePresent)
+	if nil != err {
+		errorLog("binary.Read() failed: %v", err)
+		return err, nil
+	}
+	if 0 != timePresent {
+		err, notification.Time = decodeDateTimeOctetString(r)
+		if nil != err {
+			return err, nil
+		}
+	}
+	err = binary.Read(r, binary.BigEndian, &notification.ClassId)
+	if nil != err {
+		errorLog("binary.Read() failed: %v", err)
+		return err, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &notification.InstanceId)
+	if nil != err {
+		errorLog("binary.Read() failed: %v", err)
+		return err, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &notification.AttributeId)
+	if nil != err {
+		errorLog("binary.Read() failed: %v", err)
+		return err, nil
+	}
+	notification.Value = new(DlmsData)
+	err = notification.Value.Decode(r)
+	if nil != err {
+		return err, nil
+	}
+	return nil, notification
+}
+
+func decodeAxdrOctetString(r io.Reader) (err error, b []byte) {
+	err, length := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil
+	}
+	b = make([]byte, length)
+	_, err = io.ReadFull(r, b)
+	if nil != err {
+		errorLog("io.ReadFull() failed: %v", err)
+		return err, nil
+	}
+	return nil, b
+}
+
+type tPushKeys struct {
+	globalKey         []byte
+	dedicatedKey      []byte
+	authenticationKey []byte
+}
+
+/*
+Listener accepting pushes (DataNotification and EventNotificationRequest)
+sent by meters over DLMS wrapper (TCP and UDP) or in HDLC UI frames and
+passing them to handler. Pushes protected with general-glo-ciphering or
+general-ded-ciphering are decrypted using keys set for sender system title by
+SetKeys(), pushes which cannot be decoded or decrypted are dropped.
+*/
+type PushListener struct {
+	handler PushHandler
+
+	keys    map[string]*tPushKeys // by hex encoded system title
+	keysMtx sync.RWMutex
+
+	mtx     sync.Mutex
+	closed  bool
+	closers map[io.Closer]bool // listeners and connections closed by Close()
+}
+
+func NewPushListener(handler PushHandler) *PushListener {
+	pl := new(PushListener)
+	pl.handler = handler
+	pl.keys = make(map[string]*tPushKeys)
+	pl.closers = make(map[io.Closer]bool)
+	return pl
+}
+
+// Sets keys of meter with 'systemTitle'. Dedicated key is needed only if meter uses general-ded-ciphering.
+func (pl *PushListener) SetKeys(systemTitle []byte, globalKey []byte, dedicatedKey []byte, authenticationKey []byte) {
+	pl.keysMtx.Lock()
+	defer pl.keysMtx.Unlock()
+	pl.keys[hex.EncodeToString(systemTitle)] = &tPushKeys{globalKey: globalKey, dedicatedKey: dedicatedKey, authenticationKey: authenticationKey}
+}
+
+func (pl *PushListener) decipher(pdu []byte) (err error, systemTitle []byte, dpdu []byte) {
+	r := bytes.NewReader(pdu[1:])
+	err, systemTitle = decodeAxdrOctetString(r)
+	if nil != err {
+		return err, nil, nil
+	}
+	err, content := decodeAxdrOctetString(r)
+	if nil != err {
+		return err, nil, nil
+	}
+
+	pl.keysMtx.RLock()
+	keys := pl.keys[hex.EncodeToString(systemTitle)]
+	pl.keysMtx.RUnlock()
+	if nil == keys {
+		err = fmt.Errorf("no keys for system title: % 02X", systemTitle)
+		errorLog("%s", err)
+		return err, nil, nil
+	}
+	key := keys.globalKey
+	if pduTagGeneralDedCiphering == pdu[0] {
+		key = keys.dedicatedKey
+	}
+	if nil == key {
+		err = fmt.Errorf("no encryption key for system title: % 02X", systemTitle)
+		errorLog("%s", err)
+		return err, nil, nil
+	}
+	err, dpdu = decryptGSM(key, keys.authenticationKey, systemTitle, content)
+	if nil != err {
+		return err, nil, nil
+	}
+	return nil, systemTitle, dpdu
+}
+
+func (pl *PushListener) decodePush(pdu []byte) (err error, push *DlmsPush) {
+	var systemTitle []byte
+	if (len(pdu) > 0) && ((pduTagGeneralGloCiphering == pdu[0]) || (pduTagGeneralDedCiphering == pdu[0])) {
+		err, systemTitle, pdu = pl.decipher(pdu)
+		if nil != err {
+			return err, nil
+		}
+	}
+	err, push = decodePushPdu(pdu)
+	if nil != err {
+		return err, nil
+	}
+	push.SystemTitle = systemTitle
+	return nil, push
+}
+
+// Decodes unciphered DataNotification or EventNotificationRequest.
+func decodePushPdu(pdu []byte) (err error, push *DlmsPush) {
+	if 0 == len(pdu) {
+		err = fmt.Errorf("empty push pdu")
+		errorLog("%s", err)
+		return err, nil
+	}
+	push = new(DlmsPush)
+
+	r := bytes.NewReader(pdu[1:])
+	switch pdu[0] {
+	case pduTagDataNotification:
+		err, push.DataNotification = decode_DataNotification(r)
+	case pduTagEventNotificationRequest:
+		err, push.EventNotification = decode_EventNotificationRequest(r)
+	default:
+		err = fmt.Errorf("not a push pdu, tag: 0x%02X", pdu[0])
+		errorLog("%s", err)
+	}
+	if nil != err {
+		return err, nil
+	}
+	return nil, push
+}
+
+func (pl *PushListener) handlePdu(src uint16, dst uint16, pdu []byte) {
+	debugLog("received push: src: %d, dst: %d: % 02X", src, dst, pdu)
+	err, push := pl.decodePush(pdu)
+	if nil != err {
+		warnLog("dropping push: %v", err)
+		return
+	}
+	push.Src = src
+	push.Dst = dst
+	pl.handler.HandlePush(push)
+}
+
+// Registers 'c' to be closed by Close(), returns false if listener is already closed.
+func (pl *PushListener) track(c io.Closer) bool {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+	if pl.closed {
+		c.Close()
+		return false
+	}
+	pl.closers[c] = true
+	return true
+}
+
+func (pl *PushListener) untrack(c io.Closer) {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+	delete(pl.closers, c)
+}
+
+func (pl *PushListener) isClosed() bool {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+	return pl.closed
+}
+
+func (pl *PushListener) acceptLoop(ln net.Listener, serve func(conn net.Conn) error) {
+	for {
+		conn, err := ln.Accept()
+		if nil != err {
+			if !pl.isClosed() {
+				errorLog("ln.Accept() failed: %v", err)
+			}
+			return
+		}
+		go serve(conn)
+	}
+}
+
+// Accepts DLMS wrapper connections on 'addr' and serves them in background until listener is closed.
+func (pl *PushListener) ListenTcp(addr string) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		errorLog("net.Listen() failed: %v", err)
+		return nil, err
+	}
+	if !pl.track(ln) {
+		return nil, ErrServerClosed
+	}
+	go pl.acceptLoop(ln, func(conn net.Conn) error {
+		return pl.ServeTcp(conn)
+	})
+	return ln, nil
+}
+
+// Accepts HDLC over TCP connections on 'addr' and serves them in background until listener is closed.
+func (pl *PushListener) ListenHdlc(addr string, serverAddressLength int) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		errorLog("net.Listen() failed: %v", err)
+		return nil, err
+	}
+	if !pl.track(ln) {
+		return nil, ErrServerClosed
+	}
+	go pl.acceptLoop(ln, func(conn net.Conn) error {
+		return pl.ServeHdlc(conn, serverAddressLength)
+	})
+	return ln, nil
+}
+
+// Receives DLMS wrapper datagrams on 'addr' in background until listener is closed.
+func (pl *PushListener) ListenUdp(addr string) (pc net.PacketConn, err error) {
+	pc, err = net.ListenPacket("udp", addr)
+	if nil != err {
+		errorLog("net.ListenPacket() failed: %v", err)
+		return nil, err
+	}
+	if !pl.track(pc) {
+		return nil, ErrServerClosed
+	}
+	go func() {
+		p := make([]byte, udpMaxDatagramSize)
+		for {
+			n, _, err := pc.ReadFrom(p)
+			if nil != err {
+				if !pl.isClosed() {
+					errorLog("pc.ReadFrom() failed: %v", err)
+				}
+				return
+			}
+			pdu, src, dst, err := ipTransportReceive(bytes.NewReader(p[0:n]), nil, nil)
+			if nil != err {
+				warnLog("dropping malformed datagram")
+				continue
+			}
+			pl.handlePdu(src, dst, pdu)
+		}
+	}()
+	return pc, nil
+}
+
+// Receives pushes on single DLMS wrapper connection. Returns after peer closed the connection or listener was closed.
+func (pl *PushListener) ServeTcp(rwc io.ReadWriteCloser) (err error) {
+	if !pl.track(rwc) {
+		return ErrServerClosed
+	}
+	defer pl.untrack(rwc)
+	defer rwc.Close()
+
+	for {
+		pdu, src, dst, err := ipTransportReceive(rwc, nil, nil)
+		if nil != err {
+			if (io.EOF == err) || pl.isClosed() {
+				return nil
+			}
+			return err
+		}
+		pl.handlePdu(src, dst, pdu)
+	}
+}
+
+/*
+Receives pushes sent in HDLC UI frames on 'rwc' (e.g. serial port or TCP
+connection), other frames are ignored. Server address length is as in
+HdlcConnect(). Returns after peer closed the connection or listener was
+closed.
+*/
+func (pl *PushListener) ServeHdlc(rwc io.ReadWriteCloser, serverAddressLength int) (err error) {
+	if !pl.track(rwc) {
+		return ErrServerClosed
+	}
+	defer pl.untrack(rwc)
+	defer rwc.Close()
+
+	htran := newHdlcFramer(rwc, true, 0, 0, nil, serverAddressLength)
+	var segments bytes.Buffer
+	for {
+		err, frame := htran.readFrame(HDLC_FRAME_DIRECTION_CLIENT_INBOUND)
+		if nil != err {
+			if (io.EOF == err) || pl.isClosed() {
+				return nil
+			}
+			return err
+		}
+		if HDLC_CONTROL_UI != frame.control {
+			debugLog("ignoring non UI frame")
+			continue
+		}
+		segments.Write(frame.infoField)
+		if frame.segmentation {
+			continue
+		}
+		p := append([]byte(nil), segments.Bytes()...)
+		segments.Reset()
+
+		if (len(p) < len(llcHeaderResponse)) || !bytes.Equal(p[0:len(llcHeaderResponse)], llcHeaderResponse) {
+			warnLog("dropping UI frame: wrong LLC header")
+			continue
+		}
+		pl.handlePdu(frame.logicalDeviceId, uint16(frame.clientId), p[len(llcHeaderResponse):])
+	}
+}
+
+// Closes all listeners and connections.
+func (pl *PushListener) Close() (err error) {
+	pl.mtx.Lock()
+	defer pl.mtx.Unlock()
+	pl.closed = true
+	for c := range pl.closers {
+		c.Close()
+	}
+	pl.closers = make(map[io.Closer]bool)
+	return nil
+}
@@ -0,0 +1,283 @@
+package gocosem
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+var testPushSystemTitle = []byte{0x4D, 0x4D, 0x4D, 0x00, 0x00, 0xBC, 0x61, 0x4E}
+var testPushGlobalKey = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+var testPushAuthenticationKey = []byte{0xD0, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, 0xD7, 0xD8, 0xD9, 0xDA, 0xDB, 0xDC, 0xDD, 0xDE, 0xDF}
+
+func newTestDataNotification(t *testing.T) (pdu []byte, notification *DlmsDataNotification) {
+	body := new(DlmsData)
+	body.SetOctetString([]byte{0x01, 0x02, 0x03})
+	notification = &DlmsDataNotification{
+		LongInvokeIdAndPriority: 0x00000001,
+		DateTime:                DlmsDateTimeFromBytes([]byte{0x07, 0xE5, 0x01, 0x01, 0x05, 0x0A, 0x00, 0x00, 0x00, 0xFF, 0xC4, 0x00}),
+		Body:                    body,
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(pduTagDataNotification)
+	err := encode_DataNotification(&buf, notification)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	return buf.Bytes(), notification
+}
+
+func newTestEventNotification(t *testing.T) (pdu []byte) {
+	value := new(DlmsData)
+	value.SetDoubleLongUnsigned(0x00000100)
+	var buf bytes.Buffer
+	buf.WriteByte(pduTagEventNotificationRequest)
+	err := encode_EventNotificationRequest(&buf, &DlmsEventNotification{
+		ClassId:     1,
+		InstanceId:  DlmsOid{0x00, 0x00, 0x61, 0x62, 0x00, 0xFF},
+		AttributeId: 2,
+		Value:       value,
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	return buf.Bytes()
+}
+
+// Protects 'pdu' with general-glo-ciphering.
+func generalGloCipher(t *testing.T, systemTitle []byte, key []byte, authenticationKey []byte, frameCounter uint32, pdu []byte) []byte {
+	SC := byte(0x30)
+	FC := []byte{byte(frameCounter >> 24), byte(frameCounter >> 16), byte(frameCounter >> 8), byte(frameCounter)}
+	IV := append(append([]byte(nil), systemTitle...), FC...)
+	AAD := append([]byte{SC}, authenticationKey...)
+	err, ciphertext, authTag := aesgcm(key, IV, AAD, pdu, 0)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	content := append(append(append([]byte{SC}, FC...), ciphertext...), authTag...)
+
+	var buf bytes.Buffer
+	buf.WriteByte(pduTagGeneralGloCiphering)
+	encodeAxdrLength(&buf, uint16(len(systemTitle)))
+	buf.Write(systemTitle)
+	encodeAxdrLength(&buf, uint16(len(content)))
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func receivePush(t *testing.T, ch chan *DlmsPush) *DlmsPush {
+	select {
+	case push := <-ch:
+		return push
+	case <-time.After(time.Second * 5):
+		t.Fatalf("push not received")
+	}
+	return nil
+}
+
+func TestPush_decode_DataNotification(t *testing.T) {
+	pdu := []byte{0x0F, 0x00, 0x00, 0x00, 0x01, 0x0C, 0x07, 0xE5, 0x01, 0x01, 0x05, 0x0A, 0x00, 0x00, 0x00, 0xFF, 0xC4, 0x00, 0x09, 0x03, 0x01, 0x02, 0x03}
+	encoded, _ := newTestDataNotification(t)
+	if !bytes.Equal(pdu, encoded) {
+		t.Fatalf("encoding differs: % 02X", encoded)
+	}
+
+	err, notification := decode_DataNotification(bytes.NewReader(pdu[1:]))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 1 != notification.LongInvokeIdAndPriority {
+		t.Fatalf("long invoke id: %d", notification.LongInvokeIdAndPriority)
+	}
+	if (nil == notification.DateTime) || (2021 != notification.DateTime.Year) || (10 != notification.DateTime.Hour) {
+		t.Fatalf("wrong date-time")
+	}
+	if !bytes.Equal([]byte{0x01, 0x02, 0x03}, notification.Body.GetOctetString()) {
+		t.Fatalf("body differs")
+	}
+
+	// date-time not present
+	err, notification = decode_DataNotification(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x11, 0x05}))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (nil != notification.DateTime) || (5 != notification.Body.GetUnsigned()) {
+		t.Fatalf("wrong notification")
+	}
+}
+
+func TestPush_ListenTcp(t *testing.T) {
+	ch := make(chan *DlmsPush, 10)
+	pl := NewPushListener(PushHandlerFunc(func(push *DlmsPush) {
+		ch <- push
+	}))
+	defer pl.Close()
+	ln, err := pl.ListenTcp("localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer conn.Close()
+
+	pdu, _ := newTestDataNotification(t)
+	err = ipTransportSend(conn, 1, 0x66, pdu)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	err = ipTransportSend(conn, 1, 0x66, newTestEventNotification(t))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	push := receivePush(t, ch)
+	if (1 != push.Src) || (0x66 != push.Dst) || (nil == push.DataNotification) || (nil != push.SystemTitle) {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	push = receivePush(t, ch)
+	if nil == push.EventNotification {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	ev := push.EventNotification
+	if (nil != ev.Time) || (1 != ev.ClassId) || (0x61 != ev.InstanceId[2]) || (2 != ev.AttributeId) || (0x100 != ev.Value.GetDoubleLongUnsigned()) {
+		t.Fatalf("unexpected event notification: %+v", ev)
+	}
+}
+
+func TestPush_ListenUdp_ciphered(t *testing.T) {
+	ch := make(chan *DlmsPush, 10)
+	pl := NewPushListener(PushHandlerFunc(func(push *DlmsPush) {
+		ch <- push
+	}))
+	defer pl.Close()
+	pl.SetKeys(testPushSystemTitle, testPushGlobalKey, nil, testPushAuthenticationKey)
+	pc, err := pl.ListenUdp("localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer conn.Close()
+
+	pdu, notification := newTestDataNotification(t)
+
+	// unknown system title and wrong key are dropped
+	unknownSystemTitle := []byte{0x4D, 0x4D, 0x4D, 0x00, 0x00, 0x00, 0x00, 0x01}
+	err = ipTransportSend(conn, 1, 0x66, generalGloCipher(t, unknownSystemTitle, testPushGlobalKey, testPushAuthenticationKey, 1, pdu))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	err = ipTransportSend(conn, 1, 0x66, generalGloCipher(t, testPushSystemTitle, testPushAuthenticationKey, testPushAuthenticationKey, 2, pdu))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	err = ipTransportSend(conn, 1, 0x66, generalGloCipher(t, testPushSystemTitle, testPushGlobalKey, testPushAuthenticationKey, 3, pdu))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	push := receivePush(t, ch)
+	if !bytes.Equal(testPushSystemTitle, push.SystemTitle) {
+		t.Fatalf("system title differs")
+	}
+	if (nil == push.DataNotification) || !bytes.Equal(notification.Body.GetOctetString(), push.DataNotification.Body.GetOctetString()) {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	select {
+	case push = <-ch:
+		t.Fatalf("unexpected push: %+v", push)
+	default:
+	}
+}
+
+func TestPush_ServeHdlc(t *testing.T) {
+	ch := make(chan *DlmsPush, 10)
+	pl := NewPushListener(PushHandlerFunc(func(push *DlmsPush) {
+		ch <- push
+	}))
+	defer pl.Close()
+
+	meter, listener := net.Pipe()
+	defer meter.Close()
+	go pl.ServeHdlc(listener, HDLC_ADDRESS_LENGTH_1)
+
+	pdu := append(append([]byte(nil), llcHeaderResponse...), newTestEventNotification(t)...)
+	htran := newHdlcFramer(meter, false, 0x66, 1, nil, HDLC_ADDRESS_LENGTH_1)
+
+	// frames other than UI are ignored
+	err := htran.writeFrame(&HdlcFrame{direction: HDLC_FRAME_DIRECTION_SERVER_OUTBOUND, control: HDLC_CONTROL_RR, poll: true})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	// push split into two segments
+	err = htran.writeFrame(&HdlcFrame{direction: HDLC_FRAME_DIRECTION_SERVER_OUTBOUND, control: HDLC_CONTROL_UI, segmentation: true, infoField: pdu[0:10]})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	err = htran.writeFrame(&HdlcFrame{direction: HDLC_FRAME_DIRECTION_SERVER_OUTBOUND, control: HDLC_CONTROL_UI, infoField: pdu[10:]})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	push := receivePush(t, ch)
+	if (1 != push.Src) || (0x66 != push.Dst) || (nil == push.EventNotification) {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	if 0x100 != push.EventNotification.Value.GetDoubleLongUnsigned() {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestPush_ReceivePush_hdlc(t *testing.T) {
+	hdlcTestInit(t)
+
+	crw, srw := createHdlcPipe(t)
+	defer srw.Close()
+
+	server := NewHdlcTransport(srw, time.Duration(100)*time.Millisecond, false, 1, 2, nil, nil)
+	defer server.Close()
+
+	dconn, err := HdlcConnectRW(crw, 1, 2, nil, nil, time.Duration(100)*time.Millisecond, nil, time.Second*5, time.Second*5)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	err = server.WriteUI(append(append([]byte(nil), llcHeaderResponse...), newTestEventNotification(t)...))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	push, err := dconn.ReceivePush()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (2 != push.Src) || (1 != push.Dst) || (nil == push.EventNotification) {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	if 0x100 != push.EventNotification.Value.GetDoubleLongUnsigned() {
+		t.Fatalf("value differs")
+	}
+
+	// broadcast is received by server in UI frame
+	pdu := []byte{0x16, 0x01, 0x02, 0x03}
+	err = dconn.transportBroadcast(pdu)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	p, err := server.ReadUI()
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(append(append([]byte(nil), llcHeaderCommand...), pdu...), p) {
+		t.Fatalf("bytes does not match")
+	}
+}
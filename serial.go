@@ -0,0 +1,50 @@
+package gocosem
+
+import (
+	"time"
+)
+
+const (
+	SerialParityNone = byte('N')
+	SerialParityEven = byte('E')
+	SerialParityOdd  = byte('O')
+)
+
+/*
+Serial line settings. Zero values default to 8 data bits, no parity and 1
+stop bit. Optical probes (IEC 62056-21) usually start at 300 baud 7E1, HDLC
+over RS-485 typically runs at 9600 baud 8N1.
+*/
+type SerialConfig struct {
+	BaudRate int
+	DataBits int  // 5, 6, 7 or 8
+	Parity   byte // SerialParityNone, SerialParityEven or SerialParityOdd
+	StopBits int  // 1 or 2
+}
+
+func (config *SerialConfig) withDefaults() SerialConfig {
+	c := *config
+	if 0 == c.BaudRate {
+		c.BaudRate = 9600
+	}
+	if 0 == c.DataBits {
+		c.DataBits = 8
+	}
+	if 0 == c.Parity {
+		c.Parity = SerialParityNone
+	}
+	if 0 == c.StopBits {
+		c.StopBits = 1
+	}
+	return c
+}
+
+// Connects hdlc transport over serial port 'device' (e.g. /dev/ttyUSB0). For meaning of other parameters see HdlcConnect().
+func HdlcConnectSerial(device string, config *SerialConfig, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	debugLog("connecting hdlc transport over serial port: %s\n", device)
+	port, err := OpenSerial(device, config)
+	if nil != err {
+		return nil, err
+	}
+	return HdlcConnectRW(port, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+package gocosem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const serialCbaud = 0010017 // CBAUD | CBAUDEX
+
+// TCSETSW (set attributes after pending output was transmitted) follows TCSETS on all linux architectures.
+const serialTcsetsw = syscall.TCSETS + 1
+
+var serialBaudRates = map[int]uint32{
+	300:    syscall.B300,
+	600:    syscall.B600,
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+func serialIoctl(f *os.File, req uintptr, arg unsafe.Pointer) (err error) {
+	rc, err := f.SyscallConn()
+	if nil != err {
+		return err
+	}
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if nil != err {
+		return err
+	}
+	if 0 != errno {
+		return errno
+	}
+	return nil
+}
+
+// Sets raw mode and line settings of terminal device. Pending output is transmitted using previous settings.
+func serialConfigure(f *os.File, config *SerialConfig) (err error) {
+	c := config.withDefaults()
+
+	baud, ok := serialBaudRates[c.BaudRate]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate: %d", c.BaudRate)
+	}
+
+	var termios syscall.Termios
+	err = serialIoctl(f, syscall.TCGETS, unsafe.Pointer(&termios))
+	if nil != err {
+		return err
+	}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF | syscall.INPCK
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.PARODD | syscall.CSTOPB | serialCbaud
+	termios.Cflag |= syscall.CREAD | syscall.CLOCAL | baud
+
+	switch c.DataBits {
+	case 5:
+		termios.Cflag |= syscall.CS5
+	case 6:
+		termios.Cflag |= syscall.CS6
+	case 7:
+		termios.Cflag |= syscall.CS7
+	case 8:
+		termios.Cflag |= syscall.CS8
+	default:
+		return fmt.Errorf("unsupported data bits: %d", c.DataBits)
+	}
+
+	switch c.Parity {
+	case SerialParityNone:
+	case SerialParityEven:
+		termios.Cflag |= syscall.PARENB
+		termios.Iflag |= syscall.INPCK
+	case SerialParityOdd:
+		termios.Cflag |= syscall.PARENB | syscall.PARODD
+		termios.Iflag |= syscall.INPCK
+	default:
+		return fmt.Errorf("unsupported parity: %c", c.Parity)
+	}
+
+	switch c.StopBits {
+	case 1:
+	case 2:
+		termios.Cflag |= syscall.CSTOPB
+	default:
+		return fmt.Errorf("unsupported stop bits: %d", c.StopBits)
+	}
+
+	termios.Ispeed = baud
+	termios.Ospeed = baud
+
+	// block until at least one byte is available
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	return serialIoctl(f, serialTcsetsw, unsafe.Pointer(&termios))
+}
+
+// Opens serial port in raw mode. Closing returned port unblocks pending reads.
+func OpenSerial(device string, config *SerialConfig) (port io.ReadWriteCloser, err error) {
+	f, err := os.OpenFile(device, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if nil != err {
+		errorLog("os.OpenFile() failed: %v", err)
+		return nil, err
+	}
+	err = serialConfigure(f, config)
+	if nil != err {
+		errorLog("cannot configure serial port %s: %v", device, err)
+		f.Close()
+		return nil, err
+	}
+	debugLog("serial port %s opened", device)
+	return f, nil
+}
+
+// Changes line settings of serial port opened by OpenSerial(), e.g. to switch speed after IEC 62056-21 sign-on.
+func SetSerialConfig(port io.ReadWriteCloser, config *SerialConfig) (err error) {
+	f, ok := port.(*os.File)
+	if !ok {
+		err = fmt.Errorf("not a serial port")
+		errorLog("%s", err)
+		return err
+	}
+	err = serialConfigure(f, config)
+	if nil != err {
+		errorLog("cannot configure serial port: %v", err)
+		return err
+	}
+	return nil
+}
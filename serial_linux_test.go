@@ -0,0 +1,171 @@
+//go:build linux
+// +build linux
+
+package gocosem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// Opens pseudo terminal pair, returns master and path of slave device.
+func openPty(t *testing.T) (master *os.File, slave string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if nil != err {
+		t.Skipf("pseudo terminals not available: %v", err)
+	}
+	unlock := int32(0)
+	err = serialIoctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock))
+	if nil != err {
+		master.Close()
+		t.Fatalf("%s\n", err)
+	}
+	var n uint32
+	err = serialIoctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n))
+	if nil != err {
+		master.Close()
+		t.Fatalf("%s\n", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestSerial_OpenSerial(t *testing.T) {
+	master, slave := openPty(t)
+	defer master.Close()
+
+	_, err := OpenSerial(slave, &SerialConfig{BaudRate: 12345})
+	if nil == err {
+		t.Fatalf("unsupported baud rate accepted")
+	}
+
+	port, err := OpenSerial(slave, &SerialConfig{BaudRate: 300, DataBits: 7, Parity: SerialParityEven})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer port.Close()
+
+	// raw mode: bytes pass unchanged
+	b := []byte{0x7E, 0x0A, 0x0D, 0x03, 0x11, 0x7E}
+	_, err = port.Write(b)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	p := make([]byte, len(b))
+	n := 0
+	for n < len(p) {
+		m, err := master.Read(p[n:])
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		n += m
+	}
+	if !bytes.Equal(b, p) {
+		t.Fatalf("bytes don't match: % 02X", p)
+	}
+}
+
+func TestSerial_HdlcConnectSerial(t *testing.T) {
+	master, slave := openPty(t)
+
+	srv := NewCosemServer()
+	defer srv.Close()
+	go srv.ServeHdlc(master, 1, 1, nil, nil)
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(300))
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	dconn, err := HdlcConnectSerial(slave, &SerialConfig{BaudRate: 9600}, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+// Meter side of IEC 62056-21 mode E opening sequence.
+func scriptedModeEMeter(t *testing.T, port *os.File, identification string, expectedAck []byte) bool {
+	readLine := func() string {
+		line, err := iecReadLine(port, testHdlcSnrmTimeout)
+		if nil != err {
+			t.Errorf("%s\n", err)
+			return ""
+		}
+		return string(line)
+	}
+	if signOn := readLine(); "/?!\r\n" != signOn {
+		t.Errorf("unexpected sign-on: %q", signOn)
+		return false
+	}
+	_, err := port.Write([]byte(identification))
+	if nil != err {
+		t.Errorf("%s\n", err)
+		return false
+	}
+	if ack := readLine(); string(expectedAck) != ack {
+		t.Errorf("unexpected ack: %q", ack)
+		return false
+	}
+	return true
+}
+
+func TestSerial_HdlcConnectOptical(t *testing.T) {
+	master, slave := openPty(t)
+
+	srv := NewCosemServer()
+	defer srv.Close()
+	go func() {
+		if scriptedModeEMeter(t, master, "/ABC6\\2METER01\r\n", []byte{iecAck, '2', '5', '2', '\r', '\n'}) {
+			srv.ServeHdlc(master, 1, 1, nil, nil)
+		} else {
+			master.Close()
+		}
+	}()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(20))
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	dconn, ident, err := HdlcConnectOptical(slave, "", 9600, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	if ("ABC" != ident.Manufacturer) || ("METER01" != ident.Identification) {
+		t.Fatalf("unexpected identification: %+v", ident)
+	}
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
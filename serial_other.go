@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package gocosem
+
+import (
+	"fmt"
+	"io"
+)
+
+// Opens serial port in raw mode. Serial ports are supported on linux only.
+func OpenSerial(device string, config *SerialConfig) (port io.ReadWriteCloser, err error) {
+	err = fmt.Errorf("serial ports not supported on this platform")
+	errorLog("%s", err)
+	return nil, err
+}
+
+// Changes line settings of serial port opened by OpenSerial(). Serial ports are supported on linux only.
+func SetSerialConfig(port io.ReadWriteCloser, config *SerialConfig) (err error) {
+	err = fmt.Errorf("serial ports not supported on this platform")
+	errorLog("%s", err)
+	return err
+}
@@ -0,0 +1,1416 @@
+package gocosem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrServerClosed = errors.New("cosem server closed")
+
+// Attribute getter is called to obtain attribute value instead of returning stored attribute value.
+type CosemAttributeGetter func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (DlmsDataAccessResult, *DlmsData)
+
+// Attribute setter is called to set attribute value instead of replacing stored attribute value.
+type CosemAttributeSetter func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData, data *DlmsData) DlmsDataAccessResult
+
+// Method handler returns action result and optionally data returned by the method (dataAccessResult is nil for void methods).
+type CosemMethod func(obj *CosemObject, methodParameters *DlmsData) (actionResult DlmsActionResult, dataAccessResult *DlmsDataAccessResult, data *DlmsData)
+
+type CosemObject struct {
+	ClassId    DlmsClassId
+	InstanceId DlmsOid
+
+	mtx        sync.Mutex
+	attributes map[DlmsAttributeId]*DlmsData
+	getters    map[DlmsAttributeId]CosemAttributeGetter
+	setters    map[DlmsAttributeId]CosemAttributeSetter
+	methods    map[DlmsMethodId]CosemMethod
+}
+
+type CosemServer struct {
+	BlockLength         int           // If > 0 then replies longer then 'BlockLength' are sent in blocks.
+	Password            string        // If non empty then association must be authenticated using low level security with this password.
+	MaxReceivePduSize   uint16        // Reported to client in InitiateResponse.
+	HdlcResponseTimeout time.Duration // Response timeout of hdlc transport, see HdlcConnect().
+	HdlcCosemWaitTime   time.Duration // If > 0 then hdlc transport waits this time for reply to be generated before polling peer, see HdlcConnect().
+
+	conformance []byte
+
+	objects    map[DlmsOid]*CosemObject
+	baseNames  map[DlmsShortName]DlmsOid // objects accessible using short name referencing
+	objectsMtx sync.RWMutex
+
+	mtx       sync.Mutex
+	closed    bool
+	listeners map[net.Listener]bool
+	conns     map[*tCosemServerConnection]bool
+}
+
+type tCosemServerBlockTransfer struct {
+	classIds         []DlmsClassId
+	instanceIds      []*DlmsOid
+	attributeIds     []DlmsAttributeId
+	methodIds        []DlmsMethodId
+	accessSelectors  []DlmsAccessSelector
+	accessParameters []*DlmsData
+	blockNumber      uint32
+	rawData          *bytes.Buffer
+}
+
+type tCosemServerConnection struct {
+	srv               *CosemServer
+	transportType     int
+	rwc               io.ReadWriteCloser // wrapper stream or hdlc transport
+	hdlcRwc           io.ReadWriteCloser // stream used by hdlc transport
+	logicalDevice     uint16
+	applicationClient uint16
+	associated        bool
+	shortNames        bool // association uses short name referencing
+	maxPduSize        int  // client max receive pdu size, replies are sent in blocks to fit in it (no limit if 0)
+	closeOnce         sync.Once
+
+	replyBlocks map[uint8][][]byte                   // blocks of reply to be sent to client (key is invokeId)
+	setBlocks   map[uint8]*tCosemServerBlockTransfer // inbound SetRequest block transfer (key is invokeId)
+	actBlocks   map[uint8]*tCosemServerBlockTransfer // inbound ActionRequest block transfer (key is invokeId)
+
+	snWriteBlocks      *bytes.Buffer // inbound WriteRequest block transfer
+	snWriteBlockNumber uint16
+}
+
+// Conformance block of services implemented by server (read, write, unconfirmed write, block transfer with get, set and action, multiple references, parameterized access, get, set, selective access, action).
+var cosemServerConformance = []byte{0x1C, 0x1E, 0x3D}
+
+// SN services have no invoke id, this key is used for their reply blocks.
+const snReplyBlocksKey = 0xFF
+
+var cosemApplicationContextLN = tAsn1ObjectIdentifier([]uint32{2, 16, 756, 5, 8, 1, 1})
+var cosemApplicationContextSN = tAsn1ObjectIdentifier([]uint32{2, 16, 756, 5, 8, 1, 2})
+var cosemMechanismNameLLS = tAsn1ObjectIdentifier([]uint32{2, 16, 756, 5, 8, 2, 1})
+
+func NewCosemServer() *CosemServer {
+	srv := new(CosemServer)
+	srv.MaxReceivePduSize = 0xFFFF
+	srv.HdlcResponseTimeout = time.Duration(1) * time.Hour
+	srv.conformance = cosemServerConformance
+	srv.objects = make(map[DlmsOid]*CosemObject)
+	srv.baseNames = make(map[DlmsShortName]DlmsOid)
+	srv.listeners = make(map[net.Listener]bool)
+	srv.conns = make(map[*tCosemServerConnection]bool)
+	return srv
+}
+
+// Adds object to server replacing any existing object having same instance id.
+func (srv *CosemServer) AddObject(classId DlmsClassId, instanceId *DlmsOid) *CosemObject {
+	obj := new(CosemObject)
+	obj.ClassId = classId
+	obj.InstanceId = *instanceId
+	obj.attributes = make(map[DlmsAttributeId]*DlmsData)
+	obj.getters = make(map[DlmsAttributeId]CosemAttributeGetter)
+	obj.setters = make(map[DlmsAttributeId]CosemAttributeSetter)
+	obj.methods = make(map[DlmsMethodId]CosemMethod)
+
+	srv.objectsMtx.Lock()
+	srv.objects[*instanceId] = obj
+	srv.objectsMtx.Unlock()
+	return obj
+}
+
+func (srv *CosemServer) GetObject(instanceId *DlmsOid) *CosemObject {
+	srv.objectsMtx.RLock()
+	defer srv.objectsMtx.RUnlock()
+	return srv.objects[*instanceId]
+}
+
+func (srv *CosemServer) RemoveObject(instanceId *DlmsOid) {
+	srv.objectsMtx.Lock()
+	delete(srv.objects, *instanceId)
+	for baseName, oid := range srv.baseNames {
+		if oid == *instanceId {
+			delete(srv.baseNames, baseName)
+		}
+	}
+	srv.objectsMtx.Unlock()
+}
+
+// Makes object accessible using short name referencing, attribute n is read and written at SNAttribute(baseName, n).
+func (srv *CosemServer) SetBaseName(instanceId *DlmsOid, baseName DlmsShortName) {
+	srv.objectsMtx.Lock()
+	srv.baseNames[baseName] = *instanceId
+	srv.objectsMtx.Unlock()
+}
+
+// Resolves short name to object and attribute, nearest lower base name wins.
+func (srv *CosemServer) lookupShortName(variableName DlmsShortName) (obj *CosemObject, attributeId DlmsAttributeId, dataAccessResult DlmsDataAccessResult) {
+	srv.objectsMtx.RLock()
+	defer srv.objectsMtx.RUnlock()
+	for offset := 0; (offset <= int(variableName)) && (offset < 256*8); offset += 8 {
+		instanceId, ok := srv.baseNames[variableName-DlmsShortName(offset)]
+		if ok {
+			obj = srv.objects[instanceId]
+			if nil == obj {
+				break
+			}
+			return obj, DlmsAttributeId(offset/8 + 1), dataAccessResult_success
+		}
+	}
+	debugLog("no such short name: 0x%04X", variableName)
+	return nil, 0, dataAccessResult_objectUndefined
+}
+
+func (obj *CosemObject) SetAttribute(attributeId DlmsAttributeId, data *DlmsData) {
+	obj.mtx.Lock()
+	obj.attributes[attributeId] = data
+	obj.mtx.Unlock()
+}
+
+func (obj *CosemObject) GetAttribute(attributeId DlmsAttributeId) *DlmsData {
+	obj.mtx.Lock()
+	defer obj.mtx.Unlock()
+	return obj.attributes[attributeId]
+}
+
+func (obj *CosemObject) SetAttributeGetter(attributeId DlmsAttributeId, getter CosemAttributeGetter) {
+	obj.mtx.Lock()
+	obj.getters[attributeId] = getter
+	obj.mtx.Unlock()
+}
+
+func (obj *CosemObject) SetAttributeSetter(attributeId DlmsAttributeId, setter CosemAttributeSetter) {
+	obj.mtx.Lock()
+	obj.setters[attributeId] = setter
+	obj.mtx.Unlock()
+}
+
+func (obj *CosemObject) SetMethod(methodId DlmsMethodId, method CosemMethod) {
+	obj.mtx.Lock()
+	obj.methods[methodId] = method
+	obj.mtx.Unlock()
+}
+
+func (obj *CosemObject) getData(attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (dataAccessResult DlmsDataAccessResult, data *DlmsData) {
+	obj.mtx.Lock()
+	getter := obj.getters[attributeId]
+	data, ok := obj.attributes[attributeId]
+	obj.mtx.Unlock()
+
+	if nil != getter {
+		return getter(obj, attributeId, accessSelector, accessParameters)
+	}
+	if !ok {
+		if 1 == attributeId {
+			// logical_name
+			data = new(DlmsData)
+			data.SetOctetString(obj.InstanceId[:])
+			return dataAccessResult_success, data
+		}
+		return dataAccessResult_objectUndefined, nil
+	}
+	return dataAccessResult_success, data
+}
+
+func (obj *CosemObject) setData(attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData, data *DlmsData) (dataAccessResult DlmsDataAccessResult) {
+	obj.mtx.Lock()
+	setter := obj.setters[attributeId]
+	obj.mtx.Unlock()
+
+	if nil != setter {
+		return setter(obj, attributeId, accessSelector, accessParameters, data)
+	}
+
+	obj.mtx.Lock()
+	defer obj.mtx.Unlock()
+	if _, ok := obj.attributes[attributeId]; !ok {
+		return dataAccessResult_objectUndefined
+	}
+	obj.attributes[attributeId] = data
+	return dataAccessResult_success
+}
+
+func (obj *CosemObject) callMethod(methodId DlmsMethodId, methodParameters *DlmsData) (actionResult DlmsActionResult, dataAccessResult *DlmsDataAccessResult, data *DlmsData) {
+	obj.mtx.Lock()
+	method := obj.methods[methodId]
+	obj.mtx.Unlock()
+
+	if nil == method {
+		return actionResult_objectUndefined, nil, nil
+	}
+	return method(obj, methodParameters)
+}
+
+func (srv *CosemServer) lookupObject(classId DlmsClassId, instanceId *DlmsOid) (obj *CosemObject, dataAccessResult DlmsDataAccessResult) {
+	if nil == instanceId {
+		return nil, dataAccessResult_objectUndefined
+	}
+	obj = srv.GetObject(instanceId)
+	if nil == obj {
+		debugLog("no such instance id: %v", *instanceId)
+		return nil, dataAccessResult_objectUndefined
+	}
+	if obj.ClassId != classId {
+		debugLog("instance class mismatch: %v, class: %d, expected class: %d", *instanceId, classId, obj.ClassId)
+		return nil, dataAccessResult_objectClassInconsistent
+	}
+	return obj, dataAccessResult_success
+}
+
+func (srv *CosemServer) getData(classId DlmsClassId, instanceId *DlmsOid, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (dataAccessResult DlmsDataAccessResult, data *DlmsData) {
+	obj, dataAccessResult := srv.lookupObject(classId, instanceId)
+	if nil == obj {
+		return dataAccessResult, nil
+	}
+	return obj.getData(attributeId, accessSelector, accessParameters)
+}
+
+func (srv *CosemServer) setData(classId DlmsClassId, instanceId *DlmsOid, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData, data *DlmsData) (dataAccessResult DlmsDataAccessResult) {
+	obj, dataAccessResult := srv.lookupObject(classId, instanceId)
+	if nil == obj {
+		return dataAccessResult
+	}
+	return obj.setData(attributeId, accessSelector, accessParameters, data)
+}
+
+func (srv *CosemServer) callMethod(classId DlmsClassId, instanceId *DlmsOid, methodId DlmsMethodId, methodParameters *DlmsData) (actionResult DlmsActionResult, dataAccessResult *DlmsDataAccessResult, data *DlmsData) {
+	obj, _dataAccessResult := srv.lookupObject(classId, instanceId)
+	if nil == obj {
+		return DlmsActionResult(_dataAccessResult), nil, nil
+	}
+	return obj.callMethod(methodId, methodParameters)
+}
+
+// Accepts DLMS wrapper connections (TCP transport) on 'addr' and serves them in background until server is closed.
+func (srv *CosemServer) ListenTcp(addr string) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		errorLog("net.Listen() failed: %v", err)
+		return nil, err
+	}
+	err = srv.addListener(ln)
+	if nil != err {
+		return nil, err
+	}
+	go srv.acceptLoop(ln, func(conn net.Conn) error {
+		return srv.ServeTcp(conn)
+	})
+	return ln, nil
+}
+
+// Accepts HDLC over TCP connections on 'addr' and serves them in background until server is closed.
+// Server hdlc address and client address are set as in HdlcConnect().
+func (srv *CosemServer) ListenHdlc(addr string, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int) (ln net.Listener, err error) {
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		errorLog("net.Listen() failed: %v", err)
+		return nil, err
+	}
+	err = srv.addListener(ln)
+	if nil != err {
+		return nil, err
+	}
+	go srv.acceptLoop(ln, func(conn net.Conn) error {
+		return srv.ServeHdlc(conn, applicationClient, logicalDevice, physicalDevice, serverAddressLength)
+	})
+	return ln, nil
+}
+
+func (srv *CosemServer) addListener(ln net.Listener) (err error) {
+	srv.mtx.Lock()
+	defer srv.mtx.Unlock()
+	if srv.closed {
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.listeners[ln] = true
+	return nil
+}
+
+func (srv *CosemServer) acceptLoop(ln net.Listener, serve func(conn net.Conn) error) {
+	for {
+		conn, err := ln.Accept()
+		if nil != err {
+			srv.mtx.Lock()
+			closed := srv.closed
+			srv.mtx.Unlock()
+			if !closed {
+				errorLog("ln.Accept() failed: %v", err)
+			}
+			return
+		}
+		go serve(conn)
+	}
+}
+
+// Serves single DLMS wrapper connection. Returns after client closed the connection or server was closed.
+func (srv *CosemServer) ServeTcp(rwc io.ReadWriteCloser) (err error) {
+	conn := srv.newConnection(Transport_TCP, rwc, nil)
+	if nil == conn {
+		rwc.Close()
+		return ErrServerClosed
+	}
+	return conn.serve()
+}
+
+// Serves single HDLC connection. Returns after client closed the connection or server was closed.
+func (srv *CosemServer) ServeHdlc(rwc io.ReadWriteCloser, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int) (err error) {
+	htran := newHdlcTransport(rwc, srv.HdlcResponseTimeout, false, uint8(applicationClient), logicalDevice, physicalDevice, serverAddressLength)
+	if srv.HdlcCosemWaitTime > 0 {
+		htran.SetForCosem(srv.HdlcCosemWaitTime)
+	}
+	go htran.handleHdlc()
+	conn := srv.newConnection(Transport_HDLC, htran, rwc)
+	if nil == conn {
+		htran.Close()
+		rwc.Close()
+		return ErrServerClosed
+	}
+	conn.logicalDevice = logicalDevice
+	conn.applicationClient = applicationClient
+	return conn.serve()
+}
+
+/*
+Serves link accepted by HdlcListener, logical device and application client
+are taken from link addresses. Returns after client disconnected the link or
+server was closed.
+*/
+func (srv *CosemServer) ServeHdlcLink(link *HdlcLink) (err error) {
+	if srv.HdlcCosemWaitTime > 0 {
+		link.htran.SetForCosem(srv.HdlcCosemWaitTime)
+	}
+	conn := srv.newConnection(Transport_HDLC, link, nil)
+	if nil == conn {
+		link.Close()
+		return ErrServerClosed
+	}
+	conn.logicalDevice = link.LogicalDevice
+	conn.applicationClient = uint16(link.ClientId)
+	return conn.serve()
+}
+
+func (srv *CosemServer) newConnection(transportType int, rwc io.ReadWriteCloser, hdlcRwc io.ReadWriteCloser) (conn *tCosemServerConnection) {
+	conn = new(tCosemServerConnection)
+	conn.srv = srv
+	conn.transportType = transportType
+	conn.rwc = rwc
+	conn.hdlcRwc = hdlcRwc
+	conn.replyBlocks = make(map[uint8][][]byte)
+	conn.setBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+	conn.actBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+
+	srv.mtx.Lock()
+	defer srv.mtx.Unlock()
+	if srv.closed {
+		return nil
+	}
+	srv.conns[conn] = true
+	return conn
+}
+
+// Closes all listeners and all client connections.
+func (srv *CosemServer) Close() (err error) {
+	srv.mtx.Lock()
+	if srv.closed {
+		srv.mtx.Unlock()
+		return nil
+	}
+	srv.closed = true
+	listeners := srv.listeners
+	conns := srv.conns
+	srv.listeners = make(map[net.Listener]bool)
+	srv.conns = make(map[*tCosemServerConnection]bool)
+	srv.mtx.Unlock()
+
+	for ln := range listeners {
+		e := ln.Close()
+		if nil != e {
+			err = e
+		}
+	}
+	for conn := range conns {
+		conn.close()
+	}
+	return err
+}
+
+func (conn *tCosemServerConnection) close() {
+	conn.closeOnce.Do(func() {
+		conn.rwc.Close()
+		if nil != conn.hdlcRwc {
+			conn.hdlcRwc.Close()
+		}
+	})
+}
+
+func (conn *tCosemServerConnection) serve() (err error) {
+	defer func() {
+		conn.srv.mtx.Lock()
+		delete(conn.srv.conns, conn)
+		conn.srv.mtx.Unlock()
+		conn.close()
+	}()
+
+	for {
+		pdu, err := conn.receive()
+		if nil != err {
+			if io.EOF == err || HdlcErrorTransportClosed == err {
+				return nil
+			}
+			return err
+		}
+		err = conn.replyToRequest(pdu)
+		if _, ok := err.(*HdlcLinkResetError); ok {
+			// reply was lost with the link, client has to associate again
+			warnLog("dropping association: %s", err)
+			conn.associated = false
+			conn.replyBlocks = make(map[uint8][][]byte)
+			conn.setBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+			conn.actBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+			continue
+		}
+		if nil != err {
+			errorLog("%s", err)
+			return err
+		}
+	}
+}
+
+func (conn *tCosemServerConnection) receive() (pdu []byte, err error) {
+	if Transport_TCP == conn.transportType {
+		if conn.associated {
+			pdu, _, _, err = ipTransportReceive(conn.rwc, &conn.applicationClient, &conn.logicalDevice)
+		} else {
+			// addresses are learned from received AARQ
+			pdu, conn.applicationClient, conn.logicalDevice, err = ipTransportReceive(conn.rwc, nil, nil)
+		}
+		return pdu, err
+	} else if Transport_HDLC == conn.transportType {
+		return hdlcTransportReceive(conn.rwc, llcHeaderCommand, conn.srv.MaxReceivePduSize)
+	} else {
+		err = fmt.Errorf("unsupported transport type: %d", conn.transportType)
+		errorLog("%s", err)
+		return nil, err
+	}
+}
+
+func (conn *tCosemServerConnection) send(pdu []byte) (err error) {
+	if Transport_TCP == conn.transportType {
+		return ipTransportSend(conn.rwc, conn.logicalDevice, conn.applicationClient, pdu)
+	} else if Transport_HDLC == conn.transportType {
+		return hdlcTransportSend(conn.rwc, llcHeaderResponse, pdu)
+	} else {
+		err = fmt.Errorf("unsupported transport type: %d", conn.transportType)
+		errorLog("%s", err)
+		return err
+	}
+}
+
+func (conn *tCosemServerConnection) acceptApp(pdu []byte) (err error) {
+	var diagnostic assocDiagnostic
+
+	aare := new(AAREapdu)
+	aare.applicationContextName = cosemApplicationContextLN
+	aare.result = tAsn1Integer(AssociationAccepted)
+	aare.resultSourceDiagnostic.setVal(1, tAsn1Integer(DiagNull))
+
+	initiateRequest := new(DlmsInitiateRequest)
+	err, aarq := decode_AARQapdu(bytes.NewReader(pdu))
+	if nil != err {
+		// reply with rejection instead of dropping the connection
+		diagnostic = DiagNoReason
+	} else {
+		aare.applicationContextName = aarq.applicationContextName
+		diagnostic = conn.srv.authenticate(aarq)
+		conn.shortNames = oidEqual(aarq.applicationContextName, cosemApplicationContextSN)
+	}
+	if DiagNull == diagnostic {
+		if nil == aarq.userInformation {
+			diagnostic = DiagNoReason
+		} else {
+			err = initiateRequest.decode(bytes.NewReader(*aarq.userInformation))
+			if nil != err {
+				diagnostic = DiagNoReason
+			}
+		}
+	}
+
+	if DiagNull == diagnostic {
+		err, userInformation := conn.srv.encodeInitiateResponse(initiateRequest, conn.shortNames)
+		if nil != err {
+			return err
+		}
+		aare.userInformation = &userInformation
+		conn.associated = true
+		conn.maxPduSize = int(initiateRequest.clientMaxReceivePduSize)
+	} else {
+		debugLog("association rejected, diagnostic: %d", diagnostic)
+		aare.result = tAsn1Integer(AssociationRejectedPermanent)
+		aare.resultSourceDiagnostic.setVal(1, tAsn1Integer(diagnostic))
+		conn.associated = false
+	}
+
+	var buf bytes.Buffer
+	err = encode_AAREapdu(&buf, aare)
+	if nil != err {
+		return err
+	}
+	return conn.send(buf.Bytes())
+}
+
+// Releases association, connection stays open for next AARQ.
+func (conn *tCosemServerConnection) releaseApp(pdu []byte) (err error) {
+	err, rlrq := decode_RLRQapdu(bytes.NewReader(pdu))
+	if nil != err {
+		return err
+	}
+
+	rlre := new(RLREapdu)
+	reason := RLRE_REASON_NORMAL
+	rlre.reason = &reason
+
+	if conn.associated && (nil != rlrq.userInformation) {
+		initiateRequest := new(DlmsInitiateRequest)
+		err = initiateRequest.decode(bytes.NewReader(*rlrq.userInformation))
+		if nil == err {
+			err, userInformation := conn.srv.encodeInitiateResponse(initiateRequest, conn.shortNames)
+			if nil != err {
+				return err
+			}
+			rlre.userInformation = &userInformation
+		}
+	}
+	conn.associated = false
+	conn.replyBlocks = make(map[uint8][][]byte)
+	conn.setBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+	conn.actBlocks = make(map[uint8]*tCosemServerBlockTransfer)
+	conn.snWriteBlocks = nil
+
+	var buf bytes.Buffer
+	err = encode_RLREapdu(&buf, rlre)
+	if nil != err {
+		return err
+	}
+	return conn.send(buf.Bytes())
+}
+
+func (srv *CosemServer) encodeInitiateResponse(initiateRequest *DlmsInitiateRequest, shortNames bool) (err error, userInformation tAsn1OctetString) {
+	initiateResponse := new(DlmsInitiateResponse)
+	initiateResponse.negotiatedDlmsVersionNumber = 6
+	initiateResponse.negotiatedConformance.buf = srv.negotiateConformance(initiateRequest.proposedConformance.buf)
+	initiateResponse.serverMaxReceivePduSize = srv.MaxReceivePduSize
+	if shortNames {
+		initiateResponse.vaaName = -0x0600 // 0xFA00
+	} else {
+		initiateResponse.vaaName = 0x0007
+	}
+
+	var buf bytes.Buffer
+	err = initiateResponse.encode(&buf)
+	if nil != err {
+		return err, nil
+	}
+	return nil, tAsn1OctetString(buf.Bytes())
+}
+
+func (srv *CosemServer) authenticate(aarq *AARQapdu) (diagnostic assocDiagnostic) {
+	if !oidEqual(aarq.applicationContextName, cosemApplicationContextLN) && !oidEqual(aarq.applicationContextName, cosemApplicationContextSN) {
+		return DiagAppContextNotSupported
+	}
+	if nil == aarq.mechanismName {
+		if "" != srv.Password {
+			return DiagAuthMechanismRequired
+		}
+		return DiagNull
+	}
+	if !oidEqual(*aarq.mechanismName, cosemMechanismNameLLS) {
+		return DiagAuthMechanismNotRecognized
+	}
+	if (nil == aarq.callingAuthenticationValue) || (0 != aarq.callingAuthenticationValue.getTag()) {
+		return DiagAuthenticationFailure
+	}
+	password, ok := aarq.callingAuthenticationValue.getVal().(tAsn1GraphicString)
+	if !ok || (string(password) != srv.Password) {
+		return DiagAuthenticationFailure
+	}
+	return DiagNull
+}
+
+func (srv *CosemServer) negotiateConformance(proposed []byte) (negotiated []byte) {
+	negotiated = make([]byte, len(srv.conformance))
+	for i := 0; i < len(negotiated) && i < len(proposed); i++ {
+		negotiated[i] = srv.conformance[i] & proposed[i]
+	}
+	return negotiated
+}
+
+func oidEqual(a tAsn1ObjectIdentifier, b tAsn1ObjectIdentifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Splits reply into blocks if it exceeds block length or if it would not fit in client max receive pdu size.
+func (conn *tCosemServerConnection) splitReply(invokeId uint8, reply []byte) (blocks [][]byte) {
+	l := conn.srv.BlockLength
+	if conn.maxPduSize > 0 {
+		n := conn.maxPduSize - 12 // room for apdu header and block header
+		if n < 1 {
+			n = 1
+		}
+		if (l <= 0) || (l > n) {
+			l = n
+		}
+	}
+	if (l <= 0) || (len(reply) <= l) {
+		return nil
+	}
+	for len(reply) > l {
+		blocks = append(blocks, reply[0:l])
+		reply = reply[l:]
+	}
+	blocks = append(blocks, reply)
+	conn.replyBlocks[invokeId] = blocks
+	return blocks
+}
+
+func (conn *tCosemServerConnection) sendReply(b0 byte, b1 byte, invokeIdAndPriority tDlmsInvokeIdAndPriority, reply []byte) (err error) {
+	var buf bytes.Buffer
+	_, err = buf.Write([]byte{b0, b1, byte(invokeIdAndPriority)})
+	if nil != err {
+		errorLog("buf.Write() failed: %v\n", err)
+		return err
+	}
+	_, err = buf.Write(reply)
+	if nil != err {
+		errorLog("buf.Write() failed: %v\n", err)
+		return err
+	}
+	return conn.send(buf.Bytes())
+}
+
+// Sends GetResponse either normally or as first block of block transfer.
+// For block transfer 'rawData' contains only encoded data.
+func (conn *tCosemServerConnection) sendGetReply(b1 byte, invokeIdAndPriority tDlmsInvokeIdAndPriority, reply []byte, rawData []byte) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	blocks := conn.splitReply(invokeId, rawData)
+	if nil == blocks {
+		return conn.sendReply(0xC4, b1, invokeIdAndPriority, reply)
+	}
+	debugLog("outbound block transfer, blocks count: %d", len(blocks))
+
+	var buf bytes.Buffer
+	err = encode_GetResponsewithDataBlock(&buf, false, 1, dataAccessResult_success, blocks[0])
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC4, 0x02, invokeIdAndPriority, buf.Bytes())
+}
+
+// Sends ActionResponse either normally or as first block of block transfer.
+func (conn *tCosemServerConnection) sendActionReply(b1 byte, invokeIdAndPriority tDlmsInvokeIdAndPriority, reply []byte) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	blocks := conn.splitReply(invokeId, reply)
+	if nil == blocks {
+		return conn.sendReply(0xC7, b1, invokeIdAndPriority, reply)
+	}
+	debugLog("outbound block transfer, blocks count: %d", len(blocks))
+
+	var buf bytes.Buffer
+	err = encode_ActionResponseWithPblock(&buf, false, 1, blocks[0])
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC7, 0x02, invokeIdAndPriority, buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) nextReplyBlock(invokeId uint8, blockNumber uint32) (lastBlock bool, rawData []byte, ok bool) {
+	blocks := conn.replyBlocks[invokeId]
+	if (nil == blocks) || (int(blockNumber) < 1) || (int(blockNumber) >= len(blocks)) {
+		delete(conn.replyBlocks, invokeId)
+		return false, nil, false
+	}
+	lastBlock = len(blocks)-1 == int(blockNumber)
+	if lastBlock {
+		delete(conn.replyBlocks, invokeId)
+	}
+	return lastBlock, blocks[blockNumber], true
+}
+
+func (conn *tCosemServerConnection) getResponseNormal(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classId, instanceId, attributeId, accessSelector, accessParameters := decode_GetRequestNormal(r)
+	if nil != err {
+		return err
+	}
+
+	dataAccessResult, data := conn.srv.getData(classId, instanceId, attributeId, accessSelector, accessParameters)
+	debugLog("dataAccessResult: %d", dataAccessResult)
+
+	var buf bytes.Buffer
+	err = encode_GetResponseNormal(&buf, dataAccessResult, data)
+	if nil != err {
+		return err
+	}
+
+	var rawData []byte
+	if dataAccessResult_success == dataAccessResult {
+		var _buf bytes.Buffer
+		err = encode_GetResponseNormalBlock(&_buf, data)
+		if nil != err {
+			return err
+		}
+		rawData = _buf.Bytes()
+	}
+	return conn.sendGetReply(0x01, invokeIdAndPriority, buf.Bytes(), rawData)
+}
+
+func (conn *tCosemServerConnection) getResponseWithList(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classIds, instanceIds, attributeIds, accessSelectors, accessParameters := decode_GetRequestWithList(r)
+	if nil != err {
+		return err
+	}
+
+	count := len(classIds)
+	datas := make([]*DlmsData, count)
+	dataAccessResults := make([]DlmsDataAccessResult, count)
+	for i := 0; i < count; i++ {
+		dataAccessResults[i], datas[i] = conn.srv.getData(classIds[i], instanceIds[i], attributeIds[i], accessSelectors[i], accessParameters[i])
+		debugLog("dataAccessResult[%d]: %d", i, dataAccessResults[i])
+	}
+
+	var buf bytes.Buffer
+	err = encode_GetResponseWithList(&buf, dataAccessResults, datas)
+	if nil != err {
+		return err
+	}
+	return conn.sendGetReply(0x03, invokeIdAndPriority, buf.Bytes(), buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) getResponseNextBlock(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	err, blockNumber := decode_GetRequestForNextDataBlock(r)
+	if nil != err {
+		return err
+	}
+
+	var buf bytes.Buffer
+	lastBlock, rawData, ok := conn.nextReplyBlock(invokeId, blockNumber)
+	if ok {
+		err = encode_GetResponsewithDataBlock(&buf, lastBlock, blockNumber+1, dataAccessResult_success, rawData)
+	} else {
+		debugLog("no such block: invokeId: %d, blockNumber: %d", invokeId, blockNumber+1)
+		err = encode_GetResponsewithDataBlock(&buf, true, blockNumber+1, dataAccessResult_dataBlockNumberInvalid, nil)
+	}
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC4, 0x02, invokeIdAndPriority, buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) setResponseNormal(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classId, instanceId, attributeId, accessSelector, accessParameters, data := decode_SetRequestNormal(r)
+	if nil != err {
+		return err
+	}
+
+	dataAccessResult := conn.srv.setData(classId, instanceId, attributeId, accessSelector, accessParameters, data)
+	debugLog("dataAccessResult: %d", dataAccessResult)
+
+	var buf bytes.Buffer
+	err = encode_SetResponseNormal(&buf, dataAccessResult)
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC5, 0x01, invokeIdAndPriority, buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) setResponseWithList(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classIds, instanceIds, attributeIds, accessSelectors, accessParameters, datas := decode_SetRequestWithList(r)
+	if nil != err {
+		return err
+	}
+
+	count := len(classIds)
+	dataAccessResults := make([]DlmsDataAccessResult, count)
+	for i := 0; i < count; i++ {
+		dataAccessResults[i] = conn.srv.setData(classIds[i], instanceIds[i], attributeIds[i], accessSelectors[i], accessParameters[i], datas[i])
+		debugLog("dataAccessResult[%d]: %d", i, dataAccessResults[i])
+	}
+
+	var buf bytes.Buffer
+	err = encode_SetResponseWithList(&buf, dataAccessResults)
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC5, 0x05, invokeIdAndPriority, buf.Bytes())
+}
+
+// Handles received SetRequest data block. Sets the data after last block was received.
+func (conn *tCosemServerConnection) setResponseBlock(invokeIdAndPriority tDlmsInvokeIdAndPriority, lastBlock bool, blockNumber uint32, rawData []byte) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	transfer := conn.setBlocks[invokeId]
+	if (nil == transfer) || (transfer.blockNumber+1 != blockNumber) {
+		debugLog("unexpected block: invokeId: %d, blockNumber: %d", invokeId, blockNumber)
+		delete(conn.setBlocks, invokeId)
+
+		var buf bytes.Buffer
+		err = encode_SetResponseForLastDataBlock(&buf, dataAccessResult_dataBlockNumberInvalid, blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC5, 0x03, invokeIdAndPriority, buf.Bytes())
+	}
+	transfer.blockNumber = blockNumber
+	_, err = transfer.rawData.Write(rawData)
+	if nil != err {
+		return err
+	}
+
+	if !lastBlock {
+		var buf bytes.Buffer
+		err = encode_SetResponseForDataBlock(&buf, blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC5, 0x02, invokeIdAndPriority, buf.Bytes())
+	}
+
+	delete(conn.setBlocks, invokeId)
+
+	count := len(transfer.classIds)
+	dataAccessResults := make([]DlmsDataAccessResult, count)
+
+	if count > 1 {
+		var _count uint8
+		err = binary.Read(transfer.rawData, binary.BigEndian, &_count)
+		if nil != err {
+			errorLog("binary.Read() failed: %v", err)
+			return err
+		}
+		if int(_count) != count {
+			err = fmt.Errorf("unexpected count of data blocks items: %d, expected: %d", _count, count)
+			errorLog("%s", err)
+			return err
+		}
+	}
+	for i := 0; i < count; i++ {
+		data := new(DlmsData)
+		err = data.Decode(transfer.rawData)
+		if nil != err {
+			return err
+		}
+		dataAccessResults[i] = conn.srv.setData(transfer.classIds[i], transfer.instanceIds[i], transfer.attributeIds[i], transfer.accessSelectors[i], transfer.accessParameters[i], data)
+		debugLog("dataAccessResult[%d]: %d", i, dataAccessResults[i])
+	}
+
+	var buf bytes.Buffer
+	if count > 1 {
+		err = encode_SetResponseForLastDataBlockWithList(&buf, dataAccessResults, blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC5, 0x04, invokeIdAndPriority, buf.Bytes())
+	} else {
+		err = encode_SetResponseForLastDataBlock(&buf, dataAccessResults[0], blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC5, 0x03, invokeIdAndPriority, buf.Bytes())
+	}
+}
+
+func (conn *tCosemServerConnection) actionResponseNormal(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classId, instanceId, methodId, methodParameters := decode_ActionRequestNormal(r)
+	if nil != err {
+		return err
+	}
+	return conn.actionResponse(invokeIdAndPriority, []DlmsClassId{classId}, []*DlmsOid{instanceId}, []DlmsMethodId{methodId}, []*DlmsData{methodParameters})
+}
+
+func (conn *tCosemServerConnection) actionResponseWithList(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	err, classIds, instanceIds, methodIds, methodParameters := decode_ActionRequestWithList(r)
+	if nil != err {
+		return err
+	}
+	return conn.actionResponse(invokeIdAndPriority, classIds, instanceIds, methodIds, methodParameters)
+}
+
+// Calls methods and sends ActionResponseNormal (single method) or ActionResponseWithList.
+func (conn *tCosemServerConnection) actionResponse(invokeIdAndPriority tDlmsInvokeIdAndPriority, classIds []DlmsClassId, instanceIds []*DlmsOid, methodIds []DlmsMethodId, methodParameters []*DlmsData) (err error) {
+	count := len(classIds)
+	actionResults := make([]DlmsActionResult, count)
+	dataAccessResults := make([]*DlmsDataAccessResult, count)
+	datas := make([]*DlmsData, count)
+	for i := 0; i < count; i++ {
+		actionResults[i], dataAccessResults[i], datas[i] = conn.srv.callMethod(classIds[i], instanceIds[i], methodIds[i], methodParameters[i])
+		debugLog("actionResult[%d]: %d", i, actionResults[i])
+	}
+
+	var buf bytes.Buffer
+	if 1 == count {
+		err = encode_ActionResponseNormal(&buf, actionResults[0], dataAccessResults[0], datas[0])
+		if nil != err {
+			return err
+		}
+		return conn.sendActionReply(0x01, invokeIdAndPriority, buf.Bytes())
+	} else {
+		err = encode_ActionResponseWithList(&buf, actionResults, dataAccessResults, datas)
+		if nil != err {
+			return err
+		}
+		return conn.sendActionReply(0x03, invokeIdAndPriority, buf.Bytes())
+	}
+}
+
+// Handles received ActionRequest parameters block. Calls methods after last block was received.
+func (conn *tCosemServerConnection) actionResponseBlock(invokeIdAndPriority tDlmsInvokeIdAndPriority, lastBlock bool, blockNumber uint32, rawData []byte) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	transfer := conn.actBlocks[invokeId]
+	if (nil == transfer) || (transfer.blockNumber+1 != blockNumber) {
+		debugLog("unexpected block: invokeId: %d, blockNumber: %d", invokeId, blockNumber)
+		delete(conn.actBlocks, invokeId)
+
+		var buf bytes.Buffer
+		err = encode_ActionResponseNormal(&buf, actionResult_longActionAborted, nil, nil)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC7, 0x01, invokeIdAndPriority, buf.Bytes())
+	}
+	transfer.blockNumber = blockNumber
+	_, err = transfer.rawData.Write(rawData)
+	if nil != err {
+		return err
+	}
+
+	if !lastBlock {
+		var buf bytes.Buffer
+		err = encode_ActionResponseNextPblock(&buf, blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC7, 0x04, invokeIdAndPriority, buf.Bytes())
+	}
+
+	delete(conn.actBlocks, invokeId)
+
+	count := len(transfer.classIds)
+	methodParameters := make([]*DlmsData, count)
+
+	if count > 1 {
+		var _count uint8
+		err = binary.Read(transfer.rawData, binary.BigEndian, &_count)
+		if nil != err {
+			errorLog("binary.Read() failed: %v", err)
+			return err
+		}
+		if int(_count) != count {
+			err = fmt.Errorf("unexpected count of parameter blocks items: %d, expected: %d", _count, count)
+			errorLog("%s", err)
+			return err
+		}
+	}
+	for i := 0; i < count; i++ {
+		methodParameters[i] = new(DlmsData)
+		err = methodParameters[i].Decode(transfer.rawData)
+		if nil != err {
+			return err
+		}
+	}
+	return conn.actionResponse(invokeIdAndPriority, transfer.classIds, transfer.instanceIds, transfer.methodIds, methodParameters)
+}
+
+func (conn *tCosemServerConnection) actionResponseNextBlock(invokeIdAndPriority tDlmsInvokeIdAndPriority, r io.Reader) (err error) {
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	err, blockNumber := decode_ActionRequestNextPblock(r)
+	if nil != err {
+		return err
+	}
+
+	var buf bytes.Buffer
+	lastBlock, rawData, ok := conn.nextReplyBlock(invokeId, blockNumber)
+	if !ok {
+		debugLog("no such block: invokeId: %d, blockNumber: %d", invokeId, blockNumber+1)
+		err = encode_ActionResponseNormal(&buf, actionResult_noLongActionInProgress, nil, nil)
+		if nil != err {
+			return err
+		}
+		return conn.sendReply(0xC7, 0x01, invokeIdAndPriority, buf.Bytes())
+	}
+	err = encode_ActionResponseWithPblock(&buf, lastBlock, blockNumber+1, rawData)
+	if nil != err {
+		return err
+	}
+	return conn.sendReply(0xC7, 0x02, invokeIdAndPriority, buf.Bytes())
+}
+
+func newCosemServerBlockTransfer(count int) (transfer *tCosemServerBlockTransfer) {
+	transfer = new(tCosemServerBlockTransfer)
+	transfer.classIds = make([]DlmsClassId, count)
+	transfer.instanceIds = make([]*DlmsOid, count)
+	transfer.attributeIds = make([]DlmsAttributeId, count)
+	transfer.methodIds = make([]DlmsMethodId, count)
+	transfer.accessSelectors = make([]DlmsAccessSelector, count)
+	transfer.accessParameters = make([]*DlmsData, count)
+	transfer.rawData = new(bytes.Buffer)
+	return transfer
+}
+
+func (conn *tCosemServerConnection) replyToRequest(pdu []byte) (err error) {
+	if (len(pdu) > 0) && (0x60 == pdu[0]) {
+		debugLog("AARQ")
+		return conn.acceptApp(pdu)
+	}
+	if (len(pdu) > 0) && (0x62 == pdu[0]) {
+		debugLog("RLRQ")
+		return conn.releaseApp(pdu)
+	}
+	if !conn.associated {
+		warnLog("received request outside of association")
+		return conn.sendExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED, EXCEPTION_SERVICE_ERROR_OPERATION_NOT_POSSIBLE)
+	}
+	if (len(pdu) > 0) && ((0x05 == pdu[0]) || (0x06 == pdu[0]) || (0x16 == pdu[0])) {
+		if !conn.shortNames {
+			warnLog("received SN request in LN association")
+			return conn.sendExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_UNKNOWN, EXCEPTION_SERVICE_ERROR_SERVICE_NOT_SUPPORTED)
+		}
+		return conn.replyToSNRequest(pdu)
+	}
+
+	r := bytes.NewBuffer(pdu)
+
+	p := make([]byte, 3)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		warnLog("binary.Read() failed: %v", err)
+		return conn.sendExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_UNKNOWN, EXCEPTION_SERVICE_ERROR_OTHER_REASON)
+	}
+
+	invokeIdAndPriority := tDlmsInvokeIdAndPriority(p[2])
+	invokeId := uint8((invokeIdAndPriority & 0xF0) >> 4)
+
+	if (0xC0 == p[0]) && (0x01 == p[1]) {
+		debugLog("GetRequestNormal")
+		return conn.getResponseNormal(invokeIdAndPriority, r)
+
+	} else if (0xC0 == p[0]) && (0x03 == p[1]) {
+		debugLog("GetRequestWithList")
+		return conn.getResponseWithList(invokeIdAndPriority, r)
+
+	} else if (0xC0 == p[0]) && (0x02 == p[1]) {
+		debugLog("GetRequestForNextDataBlock")
+		return conn.getResponseNextBlock(invokeIdAndPriority, r)
+
+	} else if (0xC1 == p[0]) && (0x01 == p[1]) {
+		debugLog("SetRequestNormal")
+		return conn.setResponseNormal(invokeIdAndPriority, r)
+
+	} else if (0xC1 == p[0]) && (0x04 == p[1]) {
+		debugLog("SetRequestWithList")
+		return conn.setResponseWithList(invokeIdAndPriority, r)
+
+	} else if (0xC1 == p[0]) && (0x02 == p[1]) {
+		debugLog("SetRequestNormalBlock")
+
+		err, classId, instanceId, attributeId, accessSelector, accessParameters, lastBlock, blockNumber, rawData := decode_SetRequestNormalBlock(r)
+		if nil != err {
+			return err
+		}
+		transfer := newCosemServerBlockTransfer(1)
+		transfer.classIds[0] = classId
+		transfer.instanceIds[0] = instanceId
+		transfer.attributeIds[0] = attributeId
+		transfer.accessSelectors[0] = accessSelector
+		transfer.accessParameters[0] = accessParameters
+		transfer.blockNumber = blockNumber - 1
+		conn.setBlocks[invokeId] = transfer
+		return conn.setResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else if (0xC1 == p[0]) && (0x05 == p[1]) {
+		debugLog("SetRequestWithListBlock")
+
+		err, classIds, instanceIds, attributeIds, accessSelectors, accessParameters, lastBlock, blockNumber, rawData := decode_SetRequestWithListBlock(r)
+		if nil != err {
+			return err
+		}
+		transfer := newCosemServerBlockTransfer(0)
+		transfer.classIds = classIds
+		transfer.instanceIds = instanceIds
+		transfer.attributeIds = attributeIds
+		transfer.accessSelectors = accessSelectors
+		transfer.accessParameters = accessParameters
+		transfer.blockNumber = blockNumber - 1
+		conn.setBlocks[invokeId] = transfer
+		return conn.setResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else if (0xC1 == p[0]) && (0x03 == p[1]) {
+		debugLog("SetRequestWithDataBlock")
+
+		err, lastBlock, blockNumber, rawData := decode_SetRequestWithDataBlock(r)
+		if nil != err {
+			return err
+		}
+		return conn.setResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else if (0xC3 == p[0]) && (0x01 == p[1]) {
+		debugLog("ActionRequestNormal")
+		return conn.actionResponseNormal(invokeIdAndPriority, r)
+
+	} else if (0xC3 == p[0]) && (0x02 == p[1]) {
+		debugLog("ActionRequestNextPblock")
+		return conn.actionResponseNextBlock(invokeIdAndPriority, r)
+
+	} else if (0xC3 == p[0]) && (0x03 == p[1]) {
+		debugLog("ActionRequestWithList")
+		return conn.actionResponseWithList(invokeIdAndPriority, r)
+
+	} else if (0xC3 == p[0]) && (0x04 == p[1]) {
+		debugLog("ActionRequestWithFirstPblock")
+
+		err, classId, instanceId, methodId, lastBlock, blockNumber, rawData := decode_ActionRequestWithFirstPblock(r)
+		if nil != err {
+			return err
+		}
+		transfer := newCosemServerBlockTransfer(1)
+		transfer.classIds[0] = classId
+		transfer.instanceIds[0] = instanceId
+		transfer.methodIds[0] = methodId
+		transfer.blockNumber = blockNumber - 1
+		conn.actBlocks[invokeId] = transfer
+		return conn.actionResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else if (0xC3 == p[0]) && (0x05 == p[1]) {
+		debugLog("ActionRequestWithListAndFirstPblock")
+
+		err, classIds, instanceIds, methodIds, lastBlock, blockNumber, rawData := decode_ActionRequestWithListAndFirstPblock(r)
+		if nil != err {
+			return err
+		}
+		transfer := newCosemServerBlockTransfer(0)
+		transfer.classIds = classIds
+		transfer.instanceIds = instanceIds
+		transfer.methodIds = methodIds
+		transfer.blockNumber = blockNumber - 1
+		conn.actBlocks[invokeId] = transfer
+		return conn.actionResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else if (0xC3 == p[0]) && (0x06 == p[1]) {
+		debugLog("ActionRequestWithPblock")
+
+		err, lastBlock, blockNumber, rawData := decode_ActionRequestWithPblock(r)
+		if nil != err {
+			return err
+		}
+		return conn.actionResponseBlock(invokeIdAndPriority, lastBlock, blockNumber, rawData)
+
+	} else {
+		warnLog("received pdu with unknown tag: % 02X % 02X", p[0], p[1])
+		return conn.sendExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_UNKNOWN, EXCEPTION_SERVICE_ERROR_SERVICE_NOT_SUPPORTED)
+	}
+}
+
+func (conn *tCosemServerConnection) sendExceptionResponse(stateError uint8, serviceError uint8) (err error) {
+	var buf bytes.Buffer
+	_, err = buf.Write([]byte{0xD8})
+	if nil != err {
+		errorLog("buf.Write() failed: %v", err)
+		return err
+	}
+	err = encode_ExceptionResponse(&buf, &ExceptionResponseError{StateError: stateError, ServiceError: serviceError})
+	if nil != err {
+		return err
+	}
+	return conn.send(buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) replyToSNRequest(pdu []byte) (err error) {
+	tag, ok := snFirstChoice(pdu[1:])
+	if !ok {
+		warnLog("received malformed SN request")
+		return conn.sendExceptionResponse(EXCEPTION_STATE_ERROR_SERVICE_UNKNOWN, EXCEPTION_SERVICE_ERROR_OTHER_REASON)
+	}
+	r := bytes.NewReader(pdu[1:])
+
+	if (0x05 == pdu[0]) && (snBlockNumberAccess == tag) {
+		debugLog("ReadRequest block-number-access")
+		return conn.readResponseNextBlock(r)
+
+	} else if 0x05 == pdu[0] {
+		debugLog("ReadRequest")
+		return conn.readResponse(r)
+
+	} else if (0x06 == pdu[0]) && (snWriteDataBlockAccess == tag) {
+		debugLog("WriteRequest write-data-block-access")
+		return conn.writeResponseBlock(r)
+
+	} else if 0x06 == pdu[0] {
+		debugLog("WriteRequest")
+		err, dataAccessResults := conn.write(r)
+		if nil != err {
+			return err
+		}
+		return conn.sendWriteResponse(dataAccessResults)
+
+	} else {
+		debugLog("UnconfirmedWriteRequest")
+		err, _ = conn.write(r)
+		return err
+	}
+}
+
+func (conn *tCosemServerConnection) readResponse(r io.Reader) (err error) {
+	err, variableNames, accessSelectors, accessParameters := decode_ReadRequest(r)
+	if nil != err {
+		return err
+	}
+
+	dataAccessResults := make([]DlmsDataAccessResult, len(variableNames))
+	datas := make([]*DlmsData, len(variableNames))
+	for i, variableName := range variableNames {
+		obj, attributeId, dataAccessResult := conn.srv.lookupShortName(variableName)
+		if nil == obj {
+			dataAccessResults[i] = dataAccessResult
+			continue
+		}
+		dataAccessResults[i], datas[i] = obj.getData(attributeId, accessSelectors[i], accessParameters[i])
+	}
+
+	var buf bytes.Buffer
+	err = encode_ReadResponse(&buf, dataAccessResults, datas)
+	if nil != err {
+		return err
+	}
+
+	delete(conn.replyBlocks, snReplyBlocksKey)
+	blocks := conn.splitReply(snReplyBlocksKey, buf.Bytes())
+	if nil == blocks {
+		return conn.sendSNReply(0x0C, buf.Bytes())
+	}
+	debugLog("outbound block transfer, blocks count: %d", len(blocks))
+
+	var _buf bytes.Buffer
+	err = encode_ReadResponseWithDataBlock(&_buf, false, 1, blocks[0])
+	if nil != err {
+		return err
+	}
+	return conn.sendSNReply(0x0C, _buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) readResponseNextBlock(r io.Reader) (err error) {
+	err, blockNumber := decode_ReadRequestBlockNumber(r)
+	if nil != err {
+		return err
+	}
+
+	lastBlock, rawData, ok := conn.nextReplyBlock(snReplyBlocksKey, uint32(blockNumber))
+	if !ok {
+		warnLog("no reply block following block %d", blockNumber)
+		var buf bytes.Buffer
+		err = encode_ReadResponse(&buf, []DlmsDataAccessResult{dataAccessResult_noLongGetInProgress}, []*DlmsData{nil})
+		if nil != err {
+			return err
+		}
+		return conn.sendSNReply(0x0C, buf.Bytes())
+	}
+
+	var buf bytes.Buffer
+	err = encode_ReadResponseWithDataBlock(&buf, lastBlock, blockNumber+1, rawData)
+	if nil != err {
+		return err
+	}
+	return conn.sendSNReply(0x0C, buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) write(r io.Reader) (err error, dataAccessResults []DlmsDataAccessResult) {
+	err, variableNames, accessSelectors, accessParameters, datas := decode_WriteRequest(r)
+	if nil != err {
+		return err, nil
+	}
+
+	dataAccessResults = make([]DlmsDataAccessResult, len(variableNames))
+	for i, variableName := range variableNames {
+		obj, attributeId, dataAccessResult := conn.srv.lookupShortName(variableName)
+		if nil == obj {
+			dataAccessResults[i] = dataAccessResult
+			continue
+		}
+		dataAccessResults[i] = obj.setData(attributeId, accessSelectors[i], accessParameters[i], datas[i])
+	}
+	return nil, dataAccessResults
+}
+
+func (conn *tCosemServerConnection) writeResponseBlock(r io.Reader) (err error) {
+	err, lastBlock, blockNumber, rawData := decode_WriteRequestBlock(r)
+	if nil != err {
+		return err
+	}
+
+	if 1 == blockNumber {
+		conn.snWriteBlocks = new(bytes.Buffer)
+		conn.snWriteBlockNumber = 0
+	}
+	if (nil == conn.snWriteBlocks) || (conn.snWriteBlockNumber+1 != blockNumber) {
+		warnLog("unexpected write block number: %d", blockNumber)
+		conn.snWriteBlocks = nil
+		return conn.sendWriteResponse([]DlmsDataAccessResult{dataAccessResult_dataBlockNumberInvalid})
+	}
+	conn.snWriteBlocks.Write(rawData)
+	conn.snWriteBlockNumber = blockNumber
+
+	if !lastBlock {
+		var buf bytes.Buffer
+		err = encode_WriteResponseBlockNumber(&buf, blockNumber)
+		if nil != err {
+			return err
+		}
+		return conn.sendSNReply(0x0D, buf.Bytes())
+	}
+
+	debugLog("blocks received, processing WriteRequest")
+	_r := conn.snWriteBlocks
+	conn.snWriteBlocks = nil
+	err, dataAccessResults := conn.write(_r)
+	if nil != err {
+		return conn.sendWriteResponse([]DlmsDataAccessResult{dataAccessResult_otherReason})
+	}
+	return conn.sendWriteResponse(dataAccessResults)
+}
+
+func (conn *tCosemServerConnection) sendWriteResponse(dataAccessResults []DlmsDataAccessResult) (err error) {
+	var buf bytes.Buffer
+	err = encode_WriteResponse(&buf, dataAccessResults)
+	if nil != err {
+		return err
+	}
+	return conn.sendSNReply(0x0D, buf.Bytes())
+}
+
+func (conn *tCosemServerConnection) sendSNReply(tag byte, reply []byte) (err error) {
+	var buf bytes.Buffer
+	err = buf.WriteByte(tag)
+	if nil != err {
+		errorLog("buf.WriteByte() failed: %v", err)
+		return err
+	}
+	_, err = buf.Write(reply)
+	if nil != err {
+		errorLog("buf.Write() failed: %v", err)
+		return err
+	}
+	return conn.send(buf.Bytes())
+}
@@ -0,0 +1,696 @@
+package gocosem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startCosemServer(t *testing.T) (srv *CosemServer, port int) {
+	srv = NewCosemServer()
+	srv.Password = "12345678"
+	ln, err := srv.ListenTcp("localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	return srv, ln.Addr().(*net.TCPAddr).Port
+}
+
+func connectCosemServer(t *testing.T, port int) (dconn *DlmsConn, aconn *AppConn) {
+	dconn, err := TcpConnect("localhost", port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn, err = dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		dconn.Close()
+		t.Fatalf("%s\n", err)
+	}
+	return dconn, aconn
+}
+
+func TestServer_AppConnect_wrongPassword(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	dconn, err := TcpConnect("localhost", port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	_, err = dconn.AppConnectWithPassword(01, 01, 0, "87654321")
+	if nil == err {
+		t.Fatalf("association with wrong password succeeded")
+	}
+}
+
+// Returns whether general block transfer was negotiated.
+func negotiateGbt(t *testing.T, port int, proposeGbt bool) bool {
+	dconn, err := TcpConnect("localhost", port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+	dconn.ProposeGbt = proposeGbt
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer aconn.Close()
+	return aconn.gbt
+}
+
+func TestServer_AppConnect_generalBlockTransfer(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	if negotiateGbt(t, port, true) {
+		t.Fatalf("general block transfer negotiated with server not supporting it")
+	}
+
+	srv = NewCosemServer()
+	srv.Password = "12345678"
+	srv.conformance = []byte{cosemServerConformance[0] | 0x20, cosemServerConformance[1], cosemServerConformance[2]}
+	ln, err := srv.ListenTcp("localhost:0")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer srv.Close()
+	port = ln.Addr().(*net.TCPAddr).Port
+
+	if negotiateGbt(t, port, false) {
+		t.Fatalf("general block transfer negotiated without being proposed")
+	}
+	if !negotiateGbt(t, port, true) {
+		t.Fatalf("general block transfer not negotiated")
+	}
+}
+
+func TestServer_GetRequestNormal(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	vals := []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 1},
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 3},
+		&DlmsRequest{ClassId: 3, InstanceId: instanceId, AttributeId: 2},
+	}
+	for i, val := range vals {
+		rep, err := aconn.SendRequest([]*DlmsRequest{val})
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		switch i {
+		case 0:
+			if !bytes.Equal(instanceId[:], rep.DataAt(0).GetOctetString()) {
+				t.Fatalf("logical name differs")
+			}
+		case 1:
+			if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+				t.Fatalf("value differs")
+			}
+		case 2:
+			if dataAccessResult_objectUndefined != rep.DataAccessResultAt(0) {
+				t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+			}
+		case 3:
+			if dataAccessResult_objectClassInconsistent != rep.DataAccessResultAt(0) {
+				t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+			}
+		}
+	}
+}
+
+func TestServer_selectiveAccess(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	type access struct {
+		selector   DlmsAccessSelector
+		parameters *DlmsData
+	}
+	ch := make(chan access, 10)
+
+	instanceId := &DlmsOid{0x01, 0x00, 0x63, 0x01, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03})
+	obj := srv.AddObject(7, instanceId)
+	obj.SetAttributeGetter(2, func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (DlmsDataAccessResult, *DlmsData) {
+		ch <- access{accessSelector, accessParameters}
+		return dataAccessResult_success, data
+	})
+	obj.SetAttributeSetter(2, func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData, data *DlmsData) DlmsDataAccessResult {
+		ch <- access{accessSelector, accessParameters}
+		return dataAccessResult_success
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	parameters := new(DlmsData)
+	parameters.SetDoubleLongUnsigned(5)
+	for _, val := range []*DlmsRequest{
+		&DlmsRequest{ClassId: 7, InstanceId: instanceId, AttributeId: 2, AccessSelector: 2, AccessParameter: parameters},
+		&DlmsRequest{ClassId: 7, InstanceId: instanceId, AttributeId: 2, AccessSelector: 2, AccessParameter: parameters, Data: data},
+	} {
+		rep, err := aconn.SendRequest([]*DlmsRequest{val})
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if dataAccessResult_success != rep.DataAccessResultAt(0) {
+			t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+		}
+		a := <-ch
+		if (2 != a.selector) || (nil == a.parameters) || (5 != a.parameters.GetDoubleLongUnsigned()) {
+			t.Fatalf("access selection not received: %d, %v", a.selector, a.parameters)
+		}
+	}
+}
+
+func TestServer_GetRequestWithList_blockTransfer(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+	srv.BlockLength = 10
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data1 := new(DlmsData)
+	data1.SetOctetString(generateBytes(100))
+	data2 := new(DlmsData)
+	data2.SetOctetString(generateBytes(33))
+	obj := srv.AddObject(1, instanceId)
+	obj.SetAttribute(2, data1)
+	obj.SetAttribute(3, data2)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data1.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	rep, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 3},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data1.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+	if !bytes.Equal(data2.GetOctetString(), rep.DataAt(1).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_SetRequest_blockTransfer(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	obj := srv.AddObject(1, instanceId)
+	obj.SetAttribute(2, new(DlmsData))
+	obj.SetAttribute(3, new(DlmsData))
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	data1 := new(DlmsData)
+	data1.SetOctetString(generateBytes(100))
+	data2 := new(DlmsData)
+	data2.SetOctetString(generateBytes(33))
+
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2, Data: data1, BlockSize: 7},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0 != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+	if !bytes.Equal(data1.GetOctetString(), obj.GetAttribute(2).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	rep, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2, Data: data2, BlockSize: 7},
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 3, Data: data1},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (0 != rep.DataAccessResultAt(0)) || (0 != rep.DataAccessResultAt(1)) {
+		t.Fatalf("dataAccessResult: %d, %d\n", rep.DataAccessResultAt(0), rep.DataAccessResultAt(1))
+	}
+	if !bytes.Equal(data2.GetOctetString(), obj.GetAttribute(2).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+	if !bytes.Equal(data1.GetOctetString(), obj.GetAttribute(3).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_ActionRequestNormal(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	// disconnect control
+	instanceId := &DlmsOid{0x00, 0x00, 0x60, 0x03, 0x0A, 0xFF}
+	obj := srv.AddObject(70, instanceId)
+	state := new(DlmsData)
+	state.SetEnum(1)
+	obj.SetAttribute(3, state)
+	obj.SetMethod(1, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if (nil == methodParameters) || (DATA_TYPE_INTEGER != methodParameters.GetType()) {
+			return actionResult_typeUnmatched, nil, nil
+		}
+		state := new(DlmsData)
+		state.SetEnum(0)
+		obj.SetAttribute(3, state)
+		return actionResult_success, nil, nil
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	methodParameters := new(DlmsData)
+	methodParameters.SetInteger(0)
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 70, InstanceId: instanceId, MethodId: 1, MethodParameters: methodParameters},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if actionResult_success != rep.ActionResultAt(0) {
+		t.Fatalf("actionResult: %d\n", rep.ActionResultAt(0))
+	}
+	if 0 != obj.GetAttribute(3).GetEnum() {
+		t.Fatalf("state not changed")
+	}
+
+	rep, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 70, InstanceId: instanceId, MethodId: 2, MethodParameters: methodParameters},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if actionResult_objectUndefined != rep.ActionResultAt(0) {
+		t.Fatalf("actionResult: %d\n", rep.ActionResultAt(0))
+	}
+}
+
+func TestServer_ActionRequestWithPblock(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+	srv.BlockLength = 10
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}
+	obj := srv.AddObject(18, instanceId)
+	obj.SetMethod(2, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		if (nil == methodParameters) || (DATA_TYPE_OCTET_STRING != methodParameters.GetType()) {
+			return actionResult_typeUnmatched, nil, nil
+		}
+		dataAccessResult := DlmsDataAccessResult(dataAccessResult_success)
+		data := new(DlmsData)
+		data.SetOctetString(methodParameters.GetOctetString())
+		return actionResult_success, &dataAccessResult, data
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	methodParameters := new(DlmsData)
+	methodParameters.SetOctetString(generateBytes(100))
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 18, InstanceId: instanceId, MethodId: 2, MethodParameters: methodParameters, BlockSize: 16},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if actionResult_success != rep.ActionResultAt(0) {
+		t.Fatalf("actionResult: %d\n", rep.ActionResultAt(0))
+	}
+	if !bytes.Equal(methodParameters.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_ActionRequestWithList(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}
+	obj := srv.AddObject(18, instanceId)
+	calls := 0
+	obj.SetMethod(1, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		calls += 1
+		return actionResult_success, nil, nil
+	})
+	obj.SetMethod(2, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		dataAccessResult := DlmsDataAccessResult(dataAccessResult_success)
+		data := new(DlmsData)
+		data.SetOctetString(methodParameters.GetOctetString())
+		return actionResult_success, &dataAccessResult, data
+	})
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	methodParameters := new(DlmsData)
+	methodParameters.SetOctetString(generateBytes(50))
+
+	for _, blockSize := range []int{0, 16} {
+		srv.BlockLength = blockSize
+		rep, err := aconn.SendRequest([]*DlmsRequest{
+			&DlmsRequest{ClassId: 18, InstanceId: instanceId, MethodId: 1, BlockSize: blockSize},
+			&DlmsRequest{ClassId: 18, InstanceId: instanceId, MethodId: 2, MethodParameters: methodParameters},
+			&DlmsRequest{ClassId: 18, InstanceId: instanceId, MethodId: 3},
+		})
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+		if (actionResult_success != rep.ActionResultAt(0)) || (actionResult_success != rep.ActionResultAt(1)) || (actionResult_objectUndefined != rep.ActionResultAt(2)) {
+			t.Fatalf("actionResult: %d, %d, %d\n", rep.ActionResultAt(0), rep.ActionResultAt(1), rep.ActionResultAt(2))
+		}
+		if !bytes.Equal(methodParameters.GetOctetString(), rep.DataAt(1).GetOctetString()) {
+			t.Fatalf("value differs")
+		}
+	}
+	if 2 != calls {
+		t.Fatalf("method called %d times", calls)
+	}
+}
+
+func TestServer_Hdlc_GetRequestNormal(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+	ln, err := srv.ListenHdlc("localhost:0", 1, 1, nil, nil)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(1000))
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	dconn, err := HdlcConnect("localhost", port, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_Hdlc_linkReset(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+	ln, err := srv.ListenHdlc("localhost:0", 1, 1, nil, nil)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	// this read frame implementation corrupts N(R) of every 5th I frame
+	dconn, err := hdlcConnectRW(context.Background(), conn, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout, 4)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	vals := []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	}
+	for i := 1; i <= 3; i++ {
+		_, err = aconn.SendRequest(vals)
+		if nil != err {
+			t.Fatalf("%s\n", err)
+		}
+	}
+
+	// AARE was 1st I frame, reply to 4th request is lost with link reset, association must be established again
+	for i := 0; i < 2; i++ {
+		_, err = aconn.SendRequest(vals)
+		if _, ok := err.(*HdlcLinkResetError); !ok {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	aconn, err = dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	rep, err := aconn.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_Hdlc_maxReceivePduSize(t *testing.T) {
+	srv := NewCosemServer()
+	defer srv.Close()
+	srv.MaxReceivePduSize = 100
+	ln, err := srv.ListenHdlc("localhost:0", 1, 1, nil, nil)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	obj := srv.AddObject(1, instanceId)
+	obj.SetAttribute(2, new(DlmsData))
+	scriptId := &DlmsOid{0x00, 0x00, 0x2C, 0x00, 0x00, 0xFF}
+	srv.AddObject(18, scriptId).SetMethod(2, func(obj *CosemObject, methodParameters *DlmsData) (DlmsActionResult, *DlmsDataAccessResult, *DlmsData) {
+		dataAccessResult := DlmsDataAccessResult(dataAccessResult_success)
+		data := new(DlmsData)
+		data.SetOctetString(methodParameters.GetOctetString())
+		return actionResult_success, &dataAccessResult, data
+	})
+
+	dconn, err := HdlcConnect("localhost", port, 1, 1, nil, nil, testHdlcResponseTimeout, &testHdlcCosemWaitTime, testHdlcSnrmTimeout, testHdlcDiscTimeout)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 100 != aconn.serverMaxReceivePduSize {
+		t.Fatalf("wrong server max receive pdu size: %d", aconn.serverMaxReceivePduSize)
+	}
+
+	// requests exceeding server max receive pdu size are sent in blocks, reply exceeding client max receive pdu size is received in blocks
+
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(2000))
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2, Data: data},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 0 != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+	if !bytes.Equal(data.GetOctetString(), obj.GetAttribute(2).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	rep, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 18, InstanceId: scriptId, MethodId: 2, MethodParameters: data},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if actionResult_success != rep.ActionResultAt(0) {
+		t.Fatalf("actionResult: %d\n", rep.ActionResultAt(0))
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	rep, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestServer_Release(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	initiateRequest := new(DlmsInitiateRequest)
+	initiateRequest.responseAllowed = true
+	initiateRequest.proposedDlmsVersionNumber = 6
+	initiateRequest.proposedConformance.buf = []byte{0x00, 0x1E, 0x1D}
+	initiateRequest.clientMaxReceivePduSize = 0xFFFF
+	initiateResponse, err := aconn.Release(initiateRequest)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (nil == initiateResponse) || (6 != initiateResponse.negotiatedDlmsVersionNumber) {
+		t.Fatalf("initiateResponse missing")
+	}
+
+	// second association over the same transport
+	aconn, err = dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	_, err = aconn.Release(nil)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	_, err = aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	})
+	var e *ExceptionResponseError
+	if !errors.As(err, &e) || (EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED != e.StateError) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestServer_Close_outstanding(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	addSlowObject(srv, instanceId, time.Duration(2)*time.Second)
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		_, err := aconn.SendRequest([]*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+		})
+		ch <- err
+	}()
+	time.Sleep(time.Duration(100) * time.Millisecond)
+
+	// association is not released while request is outstanding, transport is closed right away
+	start := time.Now()
+	aconn.Close()
+	if time.Since(start) > time.Second {
+		t.Fatalf("close waited for outstanding request: %v", time.Since(start))
+	}
+	if nil == <-ch {
+		t.Fatalf("outstanding request succeeded on closed connection")
+	}
+}
+
+func TestServer_SendRequest_concurrent(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	for i := 0; i < 8; i++ {
+		data := new(DlmsData)
+		data.SetUnsigned(uint8(i))
+		srv.AddObject(1, &DlmsOid{0x00, 0x00, 0x2A, byte(i), 0x00, 0xFF}).SetAttribute(2, data)
+	}
+
+	dconn, aconn := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i uint8) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				rep, err := aconn.SendRequest([]*DlmsRequest{
+					&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, i, 0x00, 0xFF}, AttributeId: 2},
+				})
+				if nil != err {
+					t.Errorf("%s\n", err)
+					return
+				}
+				if i != rep.DataAt(0).GetUnsigned() {
+					t.Errorf("value differs: %d, expected: %d", rep.DataAt(0).GetUnsigned(), i)
+					return
+				}
+			}
+		}(uint8(i))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,320 @@
+package gocosem
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrSessionClosed = errors.New("session closed")
+
+// Establishes transport connection, see TcpSessionDialer() and HdlcSessionDialer().
+type SessionDialer func(ctx context.Context) (dconn *DlmsConn, err error)
+
+func TcpSessionDialer(ipAddr string, port int) SessionDialer {
+	return func(ctx context.Context) (*DlmsConn, error) {
+		return TcpConnectContext(ctx, ipAddr, port)
+	}
+}
+
+func HdlcSessionDialer(ipAddr string, port int, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) SessionDialer {
+	return func(ctx context.Context) (*DlmsConn, error) {
+		return HdlcConnectContext(ctx, ipAddr, port, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+	}
+}
+
+/*
+Session keeps association with meter established. Transport connection and
+association are set up on first request and set up again whenever transport
+connection breaks (e.g. HDLC inactivity timeout or TCP drop) or meter rejects
+request because association is gone.
+
+Failed GET requests are retried on new association. SET and ACTION requests are
+not repeated unless 'RetryAll' is set, their error is returned to the caller
+and only next request is sent on new association.
+
+Session is safe for concurrent use. Only one request at a time connects,
+requests waiting for it are not interrupted by their context until connecting
+completes. Close() interrupts connecting, including wait between attempts.
+*/
+type Session struct {
+	Retries           int           // How many times failed request is retried on new association.
+	RetryAll          bool          // Retry also SET and ACTION requests, caller must make sure repeating them is harmless.
+	ReconnectAttempts int           // Number of connection attempts before request fails (1 if 0).
+	Backoff           time.Duration // Wait time after first failed connection attempt, it doubles after every next failed attempt.
+	MaxBackoff        time.Duration // Limit of wait time between connection attempts.
+	MaxOutstanding    int           // Passed to AppConn of every association.
+
+	dial             SessionDialer
+	associate        func(dconn *DlmsConn) (aconn *AppConn, err error)
+	mtx              sync.Mutex
+	cond             *sync.Cond // signalled when connecting finishes
+	dconn            *DlmsConn
+	aconn            *AppConn
+	sendFrameCounter uint32 // last frame counter used by ciphered association
+	connecting       bool   // request is connecting, s.mtx is not held meanwhile
+	closed           bool
+	closedCh         chan bool // closed by Close(), interrupts connecting
+}
+
+func newSession(dial SessionDialer) *Session {
+	s := new(Session)
+	s.Retries = 2
+	s.ReconnectAttempts = 3
+	s.Backoff = time.Second
+	s.MaxBackoff = time.Second * 30
+	s.dial = dial
+	s.cond = sync.NewCond(&s.mtx)
+	s.closedCh = make(chan bool)
+	return s
+}
+
+// Creates session associating using low level security, see AppConnectWithPassword().
+func NewSessionWithPassword(dial SessionDialer, applicationClient uint16, logicalDevice uint16, invokeId uint8, password string) *Session {
+	s := newSession(dial)
+	s.associate = func(dconn *DlmsConn) (*AppConn, error) {
+		return dconn.AppConnectWithPassword(applicationClient, logicalDevice, invokeId, password)
+	}
+	return s
+}
+
+/*
+Creates session associating using high level security (GMAC), see
+AppConnectWithSecurity5(). Frame counter continues across associations
+starting at 'sendFrameCounter', its current value is returned by
+SendFrameCounter() so that it may be persisted.
+*/
+func NewSessionWithSecurity5(dial SessionDialer, applicationClient uint16, logicalDevice uint16, invokeId uint8, authenticationKey []byte, encryptionKey []byte, applicationContextName []uint32, callingAPtitle []byte, clientToServerChallenge string, initiateRequest *DlmsInitiateRequest, sendFrameCounter uint32) *Session {
+	s := newSession(dial)
+	s.sendFrameCounter = sendFrameCounter
+	s.associate = func(dconn *DlmsConn) (aconn *AppConn, err error) {
+		s.mtx.Lock()
+		sendFrameCounter := s.sendFrameCounter
+		s.mtx.Unlock()
+		aconn, _, err = dconn.AppConnectWithSecurity5(applicationClient, logicalDevice, invokeId, authenticationKey, encryptionKey, applicationContextName, callingAPtitle, clientToServerChallenge, initiateRequest, sendFrameCounter)
+		// counter used by AARQ must not be used again even if association failed
+		s.mtx.Lock()
+		if dconn.sendFrameCounter > s.sendFrameCounter {
+			s.sendFrameCounter = dconn.sendFrameCounter
+		}
+		s.mtx.Unlock()
+		return aconn, err
+	}
+	return s
+}
+
+// Returns last frame counter used by ciphered association.
+func (s *Session) SendFrameCounter() uint32 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.saveFrameCounter()
+	return s.sendFrameCounter
+}
+
+func (s *Session) saveFrameCounter() {
+	if nil == s.aconn {
+		return
+	}
+	// frame counter is incremented by sends, these are serialized by AppConn
+	s.aconn.sendMtx.Lock()
+	fc := s.dconn.sendFrameCounter
+	s.aconn.sendMtx.Unlock()
+	if fc > s.sendFrameCounter {
+		s.sendFrameCounter = fc
+	}
+}
+
+func (s *Session) connect(ctx context.Context) (err error, dconn *DlmsConn, aconn *AppConn) {
+	dconn, err = s.dial(ctx)
+	if nil != err {
+		return err, nil, nil
+	}
+	err = dconn.doContext(ctx, func() (err error) {
+		aconn, err = s.associate(dconn)
+		return err
+	})
+	if nil != err {
+		dconn.Close()
+		return err, nil, nil
+	}
+	aconn.MaxOutstanding = s.MaxOutstanding
+	debugLog("session connected")
+	return nil, dconn, aconn
+}
+
+// Connects repeatedly until it succeeds or attempts are exhausted. Called without holding s.mtx, connecting is aborted by Close().
+func (s *Session) reconnect(ctx context.Context) (err error, dconn *DlmsConn, aconn *AppConn) {
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.closedCh:
+			cancel()
+		case <-sctx.Done():
+		}
+	}()
+
+	backoff := s.Backoff
+	for attempt := 1; ; attempt++ {
+		err, dconn, aconn = s.connect(sctx)
+		if nil == err {
+			return nil, dconn, aconn
+		}
+		if (nil != sctx.Err()) || (attempt >= s.ReconnectAttempts) {
+			errorLog("session: connecting failed, attempts: %d: %v", attempt, err)
+			return err, nil, nil
+		}
+		warnLog("session: connecting failed: %v, next attempt in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-sctx.Done():
+			return sctx.Err(), nil, nil
+		}
+		backoff *= 2
+		if (s.MaxBackoff > 0) && (backoff > s.MaxBackoff) {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// Returns current association, connects if there is none.
+func (s *Session) appConn(ctx context.Context) (aconn *AppConn, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for s.connecting {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	if nil != s.aconn {
+		return s.aconn, nil
+	}
+
+	s.connecting = true
+	s.mtx.Unlock()
+	err, dconn, aconn := s.reconnect(ctx)
+	s.mtx.Lock()
+	s.connecting = false
+	s.cond.Broadcast()
+
+	if s.closed {
+		if nil != aconn {
+			aconn.Close()
+		}
+		return nil, ErrSessionClosed
+	}
+	if nil != err {
+		return nil, err
+	}
+	s.dconn = dconn
+	s.aconn = aconn
+	return aconn, nil
+}
+
+// Returns true if request error means that transport connection or association is lost.
+func isSessionLost(err error) bool {
+	switch e := err.(type) {
+	case *ExceptionResponseError:
+		return (EXCEPTION_STATE_ERROR_SERVICE_NOT_ALLOWED == e.StateError) || (EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR == e.ServiceError)
+	case *ConfirmedServiceError:
+		return false
+	}
+	return true
+}
+
+// Drops association 'aconn' after request on it failed with 'err', next request connects again.
+func (s *Session) drop(aconn *AppConn, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if aconn != s.aconn {
+		// already dropped by another request
+		return
+	}
+	warnLog("session: dropping association: %v", err)
+	if e, ok := err.(*ExceptionResponseError); ok {
+		// transport is alive, meter just lost association
+		s.dconn.Close()
+		if (EXCEPTION_SERVICE_ERROR_INVOCATION_COUNTER_ERROR == e.ServiceError) && (e.InvocationCounter > s.sendFrameCounter) {
+			s.sendFrameCounter = e.InvocationCounter
+		}
+	} else {
+		s.dconn.abort()
+	}
+	s.saveFrameCounter()
+	s.dconn = nil
+	s.aconn = nil
+}
+
+// Returns true if all requests are GET requests.
+func isIdempotent(vals []*DlmsRequest) bool {
+	for _, req := range vals {
+		if (0 == req.AttributeId) || (nil != req.Data) {
+			return false
+		}
+	}
+	return true
+}
+
+// Connects transport and associates unless it is already done. Calling it is optional, requests connect on demand.
+func (s *Session) Connect(ctx context.Context) (err error) {
+	_, err = s.appConn(ctx)
+	return err
+}
+
+func (s *Session) SendRequest(vals []*DlmsRequest) (response DlmsResultResponse, err error) {
+	return s.SendRequestContext(context.Background(), vals)
+}
+
+func (s *Session) SendRequestContext(ctx context.Context, vals []*DlmsRequest) (response DlmsResultResponse, err error) {
+	retry := s.RetryAll || isIdempotent(vals)
+	for attempt := 0; ; attempt++ {
+		aconn, err := s.appConn(ctx)
+		if nil != err {
+			return nil, err
+		}
+		response, err = aconn.SendRequestContext(ctx, vals)
+		if nil == err {
+			return response, nil
+		}
+		if nil != ctx.Err() {
+			// transport is aborted only if no other request was outstanding
+			if aconn.dconn.isClosed() {
+				s.drop(aconn, err)
+			}
+			return nil, err
+		}
+		if !isSessionLost(err) {
+			return nil, err
+		}
+		s.drop(aconn, err)
+		if !retry || (attempt >= s.Retries) {
+			return nil, err
+		}
+		warnLog("session: request failed: %v, retrying on new association", err)
+	}
+}
+
+// Releases association and closes transport connection. Request connecting meanwhile is interrupted and fails with ErrSessionClosed.
+func (s *Session) Close() {
+	s.mtx.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.closedCh)
+	}
+	aconn := s.aconn
+	if nil != aconn {
+		s.saveFrameCounter()
+		s.dconn = nil
+		s.aconn = nil
+	}
+	s.mtx.Unlock()
+
+	if nil != aconn {
+		aconn.Close()
+	}
+}
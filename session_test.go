@@ -0,0 +1,177 @@
+package gocosem
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestSession(port int) *Session {
+	s := NewSessionWithPassword(TcpSessionDialer("localhost", port), 01, 01, 0, "12345678")
+	s.Backoff = time.Duration(10) * time.Millisecond
+	return s
+}
+
+func TestSession_reconnect(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetUnsigned(7)
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+
+	s := newTestSession(port)
+	defer s.Close()
+
+	vals := []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2},
+	}
+	_, err := s.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	aconn := s.aconn
+
+	// tcp connection drops
+	s.dconn.rwc.Close()
+
+	rep, err := s.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 7 != rep.DataAt(0).GetUnsigned() {
+		t.Fatalf("value differs")
+	}
+	if aconn == s.aconn {
+		t.Fatalf("session not reconnected")
+	}
+}
+
+func TestSession_SendRequest_setNotRetried(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetUnsigned(7)
+	obj := srv.AddObject(1, instanceId)
+	obj.SetAttribute(2, data)
+
+	s := newTestSession(port)
+	defer s.Close()
+
+	err := s.Connect(context.Background())
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	newData := new(DlmsData)
+	newData.SetUnsigned(8)
+	vals := []*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: instanceId, AttributeId: 2, Data: newData},
+	}
+
+	s.dconn.rwc.Close()
+	_, err = s.SendRequest(vals)
+	if nil == err {
+		t.Fatalf("set request retried")
+	}
+	if 7 != obj.GetAttribute(2).GetUnsigned() {
+		t.Fatalf("value changed")
+	}
+
+	// next request is sent on new association
+	rep, err := s.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if dataAccessResult_success != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d", rep.DataAccessResultAt(0))
+	}
+
+	s.RetryAll = true
+	newData.SetUnsigned(9)
+	s.dconn.rwc.Close()
+	_, err = s.SendRequest(vals)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 9 != obj.GetAttribute(2).GetUnsigned() {
+		t.Fatalf("value not set")
+	}
+}
+
+func TestSession_Connect_backoff(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	errDial := errors.New("dial failed")
+	failures := 2
+	calls := 0
+	dial := TcpSessionDialer("localhost", port)
+	s := NewSessionWithPassword(func(ctx context.Context) (*DlmsConn, error) {
+		calls += 1
+		if calls <= failures {
+			return nil, errDial
+		}
+		return dial(ctx)
+	}, 01, 01, 0, "12345678")
+	s.Backoff = time.Duration(10) * time.Millisecond
+	defer s.Close()
+
+	err := s.Connect(context.Background())
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 3 != calls {
+		t.Fatalf("dial called %d times", calls)
+	}
+
+	s.dconn.rwc.Close()
+	failures = 100
+	calls = 0
+	_, err = s.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 2},
+	})
+	if errDial != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if 3 != calls {
+		t.Fatalf("dial called %d times", calls)
+	}
+}
+
+func TestSession_Close_backoff(t *testing.T) {
+	errDial := errors.New("dial failed")
+	s := NewSessionWithPassword(func(ctx context.Context) (*DlmsConn, error) {
+		return nil, errDial
+	}, 01, 01, 0, "12345678")
+	s.Backoff = time.Duration(10) * time.Second
+
+	ch := make(chan error, 1)
+	go func() {
+		_, err := s.SendRequest([]*DlmsRequest{
+			&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 2},
+		})
+		ch <- err
+	}()
+	time.Sleep(time.Duration(100) * time.Millisecond)
+
+	// session is not locked while request waits for next connection attempt
+	start := time.Now()
+	s.SendFrameCounter()
+	s.Close()
+	select {
+	case err := <-ch:
+		if ErrSessionClosed != err {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("close did not interrupt backoff")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("session locked during backoff: %v", time.Since(start))
+	}
+}
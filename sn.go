@@ -0,0 +1,887 @@
+package gocosem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Short name of COSEM object attribute or method (base_name plus offset).
+type DlmsShortName uint16
+
+// Variable-Access-Specification choice tags.
+const (
+	snVariableName         = 2
+	snParameterizedAccess  = 4
+	snBlockNumberAccess    = 5
+	snWriteDataBlockAccess = 7
+)
+
+// ReadResponse choice tags.
+const (
+	snReadData            = 0
+	snReadDataAccessError = 1
+	snReadDataBlockResult = 2
+)
+
+// WriteResponse choice tags.
+const (
+	snWriteSuccess         = 0
+	snWriteDataAccessError = 1
+	snWriteBlockNumber     = 2
+)
+
+// Proposed conformance block for SN referencing (read, write, unconfirmed write, block transfer with read and write, multiple references, parameterized access).
+var snConformance = []byte{0x1C, 0x1A, 0x20}
+
+// Short name of attribute 'attributeId' of object having 'baseName'. Attributes are spaced by 8.
+func SNAttribute(baseName DlmsShortName, attributeId DlmsAttributeId) DlmsShortName {
+	return baseName + DlmsShortName(attributeId-1)*8
+}
+
+func encode_snVariableAccess(w io.Writer, variableName DlmsShortName, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (err error) {
+	if (0 == accessSelector) || (nil == accessParameters) {
+		err = binary.Write(w, binary.BigEndian, uint8(snVariableName))
+		if nil != err {
+			errorLog("binary.Write() failed, err: %v", err)
+			return err
+		}
+		err = binary.Write(w, binary.BigEndian, variableName)
+		if nil != err {
+			errorLog("binary.Write() failed, err: %v", err)
+			return err
+		}
+		return nil
+	}
+
+	err = binary.Write(w, binary.BigEndian, uint8(snParameterizedAccess))
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, variableName)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, accessSelector)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	return accessParameters.Encode(w)
+}
+
+func decode_snVariableAccess(r io.Reader) (err error, variableName DlmsShortName, accessSelector DlmsAccessSelector, accessParameters *DlmsData) {
+	var tag uint8
+	err = binary.Read(r, binary.BigEndian, &tag)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0, 0, nil
+	}
+	if (snVariableName != tag) && (snParameterizedAccess != tag) {
+		err = fmt.Errorf("unsupported variable access specification: %d", tag)
+		errorLog("%s", err)
+		return err, 0, 0, nil
+	}
+
+	err = binary.Read(r, binary.BigEndian, &variableName)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0, 0, nil
+	}
+	if snVariableName == tag {
+		return nil, variableName, 0, nil
+	}
+
+	err = binary.Read(r, binary.BigEndian, &accessSelector)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, variableName, 0, nil
+	}
+	accessParameters = new(DlmsData)
+	err = accessParameters.Decode(r)
+	if nil != err {
+		return err, variableName, accessSelector, nil
+	}
+	return nil, variableName, accessSelector, accessParameters
+}
+
+func encode_snVariableAccessList(w io.Writer, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData) (err error) {
+	err = encodeAxdrLength(w, uint16(len(variableNames)))
+	if nil != err {
+		return err
+	}
+	for i := 0; i < len(variableNames); i++ {
+		err = encode_snVariableAccess(w, variableNames[i], accessSelectors[i], accessParameters[i])
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func decode_snVariableAccessList(r io.Reader) (err error, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData) {
+	err, count := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil, nil, nil
+	}
+	variableNames = make([]DlmsShortName, count)
+	accessSelectors = make([]DlmsAccessSelector, count)
+	accessParameters = make([]*DlmsData, count)
+	for i := 0; i < int(count); i++ {
+		err, variableNames[i], accessSelectors[i], accessParameters[i] = decode_snVariableAccess(r)
+		if nil != err {
+			return err, variableNames[0:i], accessSelectors[0:i], accessParameters[0:i]
+		}
+	}
+	return nil, variableNames, accessSelectors, accessParameters
+}
+
+func encode_snDataList(w io.Writer, datas []*DlmsData) (err error) {
+	err = encodeAxdrLength(w, uint16(len(datas)))
+	if nil != err {
+		return err
+	}
+	for _, data := range datas {
+		err = data.Encode(w)
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func decode_snDataList(r io.Reader) (err error, datas []*DlmsData) {
+	err, count := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil
+	}
+	datas = make([]*DlmsData, count)
+	for i := 0; i < int(count); i++ {
+		datas[i] = new(DlmsData)
+		err = datas[i].Decode(r)
+		if nil != err {
+			return err, datas[0:i]
+		}
+	}
+	return nil, datas
+}
+
+// Peeks at choice tag of first list element of SN pdu (tag excluded).
+func snFirstChoice(pdu []byte) (tag uint8, ok bool) {
+	r := bytes.NewReader(pdu)
+	err, count := decodeAxdrLength(r)
+	if (nil != err) || (0 == count) {
+		return 0, false
+	}
+	tag, err = r.ReadByte()
+	if nil != err {
+		return 0, false
+	}
+	return tag, true
+}
+
+// ReadRequest with variable-name or parameterized-access (if access selector > 0) elements.
+func encode_ReadRequest(w io.Writer, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData) (err error) {
+	return encode_snVariableAccessList(w, variableNames, accessSelectors, accessParameters)
+}
+
+func decode_ReadRequest(r io.Reader) (err error, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData) {
+	return decode_snVariableAccessList(r)
+}
+
+// ReadRequest with block-number-access requesting block following 'blockNumber'.
+func encode_ReadRequestBlockNumber(w io.Writer, blockNumber uint16) (err error) {
+	_, err = w.Write([]byte{0x01, snBlockNumberAccess})
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumber)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func decode_ReadRequestBlockNumber(r io.Reader) (err error, blockNumber uint16) {
+	p := make([]byte, 2)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0
+	}
+	if (0x01 != p[0]) || (snBlockNumberAccess != p[1]) {
+		err = fmt.Errorf("not a block-number-access: % 02X", p)
+		errorLog("%s", err)
+		return err, 0
+	}
+	err = binary.Read(r, binary.BigEndian, &blockNumber)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0
+	}
+	return nil, blockNumber
+}
+
+func encode_ReadResponse(w io.Writer, dataAccessResults []DlmsDataAccessResult, datas []*DlmsData) (err error) {
+	err = encodeAxdrLength(w, uint16(len(dataAccessResults)))
+	if nil != err {
+		return err
+	}
+	for i := 0; i < len(dataAccessResults); i++ {
+		if dataAccessResult_success == dataAccessResults[i] {
+			err = binary.Write(w, binary.BigEndian, uint8(snReadData))
+			if nil != err {
+				errorLog("binary.Write() failed, err: %v", err)
+				return err
+			}
+			err = datas[i].Encode(w)
+			if nil != err {
+				return err
+			}
+		} else {
+			_, err = w.Write([]byte{snReadDataAccessError, byte(dataAccessResults[i])})
+			if nil != err {
+				errorLog("w.Write() failed, err: %v", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decode_ReadResponse(r io.Reader) (err error, dataAccessResults []DlmsDataAccessResult, datas []*DlmsData) {
+	err, count := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil, nil
+	}
+	dataAccessResults = make([]DlmsDataAccessResult, count)
+	datas = make([]*DlmsData, count)
+	for i := 0; i < int(count); i++ {
+		var tag uint8
+		err = binary.Read(r, binary.BigEndian, &tag)
+		if nil != err {
+			errorLog("binary.Read() failed, err: %v", err)
+			return err, dataAccessResults[0:i], datas[0:i]
+		}
+		switch tag {
+		case snReadData:
+			dataAccessResults[i] = dataAccessResult_success
+			datas[i] = new(DlmsData)
+			err = datas[i].Decode(r)
+			if nil != err {
+				return err, dataAccessResults[0:i], datas[0:i]
+			}
+		case snReadDataAccessError:
+			err = binary.Read(r, binary.BigEndian, &dataAccessResults[i])
+			if nil != err {
+				errorLog("binary.Read() failed, err: %v", err)
+				return err, dataAccessResults[0:i], datas[0:i]
+			}
+		default:
+			err = fmt.Errorf("unexpected ReadResponse choice: %d", tag)
+			errorLog("%s", err)
+			return err, dataAccessResults[0:i], datas[0:i]
+		}
+	}
+	return nil, dataAccessResults, datas
+}
+
+// ReadResponse carrying data-block-result. Concatenated raw data of all blocks is encoded ReadResponse (tag excluded).
+func encode_ReadResponseWithDataBlock(w io.Writer, lastBlock bool, blockNumber uint16, rawData []byte) (err error) {
+	_, err = w.Write([]byte{0x01, snReadDataBlockResult})
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, lastBlock)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumber)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = encodeAxdrLength(w, uint16(len(rawData)))
+	if nil != err {
+		return err
+	}
+	_, err = w.Write(rawData)
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func decode_ReadResponseWithDataBlock(r io.Reader) (err error, lastBlock bool, blockNumber uint16, rawData []byte) {
+	p := make([]byte, 2)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, false, 0, nil
+	}
+	if (0x01 != p[0]) || (snReadDataBlockResult != p[1]) {
+		err = fmt.Errorf("not a data-block-result: % 02X", p)
+		errorLog("%s", err)
+		return err, false, 0, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &lastBlock)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, false, 0, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &blockNumber)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, 0, nil
+	}
+	err, length := decodeAxdrLength(r)
+	if nil != err {
+		return err, lastBlock, blockNumber, nil
+	}
+	rawData = make([]byte, length)
+	err = binary.Read(r, binary.BigEndian, rawData)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, blockNumber, nil
+	}
+	return nil, lastBlock, blockNumber, rawData
+}
+
+// WriteRequest, UnconfirmedWriteRequest has the same encoding.
+func encode_WriteRequest(w io.Writer, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData, datas []*DlmsData) (err error) {
+	err = encode_snVariableAccessList(w, variableNames, accessSelectors, accessParameters)
+	if nil != err {
+		return err
+	}
+	return encode_snDataList(w, datas)
+}
+
+func decode_WriteRequest(r io.Reader) (err error, variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData, datas []*DlmsData) {
+	err, variableNames, accessSelectors, accessParameters = decode_snVariableAccessList(r)
+	if nil != err {
+		return err, variableNames, accessSelectors, accessParameters, nil
+	}
+	err, datas = decode_snDataList(r)
+	if nil != err {
+		return err, variableNames, accessSelectors, accessParameters, datas
+	}
+	if len(datas) != len(variableNames) {
+		err = fmt.Errorf("count of data %d differs from count of variables %d", len(datas), len(variableNames))
+		errorLog("%s", err)
+		return err, variableNames, accessSelectors, accessParameters, datas
+	}
+	return nil, variableNames, accessSelectors, accessParameters, datas
+}
+
+// WriteRequest with write-data-block-access. Concatenated raw data of all blocks is encoded WriteRequest (tag excluded).
+func encode_WriteRequestBlock(w io.Writer, lastBlock bool, blockNumber uint16, rawData []byte) (err error) {
+	_, err = w.Write([]byte{0x01, snWriteDataBlockAccess})
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, lastBlock)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumber)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	data := new(DlmsData)
+	data.SetOctetString(rawData)
+	return encode_snDataList(w, []*DlmsData{data})
+}
+
+func decode_WriteRequestBlock(r io.Reader) (err error, lastBlock bool, blockNumber uint16, rawData []byte) {
+	p := make([]byte, 2)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, false, 0, nil
+	}
+	if (0x01 != p[0]) || (snWriteDataBlockAccess != p[1]) {
+		err = fmt.Errorf("not a write-data-block-access: % 02X", p)
+		errorLog("%s", err)
+		return err, false, 0, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &lastBlock)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, false, 0, nil
+	}
+	err = binary.Read(r, binary.BigEndian, &blockNumber)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, lastBlock, 0, nil
+	}
+	err, datas := decode_snDataList(r)
+	if nil != err {
+		return err, lastBlock, blockNumber, nil
+	}
+	if (1 != len(datas)) || (DATA_TYPE_OCTET_STRING != datas[0].Typ) {
+		err = fmt.Errorf("write-data-block-access without raw data")
+		errorLog("%s", err)
+		return err, lastBlock, blockNumber, nil
+	}
+	return nil, lastBlock, blockNumber, datas[0].GetOctetString()
+}
+
+func encode_WriteResponse(w io.Writer, dataAccessResults []DlmsDataAccessResult) (err error) {
+	err = encodeAxdrLength(w, uint16(len(dataAccessResults)))
+	if nil != err {
+		return err
+	}
+	for _, dataAccessResult := range dataAccessResults {
+		if dataAccessResult_success == dataAccessResult {
+			_, err = w.Write([]byte{snWriteSuccess})
+		} else {
+			_, err = w.Write([]byte{snWriteDataAccessError, byte(dataAccessResult)})
+		}
+		if nil != err {
+			errorLog("w.Write() failed, err: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func decode_WriteResponse(r io.Reader) (err error, dataAccessResults []DlmsDataAccessResult) {
+	err, count := decodeAxdrLength(r)
+	if nil != err {
+		return err, nil
+	}
+	dataAccessResults = make([]DlmsDataAccessResult, count)
+	for i := 0; i < int(count); i++ {
+		var tag uint8
+		err = binary.Read(r, binary.BigEndian, &tag)
+		if nil != err {
+			errorLog("binary.Read() failed, err: %v", err)
+			return err, dataAccessResults[0:i]
+		}
+		switch tag {
+		case snWriteSuccess:
+			dataAccessResults[i] = dataAccessResult_success
+		case snWriteDataAccessError:
+			err = binary.Read(r, binary.BigEndian, &dataAccessResults[i])
+			if nil != err {
+				errorLog("binary.Read() failed, err: %v", err)
+				return err, dataAccessResults[0:i]
+			}
+		default:
+			err = fmt.Errorf("unexpected WriteResponse choice: %d", tag)
+			errorLog("%s", err)
+			return err, dataAccessResults[0:i]
+		}
+	}
+	return nil, dataAccessResults
+}
+
+// WriteResponse acknowledging received block of block transfer.
+func encode_WriteResponseBlockNumber(w io.Writer, blockNumber uint16) (err error) {
+	_, err = w.Write([]byte{0x01, snWriteBlockNumber})
+	if nil != err {
+		errorLog("w.Write() failed, err: %v", err)
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, blockNumber)
+	if nil != err {
+		errorLog("binary.Write() failed, err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func decode_WriteResponseBlockNumber(r io.Reader) (err error, blockNumber uint16) {
+	p := make([]byte, 2)
+	err = binary.Read(r, binary.BigEndian, p)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0
+	}
+	if (0x01 != p[0]) || (snWriteBlockNumber != p[1]) {
+		err = fmt.Errorf("not a block-number: % 02X", p)
+		errorLog("%s", err)
+		return err, 0
+	}
+	err = binary.Read(r, binary.BigEndian, &blockNumber)
+	if nil != err {
+		errorLog("binary.Read() failed, err: %v", err)
+		return err, 0
+	}
+	return nil, blockNumber
+}
+
+type DlmsSNRequest struct {
+	VariableName    DlmsShortName
+	AccessSelector  DlmsAccessSelector // If > 0 then parameterized-access is used.
+	AccessParameter *DlmsData
+	Data            *DlmsData // Data to be sent with WriteRequest. If non-nil then WriteRequest is sent otherwise ReadRequest is sent.
+	BlockSize       int       // If > 0 then WriteRequest is sent in blocks.
+}
+
+type DlmsSNResponse struct {
+	DataAccessResult DlmsDataAccessResult
+	Data             *DlmsData
+}
+
+type DlmsSNRequestResponse struct {
+	Req *DlmsSNRequest
+	Rep *DlmsSNResponse
+
+	RequestSubmittedAt time.Time
+	ReplyDeliveredAt   time.Time
+}
+
+type DlmsSNResultResponse []*DlmsSNRequestResponse
+
+func (rep DlmsSNResultResponse) RequestAt(i int) (req *DlmsSNRequest) {
+	return rep[i].Req
+}
+
+func (rep DlmsSNResultResponse) DataAt(i int) *DlmsData {
+	return rep[i].Rep.Data
+}
+
+func (rep DlmsSNResultResponse) DataAccessResultAt(i int) DlmsDataAccessResult {
+	return rep[i].Rep.DataAccessResult
+}
+
+func (rep DlmsSNResultResponse) DeliveredIn() time.Duration {
+	return rep[0].ReplyDeliveredAt.Sub(rep[0].RequestSubmittedAt)
+}
+
+// Application connection using short name referencing.
+type SNConn struct {
+	dconn             *DlmsConn
+	applicationClient uint16
+	logicalDevice     uint16
+	released          bool
+
+	requestMtx  sync.Mutex // serializes requests, SN replies carry no invoke id to match them with requests
+	mtx         sync.Mutex
+	outstanding int // guarded by mtx, number of requests sent or waiting to be sent
+}
+
+func NewSNConn(dconn *DlmsConn, applicationClient uint16, logicalDevice uint16) (snconn *SNConn) {
+	snconn = new(SNConn)
+	snconn.dconn = dconn
+	snconn.applicationClient = applicationClient
+	snconn.logicalDevice = logicalDevice
+
+	return snconn
+}
+
+// Releases association, see AppConn.Release().
+func (snconn *SNConn) Release(initiateRequest *DlmsInitiateRequest) (initiateResponse *DlmsInitiateResponse, err error) {
+	aconn := NewAppConn(snconn.dconn, snconn.applicationClient, snconn.logicalDevice, 0)
+	aconn.released = snconn.released
+	initiateResponse, err = aconn.Release(initiateRequest)
+	snconn.released = aconn.released
+	return initiateResponse, err
+}
+
+func (snconn *SNConn) Close() {
+	aconn := NewAppConn(snconn.dconn, snconn.applicationClient, snconn.logicalDevice, 0)
+	aconn.released = snconn.released
+	aconn.Close()
+	snconn.released = true
+}
+
+func (snconn *SNConn) transact(pdu []byte) (reply []byte, err error) {
+	err = snconn.dconn.transportSend(snconn.applicationClient, snconn.logicalDevice, pdu)
+	if nil != err {
+		return nil, err
+	}
+	reply, err = snconn.dconn.transportReceive(snconn.logicalDevice, snconn.applicationClient)
+	if nil != err {
+		return nil, err
+	}
+	err = decodeErrorPdu(reply)
+	if nil != err {
+		return nil, err
+	}
+	if 0 == len(reply) {
+		err = fmt.Errorf("received empty pdu")
+		errorLog("%s", err)
+		return nil, err
+	}
+	return reply, nil
+}
+
+func snRequestLists(vals []*DlmsSNRequest) (variableNames []DlmsShortName, accessSelectors []DlmsAccessSelector, accessParameters []*DlmsData, datas []*DlmsData) {
+	variableNames = make([]DlmsShortName, len(vals))
+	accessSelectors = make([]DlmsAccessSelector, len(vals))
+	accessParameters = make([]*DlmsData, len(vals))
+	datas = make([]*DlmsData, len(vals))
+	for i, val := range vals {
+		variableNames[i] = val.VariableName
+		accessSelectors[i] = val.AccessSelector
+		accessParameters[i] = val.AccessParameter
+		datas[i] = val.Data
+	}
+	return variableNames, accessSelectors, accessParameters, datas
+}
+
+/*
+Sends ReadRequest if all requests have nil 'Data', otherwise sends
+WriteRequest. Reading and writing cannot be mixed in one call.
+Blocks of ReadResponse are requested until last block is received.
+WriteRequest is sent in blocks if 'BlockSize' of first request is > 0.
+*/
+func (snconn *SNConn) SendRequest(vals []*DlmsSNRequest) (response DlmsSNResultResponse, err error) {
+	snconn.beginRequest()
+	defer snconn.endRequest()
+	return snconn.sendRequest(vals)
+}
+
+func (snconn *SNConn) beginRequest() {
+	snconn.mtx.Lock()
+	defer snconn.mtx.Unlock()
+	snconn.outstanding++
+}
+
+func (snconn *SNConn) endRequest() {
+	snconn.mtx.Lock()
+	defer snconn.mtx.Unlock()
+	snconn.outstanding--
+}
+
+// Returns true if requests other than the calling one are outstanding.
+func (snconn *SNConn) othersOutstanding() bool {
+	snconn.mtx.Lock()
+	defer snconn.mtx.Unlock()
+	return snconn.outstanding > 1
+}
+
+func (snconn *SNConn) sendRequest(vals []*DlmsSNRequest) (response DlmsSNResultResponse, err error) {
+	if 0 == len(vals) {
+		return nil, nil
+	}
+
+	snconn.requestMtx.Lock()
+	defer snconn.requestMtx.Unlock()
+
+	write := nil != vals[0].Data
+	rips := make([]*DlmsSNRequestResponse, len(vals))
+	for i, val := range vals {
+		if write != (nil != val.Data) {
+			err = fmt.Errorf("cannot mix read and write requests")
+			errorLog("%s", err)
+			return nil, err
+		}
+		rip := new(DlmsSNRequestResponse)
+		rip.Req = val
+		rip.RequestSubmittedAt = time.Now()
+		rips[i] = rip
+	}
+
+	var dataAccessResults []DlmsDataAccessResult
+	var datas []*DlmsData
+	if write {
+		dataAccessResults, err = snconn.write(vals)
+	} else {
+		dataAccessResults, datas, err = snconn.read(vals)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if len(dataAccessResults) != len(rips) {
+		err = fmt.Errorf("unexpected count of received list entries")
+		errorLog("%s", err)
+		return nil, err
+	}
+	t := time.Now()
+	for i, rip := range rips {
+		rip.Rep = new(DlmsSNResponse)
+		rip.Rep.DataAccessResult = dataAccessResults[i]
+		if nil != datas {
+			rip.Rep.Data = datas[i]
+		}
+		rip.ReplyDeliveredAt = t
+	}
+	return DlmsSNResultResponse(rips), nil
+}
+
+func (snconn *SNConn) read(vals []*DlmsSNRequest) (dataAccessResults []DlmsDataAccessResult, datas []*DlmsData, err error) {
+	variableNames, accessSelectors, accessParameters, _ := snRequestLists(vals)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x05)
+	err = encode_ReadRequest(buf, variableNames, accessSelectors, accessParameters)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	var rawData []byte
+	var expectedBlockNumber uint16 = 1
+	for {
+		pdu, err := snconn.transact(buf.Bytes())
+		if nil != err {
+			return nil, nil, err
+		}
+		if 0x0C != pdu[0] {
+			err = fmt.Errorf("received pdu discarded due to unknown tag: % 02X", pdu[0])
+			errorLog("%s", err)
+			return nil, nil, err
+		}
+
+		tag, ok := snFirstChoice(pdu[1:])
+		if !ok || (snReadDataBlockResult != tag) {
+			if nil != rawData {
+				err = fmt.Errorf("expected data-block-result")
+				errorLog("%s", err)
+				return nil, nil, err
+			}
+			err, dataAccessResults, datas = decode_ReadResponse(bytes.NewReader(pdu[1:]))
+			return dataAccessResults, datas, err
+		}
+
+		err, lastBlock, blockNumber, _rawData := decode_ReadResponseWithDataBlock(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return nil, nil, err
+		}
+		if expectedBlockNumber != blockNumber {
+			err = fmt.Errorf("error occured receiving response block: received unexpected blockNumber: %d, expected: %d", blockNumber, expectedBlockNumber)
+			errorLog("%s", err)
+			return nil, nil, err
+		}
+		rawData = append(rawData, _rawData...)
+		if lastBlock {
+			debugLog("blocks received, processing ReadResponse")
+			err, dataAccessResults, datas = decode_ReadResponse(bytes.NewReader(rawData))
+			return dataAccessResults, datas, err
+		}
+
+		debugLog("requesting next data block after block %d", blockNumber)
+		buf = new(bytes.Buffer)
+		buf.WriteByte(0x05)
+		err = encode_ReadRequestBlockNumber(buf, blockNumber)
+		if nil != err {
+			return nil, nil, err
+		}
+		expectedBlockNumber += 1
+	}
+}
+
+func (snconn *SNConn) write(vals []*DlmsSNRequest) (dataAccessResults []DlmsDataAccessResult, err error) {
+	variableNames, accessSelectors, accessParameters, datas := snRequestLists(vals)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x06)
+	err = encode_WriteRequest(buf, variableNames, accessSelectors, accessParameters, datas)
+	if nil != err {
+		return nil, err
+	}
+
+	blockSize := vals[0].BlockSize
+	if 0 == blockSize {
+		pdu, err := snconn.transact(buf.Bytes())
+		if nil != err {
+			return nil, err
+		}
+		return snconn.processWriteResponse(pdu)
+	}
+
+	rawData := buf.Bytes()[1:]
+	var blockNumber uint16
+	for {
+		n := blockSize
+		if n > len(rawData) {
+			n = len(rawData)
+		}
+		block := rawData[0:n]
+		rawData = rawData[n:]
+		lastBlock := 0 == len(rawData)
+		blockNumber += 1
+
+		buf = new(bytes.Buffer)
+		buf.WriteByte(0x06)
+		err = encode_WriteRequestBlock(buf, lastBlock, blockNumber, block)
+		if nil != err {
+			return nil, err
+		}
+		pdu, err := snconn.transact(buf.Bytes())
+		if nil != err {
+			return nil, err
+		}
+		if lastBlock {
+			return snconn.processWriteResponse(pdu)
+		}
+
+		tag, ok := snFirstChoice(pdu[1:])
+		if (0x0D != pdu[0]) || !ok || (snWriteBlockNumber != tag) {
+			// server terminated block transfer
+			return snconn.processWriteResponse(pdu)
+		}
+		err, _blockNumber := decode_WriteResponseBlockNumber(bytes.NewReader(pdu[1:]))
+		if nil != err {
+			return nil, err
+		}
+		if blockNumber != _blockNumber {
+			err = fmt.Errorf("error occured sending request block: received unexpected blockNumber: %d, expected: %d", _blockNumber, blockNumber)
+			errorLog("%s", err)
+			return nil, err
+		}
+	}
+}
+
+func (snconn *SNConn) processWriteResponse(pdu []byte) (dataAccessResults []DlmsDataAccessResult, err error) {
+	if 0x0D != pdu[0] {
+		err = fmt.Errorf("received pdu discarded due to unknown tag: % 02X", pdu[0])
+		errorLog("%s", err)
+		return nil, err
+	}
+	err, dataAccessResults = decode_WriteResponse(bytes.NewReader(pdu[1:]))
+	return dataAccessResults, err
+}
+
+// Sends UnconfirmedWriteRequest, server sends no response.
+func (snconn *SNConn) SendUnconfirmedWrite(vals []*DlmsSNRequest) (err error) {
+	err, pdu := encodeUnconfirmedWrite(vals)
+	if nil != err {
+		return err
+	}
+	return snconn.dconn.transportSend(snconn.applicationClient, snconn.logicalDevice, pdu)
+}
+
+// Sends UnconfirmedWriteRequest to all meters on HDLC line in UI frame addressed to all stations.
+func (snconn *SNConn) BroadcastUnconfirmedWrite(vals []*DlmsSNRequest) (err error) {
+	err, pdu := encodeUnconfirmedWrite(vals)
+	if nil != err {
+		return err
+	}
+	return snconn.dconn.transportBroadcast(pdu)
+}
+
+func encodeUnconfirmedWrite(vals []*DlmsSNRequest) (err error, pdu []byte) {
+	variableNames, accessSelectors, accessParameters, datas := snRequestLists(vals)
+	for _, data := range datas {
+		if nil == data {
+			err = fmt.Errorf("missing data in unconfirmed write request")
+			errorLog("%s", err)
+			return err, nil
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x16)
+	err = encode_WriteRequest(buf, variableNames, accessSelectors, accessParameters, datas)
+	if nil != err {
+		return err, nil
+	}
+	return nil, buf.Bytes()
+}
@@ -0,0 +1,243 @@
+package gocosem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func connectCosemServerSN(t *testing.T, port int) (dconn *DlmsConn, snconn *SNConn) {
+	dconn, err := TcpConnect("localhost", port)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	snconn, err = dconn.SNConnectWithPassword(01, 01, "12345678")
+	if nil != err {
+		dconn.Close()
+		t.Fatalf("%s\n", err)
+	}
+	return dconn, snconn
+}
+
+func TestSn_encode_ReadRequest(t *testing.T) {
+	accessParameter := new(DlmsData)
+	accessParameter.SetDoubleLongUnsigned(1)
+
+	var buf bytes.Buffer
+	err := encode_ReadRequest(&buf, []DlmsShortName{0x0100, 0x0208}, []DlmsAccessSelector{0, 2}, []*DlmsData{nil, accessParameter})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	b := []byte{0x02, 0x02, 0x01, 0x00, 0x04, 0x02, 0x08, 0x02, 0x06, 0x00, 0x00, 0x00, 0x01}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("bytes don't match: % 02X", buf.Bytes())
+	}
+
+	err, variableNames, accessSelectors, accessParameters := decode_ReadRequest(bytes.NewReader(b))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (2 != len(variableNames)) || (0x0208 != variableNames[1]) || (2 != accessSelectors[1]) || (nil != accessParameters[0]) || (1 != accessParameters[1].GetDoubleLongUnsigned()) {
+		t.Fatalf("decoded request differs")
+	}
+}
+
+func TestSn_encode_ReadResponse(t *testing.T) {
+	data := new(DlmsData)
+	data.SetLongUnsigned(0x0102)
+
+	var buf bytes.Buffer
+	err := encode_ReadResponse(&buf, []DlmsDataAccessResult{dataAccessResult_success, dataAccessResult_objectUndefined}, []*DlmsData{data, nil})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	b := []byte{0x02, 0x00, 0x12, 0x01, 0x02, 0x01, 0x04}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("bytes don't match: % 02X", buf.Bytes())
+	}
+
+	err, dataAccessResults, datas := decode_ReadResponse(bytes.NewReader(b))
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if (dataAccessResult_success != dataAccessResults[0]) || (0x0102 != datas[0].GetLongUnsigned()) || (dataAccessResult_objectUndefined != dataAccessResults[1]) {
+		t.Fatalf("decoded response differs")
+	}
+}
+
+func TestSn_encode_WriteRequest(t *testing.T) {
+	data := new(DlmsData)
+	data.SetUnsigned(5)
+
+	var buf bytes.Buffer
+	err := encode_WriteRequest(&buf, []DlmsShortName{0x0108}, []DlmsAccessSelector{0}, []*DlmsData{nil}, []*DlmsData{data})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	b := []byte{0x01, 0x02, 0x01, 0x08, 0x01, 0x11, 0x05}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("bytes don't match: % 02X", buf.Bytes())
+	}
+
+	buf.Reset()
+	err = encode_WriteResponse(&buf, []DlmsDataAccessResult{dataAccessResult_success, dataAccessResult_readWriteDenied})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	b = []byte{0x02, 0x00, 0x01, 0x03}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("bytes don't match: % 02X", buf.Bytes())
+	}
+}
+
+func TestSn_ReadWrite(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	srv.AddObject(1, instanceId).SetAttribute(2, data)
+	srv.SetBaseName(instanceId, 0xFD00)
+
+	dconn, snconn := connectCosemServerSN(t, port)
+	defer dconn.Close()
+
+	rep, err := snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: 0xFD00},
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2)},
+		&DlmsSNRequest{VariableName: 0xFE00},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(instanceId[:], rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("logical name differs")
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(1).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+	if dataAccessResult_objectUndefined != rep.DataAccessResultAt(2) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(2))
+	}
+
+	written := new(DlmsData)
+	written.SetOctetString([]byte{0x05, 0x04, 0x03})
+	rep, err = snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2), Data: written},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if dataAccessResult_success != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+
+	unconfirmed := new(DlmsData)
+	unconfirmed.SetOctetString([]byte{0x0A, 0x0B})
+	err = snconn.SendUnconfirmedWrite([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2), Data: unconfirmed},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+
+	rep, err = snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2)},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(unconfirmed.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestSn_parameterizedAccess(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	instanceId := &DlmsOid{1, 0, 99, 1, 0, 255}
+	obj := srv.AddObject(CLASS_ID_PROFILE_GENERIC, instanceId)
+	obj.SetAttributeGetter(2, func(obj *CosemObject, attributeId DlmsAttributeId, accessSelector DlmsAccessSelector, accessParameters *DlmsData) (DlmsDataAccessResult, *DlmsData) {
+		data := new(DlmsData)
+		if 2 != accessSelector {
+			return dataAccessResult_otherReason, nil
+		}
+		data.SetDoubleLongUnsigned(accessParameters.Arr[0].GetDoubleLongUnsigned())
+		return dataAccessResult_success, data
+	})
+	srv.SetBaseName(instanceId, 0x0200)
+
+	dconn, snconn := connectCosemServerSN(t, port)
+	defer dconn.Close()
+
+	accessParameter := new(DlmsData)
+	accessParameter.SetStructure(1)
+	accessParameter.Arr[0].SetDoubleLongUnsigned(7)
+	rep, err := snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0x0200, 2), AccessSelector: 2, AccessParameter: accessParameter},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if 7 != rep.DataAt(0).GetDoubleLongUnsigned() {
+		t.Fatalf("access parameter not passed")
+	}
+}
+
+func TestSn_blockTransfer(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+	srv.BlockLength = 10
+
+	instanceId := &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}
+	obj := srv.AddObject(1, instanceId)
+	data := new(DlmsData)
+	data.SetOctetString(generateBytes(100))
+	obj.SetAttribute(2, data)
+	srv.SetBaseName(instanceId, 0xFD00)
+
+	dconn, snconn := connectCosemServerSN(t, port)
+	defer dconn.Close()
+
+	rep, err := snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2)},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+
+	written := new(DlmsData)
+	written.SetOctetString(generateBytes(77))
+	rep, err = snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: SNAttribute(0xFD00, 2), Data: written, BlockSize: 16},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if dataAccessResult_success != rep.DataAccessResultAt(0) {
+		t.Fatalf("dataAccessResult: %d\n", rep.DataAccessResultAt(0))
+	}
+	if !bytes.Equal(written.GetOctetString(), obj.GetAttribute(2).GetOctetString()) {
+		t.Fatalf("value not written")
+	}
+}
+
+func TestSn_requestInLNAssociation(t *testing.T) {
+	srv, port := startCosemServer(t)
+	defer srv.Close()
+
+	dconn, _ := connectCosemServer(t, port)
+	defer dconn.Close()
+
+	snconn := NewSNConn(dconn, 01, 01)
+	_, err := snconn.SendRequest([]*DlmsSNRequest{
+		&DlmsSNRequest{VariableName: 0xFD00},
+	})
+	if e, ok := err.(*ExceptionResponseError); !ok || (EXCEPTION_SERVICE_ERROR_SERVICE_NOT_SUPPORTED != e.ServiceError) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -5,6 +5,18 @@ go test -run TestData
 go test -run TestDlms
 go test -run TestApp
 go test -run TestHdlc
+go test -run TestServer
+go test -run TestIc
+go test -run TestObis
+go test -run TestSn
+go test -run TestImage
+go test -run TestSerial
+go test -run TestIec
+go test -run TestContext
+go test -run TestAsync
+go test -run TestSession
+go test -run TestTls
+go test -run TestPush
 #go test -run TestMeterTcp
 #go test -run TestMeterHdlc
 #go test -run TestMeterAHdlc
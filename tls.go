@@ -0,0 +1,80 @@
+package gocosem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+/*
+Connects DLMS wrapper transport over TLS. Client certificates, trusted CA
+pool and expected server name are set in 'config' (Certificates, RootCAs and
+ServerName, server name is derived from 'ipAddr' if empty). Server public key
+may be pinned by setting config.VerifyPeerCertificate to TlsPinPublicKeys().
+*/
+func TlsConnect(ipAddr string, port int, config *tls.Config) (dconn *DlmsConn, err error) {
+	return TlsConnectContext(context.Background(), ipAddr, port, config)
+}
+
+func TlsConnectContext(ctx context.Context, ipAddr string, port int, config *tls.Config) (dconn *DlmsConn, err error) {
+	dialer := &tls.Dialer{Config: config}
+
+	dconn = new(DlmsConn)
+	dconn.transportType = Transport_TCP
+
+	debugLog("connecting tls transport: %s:%d\n", ipAddr, port)
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", ipAddr, port))
+	if nil != err {
+		errorLog("tls dial failed: %v", err)
+		return nil, err
+	}
+	dconn.rwc = conn
+
+	debugLog("tls transport connected: %s:%d\n", ipAddr, port)
+	return dconn, nil
+}
+
+func TlsSessionDialer(ipAddr string, port int, config *tls.Config) SessionDialer {
+	return func(ctx context.Context) (*DlmsConn, error) {
+		return TlsConnectContext(ctx, ipAddr, port, config)
+	}
+}
+
+// Returns pin of certificate public key: SHA-256 hash of its SubjectPublicKeyInfo.
+func TlsPublicKeyPin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+/*
+Returns function to be set as tls.Config.VerifyPeerCertificate accepting
+server only if public key of its certificate matches one of 'pins' (see
+TlsPublicKeyPin()). Pinning is checked in addition to regular certificate
+verification unless InsecureSkipVerify is set.
+*/
+func TlsPinPublicKeys(pins ...[]byte) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if 0 == len(rawCerts) {
+			err := fmt.Errorf("tls: no server certificate")
+			errorLog("%s", err)
+			return err
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if nil != err {
+			errorLog("x509.ParseCertificate() failed: %v", err)
+			return err
+		}
+		pin := TlsPublicKeyPin(cert)
+		for _, p := range pins {
+			if bytes.Equal(p, pin) {
+				return nil
+			}
+		}
+		err = fmt.Errorf("tls: server public key not pinned: % 02X", pin)
+		errorLog("%s", err)
+		return err
+	}
+}
@@ -0,0 +1,219 @@
+package gocosem
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type tTestPki struct {
+	caPool     *x509.CertPool
+	serverCert tls.Certificate
+	clientCert tls.Certificate
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if nil == parent {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	return cert, key
+}
+
+func newTestPki(t *testing.T) (pki *tTestPki) {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+
+	ca, caKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+
+	server, serverKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	client, clientKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "head-end"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	pki = new(tTestPki)
+	pki.caPool = x509.NewCertPool()
+	pki.caPool.AddCert(ca)
+	pki.serverCert = tls.Certificate{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey, Leaf: server}
+	pki.clientCert = tls.Certificate{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey, Leaf: client}
+	return pki
+}
+
+// Starts tls listener requiring client certificate and passing connections to mock server.
+func listenMockTls(t *testing.T, pki *tTestPki) (ln net.Listener, port int) {
+	ln, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientCAs:    pki.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			go func() {
+				// failed handshakes are expected by tests, mock server sees only established connections
+				err := conn.(*tls.Conn).Handshake()
+				if nil != err {
+					conn.Close()
+					return
+				}
+				mockCosemServer.acceptApp(t, conn, c_TEST_AARE)
+			}()
+		}
+	}()
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTls_TlsConnect(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	data := new(DlmsData)
+	data.SetOctetString([]byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	mockCosemServer.setAttribute(&DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, 1, 0x02, data)
+
+	pki := newTestPki(t)
+	ln, port := listenMockTls(t, pki)
+	defer ln.Close()
+
+	dconn, err := TlsConnect("localhost", port, &tls.Config{
+		Certificates: []tls.Certificate{pki.clientCert},
+		RootCAs:      pki.caPool,
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	defer dconn.Close()
+
+	aconn, err := dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	rep, err := aconn.SendRequest([]*DlmsRequest{
+		&DlmsRequest{ClassId: 1, InstanceId: &DlmsOid{0x00, 0x00, 0x2A, 0x00, 0x00, 0xFF}, AttributeId: 0x02},
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	if !bytes.Equal(data.GetOctetString(), rep.DataAt(0).GetOctetString()) {
+		t.Fatalf("value differs")
+	}
+}
+
+func TestTls_TlsConnect_verification(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	pki := newTestPki(t)
+	ln, port := listenMockTls(t, pki)
+	defer ln.Close()
+
+	// unknown CA
+	_, err := TlsConnect("localhost", port, &tls.Config{
+		Certificates: []tls.Certificate{pki.clientCert},
+	})
+	if nil == err {
+		t.Fatalf("server with unknown CA accepted")
+	}
+
+	// server name differs
+	_, err = TlsConnect("localhost", port, &tls.Config{
+		Certificates: []tls.Certificate{pki.clientCert},
+		RootCAs:      pki.caPool,
+		ServerName:   "meter.example.com",
+	})
+	if nil == err {
+		t.Fatalf("server with wrong name accepted")
+	}
+
+	// no client certificate, server rejects connection during handshake or first read
+	dconn, err := TlsConnect("localhost", port, &tls.Config{
+		RootCAs: pki.caPool,
+	})
+	if nil == err {
+		_, err = dconn.AppConnectWithPassword(01, 01, 0, "12345678")
+		dconn.Close()
+	}
+	if nil == err {
+		t.Fatalf("client without certificate accepted")
+	}
+}
+
+func TestTls_TlsPinPublicKeys(t *testing.T) {
+	ensureMockCosemServer(t)
+	mockCosemServer.Init()
+	defer mockCosemServer.Close()
+
+	pki := newTestPki(t)
+	ln, port := listenMockTls(t, pki)
+	defer ln.Close()
+
+	dconn, err := TlsConnect("localhost", port, &tls.Config{
+		Certificates:          []tls.Certificate{pki.clientCert},
+		RootCAs:               pki.caPool,
+		VerifyPeerCertificate: TlsPinPublicKeys(TlsPublicKeyPin(pki.serverCert.Leaf)),
+	})
+	if nil != err {
+		t.Fatalf("%s\n", err)
+	}
+	dconn.Close()
+
+	_, err = TlsConnect("localhost", port, &tls.Config{
+		Certificates:          []tls.Certificate{pki.clientCert},
+		RootCAs:               pki.caPool,
+		VerifyPeerCertificate: TlsPinPublicKeys(TlsPublicKeyPin(pki.clientCert.Leaf)),
+	})
+	if nil == err {
+		t.Fatalf("server with unpinned key accepted")
+	}
+}
@@ -2,6 +2,7 @@ package gocosem
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -68,6 +69,9 @@ type DlmsConn struct {
 	sendFrameCounter          uint32
 	clientToServerChallenge   string
 	serverToClientChallenge   string
+	maxReceivePduSize         uint16 // client max receive pdu size proposed in AARQ (no limit if 0)
+
+	ProposeGbt bool // If true then AppConnectWithPassword() proposes general block transfer, it is used only if server accepts it.
 }
 
 type DlmsTransportSendRequest struct {
@@ -130,9 +134,12 @@ func ipTransportSend(rwc io.ReadWriteCloser, srcWport uint16, dstWport uint16, p
 	return nil
 }
 
-func hdlcTransportSend(rwc io.ReadWriteCloser, pdu []byte) error {
+// LLC sublayer headers
+var llcHeaderCommand = []byte{0xE6, 0xE6, 0x00}  // client to server
+var llcHeaderResponse = []byte{0xE6, 0xE7, 0x00} // server to client
+
+func hdlcTransportSend(rwc io.ReadWriteCloser, llcHeader []byte, pdu []byte) error {
 	var buf bytes.Buffer
-	llcHeader := []byte{0xE6, 0xE6, 0x00} // LLC sublayer header
 
 	_, err := buf.Write(llcHeader)
 	if nil != err {
@@ -170,13 +177,13 @@ func (dconn *DlmsConn) transportSend(src uint16, dst uint16, pdu []byte) (err er
 	if (Transport_TCP == dconn.transportType) || (Transport_UDP == dconn.transportType) {
 		return ipTransportSend(dconn.rwc, src, dst, pdu)
 	} else if Transport_HDLC == dconn.transportType {
-		return hdlcTransportSend(dconn.rwc, pdu)
+		return hdlcTransportSend(dconn.rwc, llcHeaderCommand, pdu)
 	} else {
 		panic(fmt.Sprintf("unsupported transport type: %d", dconn.transportType))
 	}
 }
 
-func ipTransportReceive(rwc io.ReadWriteCloser, srcWport *uint16, dstWport *uint16) (pdu []byte, src uint16, dst uint16, err error) {
+func ipTransportReceive(rwc io.Reader, srcWport *uint16, dstWport *uint16) (pdu []byte, src uint16, dst uint16, err error) {
 	var (
 		header tWrapperHeader
 	)
@@ -209,15 +216,17 @@ func ipTransportReceive(rwc io.ReadWriteCloser, srcWport *uint16, dstWport *uint
 	return pdu, header.SrcWport, header.DstWport, nil
 }
 
-func hdlcTransportReceive(rwc io.ReadWriteCloser) (pdu []byte, err error) {
+// Receives pdu not longer than 'maxPduSize' (negotiated max receive pdu size, no limit if 0).
+func hdlcTransportReceive(rwc io.ReadWriteCloser, llcHeaderExpected []byte, maxPduSize uint16) (pdu []byte, err error) {
 
 	debugLog("receiving pdu ...\n")
 
-	//TODO: Set maxSegmnetSize to AARE.user-information.server-max-receive-pdu-size.
-	// AARE.user-information is of 'InitiateResponse' asn1 type and is A-XDR encoded.
-	maxSegmnetSize := 3 * 1024
+	if 0 == maxPduSize {
+		maxPduSize = 0xFFFF
+	}
 
-	p := make([]byte, maxSegmnetSize)
+	// one byte more to detect pdu exceeding negotiated size
+	p := make([]byte, len(llcHeaderExpected)+int(maxPduSize)+1)
 
 	// hdlc ReadWriter read returns always whole segment into 'p' or full 'p' if 'p' is not long enough to fit in all segment
 	n, err := rwc.Read(p)
@@ -225,9 +234,10 @@ func hdlcTransportReceive(rwc io.ReadWriteCloser) (pdu []byte, err error) {
 		errorLog("hdlc.Read() failed, err: %v\n", err)
 		return nil, err
 	}
-	// Guard against read buffer being shorter then maximum possible segment size.
 	if len(p) == n {
-		panic("short read suspected, increase buffer size!")
+		err = fmt.Errorf("received pdu exceeds max receive pdu size: %d", maxPduSize)
+		errorLog("%s", err)
+		return nil, err
 	}
 
 	buf := bytes.NewBuffer(p[0:n])
@@ -238,7 +248,7 @@ func hdlcTransportReceive(rwc io.ReadWriteCloser) (pdu []byte, err error) {
 		errorLog("binary.Read() failed, err: %v\n", err)
 		return nil, err
 	}
-	if !bytes.Equal(llcHeader, []byte{0xE6, 0xE7, 0x00}) {
+	if !bytes.Equal(llcHeader, llcHeaderExpected) {
 		err = fmt.Errorf("wrong LLC header")
 		errorLog("%s", err)
 		return nil, err
@@ -260,7 +270,7 @@ func (dconn *DlmsConn) transportReceive(src uint16, dst uint16) (pdu []byte, err
 			return nil, err
 		}
 	} else if Transport_HDLC == dconn.transportType {
-		pdu, err = hdlcTransportReceive(dconn.rwc)
+		pdu, err = hdlcTransportReceive(dconn.rwc, llcHeaderResponse, dconn.maxReceivePduSize)
 		if nil != err {
 			return nil, err
 		}
@@ -276,6 +286,68 @@ func (dconn *DlmsConn) transportReceive(src uint16, dst uint16) (pdu []byte, err
 	return pdu, err
 }
 
+// Sends 'pdu' in HDLC UI frame addressed to all meters on the line, meters send no reply.
+func (dconn *DlmsConn) transportBroadcast(pdu []byte) (err error) {
+	debugLog("broadcasting app pdu: % 02X\n", pdu)
+
+	if Transport_HDLC != dconn.transportType {
+		err = fmt.Errorf("broadcast not supported by transport type: %d", dconn.transportType)
+		errorLog("%s", err)
+		return err
+	}
+
+	err, pdu = dconn.encryptPdu(pdu)
+	if nil != err {
+		return err
+	}
+	return dconn.HdlcClient.BroadcastUI(append(append([]byte(nil), llcHeaderCommand...), pdu...))
+}
+
+/*
+Receives push (EventNotificationRequest or DataNotification) sent by meter in
+HDLC UI frame, blocks until push arrives or connection is closed. Pushes are
+deciphered if association is ciphered, pushes which cannot be decoded are
+dropped.
+*/
+func (dconn *DlmsConn) ReceivePush() (push *DlmsPush, err error) {
+	if Transport_HDLC != dconn.transportType {
+		err = fmt.Errorf("pushes in UI frames not supported by transport type: %d", dconn.transportType)
+		errorLog("%s", err)
+		return nil, err
+	}
+
+	for {
+		p, err := dconn.HdlcClient.ReadUI()
+		if nil != err {
+			return nil, err
+		}
+		if (len(p) < len(llcHeaderResponse)) || !bytes.Equal(p[0:len(llcHeaderResponse)], llcHeaderResponse) {
+			warnLog("dropping UI frame: wrong LLC header")
+			continue
+		}
+		pdu := p[len(llcHeaderResponse):]
+		debugLog("received push: % 02X", pdu)
+		if 0 == len(pdu) {
+			warnLog("dropping empty push")
+			continue
+		}
+
+		err, pdu = dconn.decryptPdu(pdu)
+		if nil != err {
+			warnLog("dropping push: %v", err)
+			continue
+		}
+		err, push = decodePushPdu(pdu)
+		if nil != err {
+			warnLog("dropping push: %v", err)
+			continue
+		}
+		push.Src = dconn.HdlcClient.logicalDeviceId
+		push.Dst = uint16(dconn.HdlcClient.clientId)
+		return push, nil
+	}
+}
+
 var gloTagMap = map[byte]byte{
 	1:   33,
 	5:   37,
@@ -390,6 +462,17 @@ func (dconn *DlmsConn) decryptPduGSM(pdu []byte) (err error, dpdu []byte) {
 	}
 	pdu = buf.Bytes()
 
+	return decryptGSM(dconn.EK, dconn.AK, dconn.serverSystemTitle, pdu)
+}
+
+// Decrypts and authenticates ciphered content: security control, frame counter, ciphertext and authentication tag.
+func decryptGSM(EK []byte, AK []byte, systemTitle []byte, pdu []byte) (err error, dpdu []byte) {
+	if len(pdu) < 1+4+GCM_TAG_LEN {
+		err = fmt.Errorf("ciphered content too short")
+		errorLog("%s", err)
+		return err, nil
+	}
+
 	// security control
 	SC := pdu[0] // security control
 	if SC != 0x30 {
@@ -408,23 +491,23 @@ func (dconn *DlmsConn) decryptPduGSM(pdu []byte) (err error, dpdu []byte) {
 
 	// initialization vector
 	IV := make([]byte, 12) // initialization vector
-	if len(dconn.serverSystemTitle) != 8 {
+	if len(systemTitle) != 8 {
 		err = fmt.Errorf("system title length is not 8")
 		errorLog("%s", err)
 		return err, nil
 	}
-	copy(IV, dconn.serverSystemTitle)
-	copy(IV[len(dconn.serverSystemTitle):], FC)
+	copy(IV, systemTitle)
+	copy(IV[len(systemTitle):], FC)
 
 	// additional authenticated data
-	AAD := make([]byte, 1+len(dconn.AK))
+	AAD := make([]byte, 1+len(AK))
 	AAD[0] = SC
-	copy(AAD[1:], dconn.AK)
+	copy(AAD[1:], AK)
 
 	ciphertext := pdu[1+4 : len(pdu)-GCM_TAG_LEN]
 	receivedAuthTag := pdu[len(pdu)-GCM_TAG_LEN:]
 
-	err, dpdu, authTag := aesgcm(dconn.EK, IV, AAD, ciphertext, 1)
+	err, dpdu, authTag := aesgcm(EK, IV, AAD, ciphertext, 1)
 	if err != nil {
 		return err, nil
 	}
@@ -445,7 +528,15 @@ func (dconn *DlmsConn) decryptPduGSM(pdu []byte) (err error, dpdu []byte) {
 	return nil, dpdu
 }
 
+// ACSE APDUs (AARQ, AARE, RLRQ, RLRE) are never ciphered as whole, only their user-information is.
+func isAcsePdu(pdu []byte) bool {
+	return (len(pdu) > 0) && (pdu[0] >= 0x60) && (pdu[0] <= 0x63)
+}
+
 func (dconn *DlmsConn) encryptPdu(pdu []byte) (err error, epdu []byte) {
+	if isAcsePdu(pdu) {
+		return nil, pdu
+	}
 	if dconn.authenticationMechanismId == high_level_security_mechanism_using_GMAC {
 		err, epdu = dconn.encryptPduGSM(pdu)
 		debugLog("encrypted app pdu: % 0X", epdu)
@@ -459,7 +550,22 @@ func (dconn *DlmsConn) encryptPdu(pdu []byte) (err error, epdu []byte) {
 	}
 }
 
+// Returns by how many bytes ciphering makes pdu longer.
+func (dconn *DlmsConn) cipherOverhead() int {
+	if dconn.authenticationMechanismId == high_level_security_mechanism_using_GMAC {
+		return 1 + 3 + 1 + 4 + 12 // tag + LEN + SC + frameCounter + authTag
+	}
+	return 0
+}
+
 func (dconn *DlmsConn) decryptPdu(pdu []byte) (err error, dpdu []byte) {
+	if isAcsePdu(pdu) {
+		return nil, pdu
+	}
+	if (len(pdu) > 0) && ((0xD8 == pdu[0]) || (0x0E == pdu[0])) {
+		// ExceptionResponse has no ciphered counterpart, ConfirmedServiceError is sent unciphered if server could not decipher request
+		return nil, pdu
+	}
 	if dconn.authenticationMechanismId == high_level_security_mechanism_using_GMAC {
 		err, dpdu = dconn.decryptPduGSM(pdu)
 		debugLog("decrypted app pdu: % 0X", dpdu)
@@ -603,16 +709,12 @@ func (aconn *AppConn) doChallengeClientSide_for_high_level_security_mechanism_us
 	return nil
 }
 
-func (dconn *DlmsConn) AppConnectWithPassword(applicationClient uint16, logicalDevice uint16, invokeId uint8, password string) (aconn *AppConn, err error) {
-	var aarq = AARQ{
-		appCtxt:   LogicalName_NoCiphering,
-		authMech:  LowLevelSecurity,
-		authValue: password,
-	}
+func (dconn *DlmsConn) associateWithPassword(applicationClient uint16, logicalDevice uint16, aarq *AARQ) (aare *AARE, err error) {
 	pdu, err := aarq.encode()
 	if err != nil {
 		return nil, err
 	}
+	dconn.maxReceivePduSize = aarqMaxReceivePduSize
 
 	err = dconn.transportSend(applicationClient, logicalDevice, pdu)
 	if nil != err {
@@ -623,7 +725,7 @@ func (dconn *DlmsConn) AppConnectWithPassword(applicationClient uint16, logicalD
 		return nil, err
 	}
 
-	var aare AARE
+	aare = new(AARE)
 	err = aare.decode(pdu)
 	if err != nil {
 		return nil, err
@@ -632,11 +734,41 @@ func (dconn *DlmsConn) AppConnectWithPassword(applicationClient uint16, logicalD
 		err = fmt.Errorf("app connect failed, result: %v, diagnostic: %v", aare.result, aare.diagnostic)
 		errorLog("%s", err)
 		return nil, err
-	} else {
-		aconn = NewAppConn(dconn, applicationClient, logicalDevice, invokeId)
-		return aconn, nil
 	}
+	return aare, nil
+}
 
+func (dconn *DlmsConn) AppConnectWithPassword(applicationClient uint16, logicalDevice uint16, invokeId uint8, password string) (aconn *AppConn, err error) {
+	var aarq = AARQ{
+		appCtxt:   LogicalName_NoCiphering,
+		authMech:  LowLevelSecurity,
+		authValue: password,
+		gbt:       dconn.ProposeGbt,
+	}
+	aare, err := dconn.associateWithPassword(applicationClient, logicalDevice, &aarq)
+	if nil != err {
+		return nil, err
+	}
+	aconn = NewAppConn(dconn, applicationClient, logicalDevice, invokeId)
+	aconn.setNegotiatedConformance(aare.conformance)
+	aconn.serverMaxReceivePduSize = aare.maxPduSize
+	return aconn, nil
+}
+
+// Establishes association using short name referencing.
+func (dconn *DlmsConn) SNConnectWithPassword(applicationClient uint16, logicalDevice uint16, password string) (snconn *SNConn, err error) {
+	var aarq = AARQ{
+		appCtxt:     ShortName_NoCiphering,
+		authMech:    LowLevelSecurity,
+		authValue:   password,
+		conformance: snConformance,
+	}
+	_, err = dconn.associateWithPassword(applicationClient, logicalDevice, &aarq)
+	if nil != err {
+		return nil, err
+	}
+	snconn = NewSNConn(dconn, applicationClient, logicalDevice)
+	return snconn, nil
 }
 
 func (dconn *DlmsConn) AppConnectWithSecurity5(applicationClient uint16, logicalDevice uint16, invokeId uint8, authenticationKey []byte, encryptionKey []byte, applicationContextName []uint32, callingAPtitle []byte, clientToServerChallenge string, initiateRequest *DlmsInitiateRequest, sendFrameCounter uint32) (aconn *AppConn, initiateResponse *DlmsInitiateResponse, err error) {
@@ -656,6 +788,7 @@ func (dconn *DlmsConn) AppConnectWithSecurity5(applicationClient uint16, logical
 		return nil, nil, err
 	}
 	initiateRequestBytes := buf.Bytes()
+	dconn.maxReceivePduSize = initiateRequest.clientMaxReceivePduSize
 
 	// enforce 128 bit keys
 	if len(dconn.AK) != 16 {
@@ -879,6 +1012,8 @@ func (dconn *DlmsConn) AppConnectWithSecurity5(applicationClient uint16, logical
 	}
 
 	aconn = NewAppConn(dconn, applicationClient, logicalDevice, invokeId)
+	aconn.setNegotiatedConformance(initiateResponse.negotiatedConformance.buf)
+	aconn.serverMaxReceivePduSize = initiateResponse.serverMaxReceivePduSize
 
 	err = aconn.doChallengeClientSide_for_high_level_security_mechanism_using_GMAC()
 	if nil != err {
@@ -917,6 +1052,13 @@ func (dconn *DlmsConn) AppConnect(applicationClient uint16, logicalDevice uint16
 		return nil, nil, err
 	}
 	aarqBytes := buf.Bytes()
+	if (nil != aarq.userInformation) && (len(*aarq.userInformation) > 0) && (0x01 == (*aarq.userInformation)[0]) {
+		// unciphered InitiateRequest
+		initiateRequest := new(DlmsInitiateRequest)
+		if nil == initiateRequest.decode(bytes.NewReader(*aarq.userInformation)) {
+			dconn.maxReceivePduSize = initiateRequest.clientMaxReceivePduSize
+		}
+	}
 
 	err = dconn.transportSend(applicationClient, logicalDevice, aarqBytes)
 	if nil != err {
@@ -938,20 +1080,33 @@ func (dconn *DlmsConn) AppConnect(applicationClient uint16, logicalDevice uint16
 		return nil, aare, err
 	} else {
 		aconn = NewAppConn(dconn, applicationClient, logicalDevice, invokeId)
+		if (nil != aare.userInformation) && (len(*aare.userInformation) > 0) && (0x08 == (*aare.userInformation)[0]) {
+			// unciphered InitiateResponse
+			initiateResponse := new(DlmsInitiateResponse)
+			if nil == initiateResponse.decode(bytes.NewReader(*aare.userInformation)) {
+				aconn.setNegotiatedConformance(initiateResponse.negotiatedConformance.buf)
+				aconn.serverMaxReceivePduSize = initiateResponse.serverMaxReceivePduSize
+			}
+		}
 		return aconn, aare, nil
 	}
 }
 
 func TcpConnect(ipAddr string, port int) (dconn *DlmsConn, err error) {
+	return TcpConnectContext(context.Background(), ipAddr, port)
+}
+
+func TcpConnectContext(ctx context.Context, ipAddr string, port int) (dconn *DlmsConn, err error) {
 	var (
-		conn net.Conn
+		conn   net.Conn
+		dialer net.Dialer
 	)
 
 	dconn = new(DlmsConn)
 	dconn.transportType = Transport_TCP
 
 	debugLog("connecting tcp transport: %s:%d\n", ipAddr, port)
-	conn, err = net.Dial("tcp", fmt.Sprintf("%s:%d", ipAddr, port))
+	conn, err = dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", ipAddr, port))
 	if nil != err {
 		return nil, err
 	}
@@ -977,29 +1132,56 @@ Optional 'cosemWaitTime' should be set to average time what it takes for
     avoiding of sending unnecessary RR frames.
 */
 func HdlcConnect(ipAddr string, port int, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	return HdlcConnectContext(context.Background(), ipAddr, port, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
+
+// Same as HdlcConnect() but connecting is aborted when 'ctx' is done.
+func HdlcConnectContext(ctx context.Context, ipAddr string, port int, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
 	var (
-		conn net.Conn
+		conn   net.Conn
+		dialer net.Dialer
 	)
 
-	dconn = new(DlmsConn)
-	dconn.transportType = Transport_HDLC
-
 	debugLog("connecting hdlc transport over tcp: %s:%d\n", ipAddr, port)
-	conn, err = net.Dial("tcp", fmt.Sprintf("%s:%d", ipAddr, port))
+	conn, err = dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", ipAddr, port))
 	if nil != err {
 		errorLog("net.Dial() failed: %v", err)
 		return nil, err
 	}
-	dconn.hdlcRwc = conn
 
-	client := NewHdlcTransport(dconn.hdlcRwc, responseTimeout, true, uint8(applicationClient), logicalDevice, physicalDevice, serverAddressLength)
-	dconn.hdlcResponseTimeout = responseTimeout
-	dconn.snrmTimeout = snrmTimeout
-	dconn.discTimeout = discTimeout
+	return HdlcConnectRWContext(ctx, conn, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
 
+/*
+Connects hdlc transport over arbitrary stream, e.g. serial port or optical
+probe (see OpenSerial()). Stream is closed when connecting fails or when
+connection is closed. For meaning of parameters see HdlcConnect().
+*/
+func HdlcConnectRW(rwc io.ReadWriteCloser, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	return HdlcConnectRWContext(context.Background(), rwc, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout)
+}
+
+// Same as HdlcConnectRW() but connecting is aborted when 'ctx' is done.
+func HdlcConnectRWContext(ctx context.Context, rwc io.ReadWriteCloser, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration) (dconn *DlmsConn, err error) {
+	return hdlcConnectRW(ctx, rwc, applicationClient, logicalDevice, physicalDevice, serverAddressLength, responseTimeout, cosemWaitTime, snrmTimeout, discTimeout, 0)
+}
+
+// Same as HdlcConnectRWContext() but client transport reads frames using test implementation 'readFrameImpl' set before transport goroutine starts.
+func hdlcConnectRW(ctx context.Context, rwc io.ReadWriteCloser, applicationClient uint16, logicalDevice uint16, physicalDevice *uint16, serverAddressLength *int, responseTimeout time.Duration, cosemWaitTime *time.Duration, snrmTimeout time.Duration, discTimeout time.Duration, readFrameImpl int) (dconn *DlmsConn, err error) {
+
+	dconn = new(DlmsConn)
+	dconn.transportType = Transport_HDLC
+	dconn.hdlcRwc = rwc
+
+	client := newHdlcTransport(dconn.hdlcRwc, responseTimeout, true, uint8(applicationClient), logicalDevice, physicalDevice, serverAddressLength)
+	client.readFrameImpl = readFrameImpl
 	if nil != cosemWaitTime {
 		client.SetForCosem(*cosemWaitTime)
 	}
+	go client.handleHdlc()
+	dconn.hdlcResponseTimeout = responseTimeout
+	dconn.snrmTimeout = snrmTimeout
+	dconn.discTimeout = discTimeout
 
 	// send SNRM
 	ch := make(chan error, 1)
@@ -1010,7 +1192,7 @@ func HdlcConnect(ipAddr string, port int, applicationClient uint16, logicalDevic
 	case err = <-ch:
 		if nil != err {
 			errorLog("client.SendSNRM() failed: %v", err)
-			conn.Close()
+			rwc.Close()
 			client.Close()
 			return nil, err
 		}
@@ -1018,21 +1200,32 @@ func HdlcConnect(ipAddr string, port int, applicationClient uint16, logicalDevic
 		dconn.rwc = client
 	case <-time.After(dconn.snrmTimeout):
 		errorLog("SendSNRM(): error timeout")
-		conn.Close()
+		rwc.Close()
 		client.Close()
 		return nil, ErrDlmsTimeout
+	case <-ctx.Done():
+		errorLog("SendSNRM(): %v", ctx.Err())
+		rwc.Close()
+		client.Close()
+		return nil, ctx.Err()
 	}
 
 	return dconn, nil
 }
 
+func (dconn *DlmsConn) isClosed() bool {
+	dconn.closedMutex.Lock()
+	defer dconn.closedMutex.Unlock()
+	return dconn.closed
+}
+
 func (dconn *DlmsConn) Close() (err error) {
 	debugLog("closing transport connection ...")
 
 	dconn.closedMutex.Lock()
 	if !dconn.closed {
 		switch dconn.transportType {
-		case Transport_TCP:
+		case Transport_TCP, Transport_UDP:
 			dconn.rwc.Close()
 		case Transport_HDLC:
 			// send DISC
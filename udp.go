@@ -0,0 +1,148 @@
+package gocosem
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Largest possible UDP payload.
+const udpMaxDatagramSize = 65507
+
+/*
+DLMS wrapper over UDP. Each wrapper pdu is sent in single datagram. Reads
+return data of received datagram, part of datagram not fitting into read
+buffer is returned by following reads.
+
+If no datagram is received within 'responseTimeout' last sent datagram is
+sent again, after 'retries' retransmissions read fails with ErrDlmsTimeout.
+Before sending new datagram any late replies to retransmitted datagrams are
+discarded unless read is in progress. Retransmission resends only last
+datagram, AppConn therefore never has more than one request outstanding over
+UDP.
+*/
+type tUdpTransport struct {
+	conn            *net.UDPConn
+	responseTimeout time.Duration
+	retries         int
+
+	mtx      sync.Mutex
+	lastSent []byte // datagram sent again if reply does not arrive in time
+	datagram []byte // unread rest of last received datagram
+	reading  bool   // read is waiting for datagram
+}
+
+func (udp *tUdpTransport) discardPending() {
+	p := make([]byte, udpMaxDatagramSize)
+	udp.conn.SetReadDeadline(time.Now())
+	for {
+		n, err := udp.conn.Read(p)
+		if nil != err {
+			break
+		}
+		debugLog("udp: discarding late datagram: % 02X", p[0:n])
+	}
+	udp.conn.SetReadDeadline(time.Time{})
+}
+
+func (udp *tUdpTransport) Write(p []byte) (n int, err error) {
+	if len(p) > udpMaxDatagramSize {
+		err = fmt.Errorf("udp: datagram too long: %d", len(p))
+		errorLog("%s", err)
+		return 0, err
+	}
+	udp.mtx.Lock()
+	// read in progress (e.g. finishing cancelled request) must not be woken up by read deadline
+	if !udp.reading {
+		udp.discardPending()
+		udp.datagram = nil
+	}
+	udp.lastSent = append([]byte(nil), p...)
+	udp.mtx.Unlock()
+	return udp.conn.Write(p)
+}
+
+func (udp *tUdpTransport) receive() (err error, datagram []byte) {
+	p := make([]byte, udpMaxDatagramSize)
+	for retry := 0; ; retry++ {
+		if udp.responseTimeout > 0 {
+			udp.conn.SetReadDeadline(time.Now().Add(udp.responseTimeout))
+		}
+		n, err := udp.conn.Read(p)
+		if nil == err {
+			return nil, p[0:n]
+		}
+		if !isTimeOutErr(err) {
+			errorLog("udp: conn.Read() failed: %v", err)
+			return err, nil
+		}
+		udp.mtx.Lock()
+		lastSent := udp.lastSent
+		udp.mtx.Unlock()
+		if (retry >= udp.retries) || (nil == lastSent) {
+			errorLog("udp: no reply received, retransmissions: %d", retry)
+			return ErrDlmsTimeout, nil
+		}
+		debugLog("udp: no reply received, retransmitting datagram")
+		_, err = udp.conn.Write(lastSent)
+		if nil != err {
+			errorLog("udp: conn.Write() failed: %v", err)
+			return err, nil
+		}
+	}
+}
+
+func (udp *tUdpTransport) Read(p []byte) (n int, err error) {
+	udp.mtx.Lock()
+	defer udp.mtx.Unlock()
+
+	if 0 == len(udp.datagram) {
+		udp.reading = true
+		udp.mtx.Unlock()
+		err, datagram := udp.receive()
+		udp.mtx.Lock()
+		udp.reading = false
+		if nil != err {
+			return 0, err
+		}
+		udp.datagram = datagram
+	}
+	n = copy(p, udp.datagram)
+	udp.datagram = udp.datagram[n:]
+	return n, nil
+}
+
+func (udp *tUdpTransport) Close() error {
+	return udp.conn.Close()
+}
+
+/*
+Connects DLMS wrapper transport over UDP.
+
+'responseTimeout' is time to wait for reply before request is sent again,
+zero means waiting forever without retransmissions. 'retries' is maximum
+number of retransmissions of single request.
+*/
+func UdpConnect(ipAddr string, port int, responseTimeout time.Duration, retries int) (dconn *DlmsConn, err error) {
+	return UdpConnectContext(context.Background(), ipAddr, port, responseTimeout, retries)
+}
+
+func UdpConnectContext(ctx context.Context, ipAddr string, port int, responseTimeout time.Duration, retries int) (dconn *DlmsConn, err error) {
+	var dialer net.Dialer
+
+	dconn = new(DlmsConn)
+	dconn.transportType = Transport_UDP
+
+	debugLog("connecting udp transport: %s:%d\n", ipAddr, port)
+	conn, err := dialer.DialContext(ctx, "udp", fmt.Sprintf("%s:%d", ipAddr, port))
+	if nil != err {
+		errorLog("net.Dial() failed: %v", err)
+		return nil, err
+	}
+	dconn.rwc = &tUdpTransport{conn: conn.(*net.UDPConn), responseTimeout: responseTimeout, retries: retries}
+
+	debugLog("udp transport connected: %s:%d\n", ipAddr, port)
+	return dconn, nil
+}